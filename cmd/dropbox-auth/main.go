@@ -0,0 +1,51 @@
+// Command dropbox-auth obtains a Dropbox refresh token for the MCP server with
+// the OAuth2 authorization code flow and PKCE. It prints the authorization URL,
+// reads the code Dropbox shows after approval and prints the environment
+// variables to configure.
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"golang-mcp-testing/tools/dropbox"
+)
+
+func main() {
+	appKey := flag.String("app-key", os.Getenv("DROPBOX_APP_KEY"), "Dropbox app key (defaults to $DROPBOX_APP_KEY)")
+	appSecret := flag.String("app-secret", os.Getenv("DROPBOX_APP_SECRET"), "Dropbox app secret, optional with PKCE (defaults to $DROPBOX_APP_SECRET)")
+	flag.Parse()
+
+	flow, err := dropbox.NewPKCEFlow(*appKey, *appSecret)
+	if err != nil {
+		log.Fatalf("Failed to start authorization: %v", err)
+	}
+
+	fmt.Fprintln(os.Stderr, "1. Open this URL in a browser and allow access:")
+	fmt.Fprintln(os.Stderr, "\n   "+flow.AuthorizeURL()+"\n")
+	fmt.Fprint(os.Stderr, "2. Paste the authorization code here: ")
+
+	code, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && code == "" {
+		log.Fatalf("Failed to read authorization code: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	token, err := flow.Exchange(ctx, code)
+	if err != nil {
+		log.Fatalf("Failed to obtain refresh token: %v", err)
+	}
+
+	fmt.Fprintln(os.Stderr, "\nAuthorized. Configure the server with:")
+	fmt.Printf("DROPBOX_APP_KEY=%s\n", *appKey)
+	if *appSecret != "" {
+		fmt.Printf("DROPBOX_APP_SECRET=%s\n", *appSecret)
+	}
+	fmt.Printf("DROPBOX_REFRESH_TOKEN=%s\n", token.RefreshToken)
+}
@@ -3,7 +3,7 @@ module golang-mcp-testing
 go 1.24.2
 
 require (
-	github.com/davecgh/go-spew v1.1.1
+	github.com/gorilla/websocket v1.5.3
 	github.com/localrivet/gomcp v1.6.5
 )
 
@@ -12,7 +12,6 @@ require (
 	github.com/gobwas/httphead v0.1.0 // indirect
 	github.com/gobwas/pool v0.2.1 // indirect
 	github.com/gobwas/ws v1.4.0 // indirect
-	github.com/gorilla/websocket v1.5.3 // indirect
 	github.com/klauspost/compress v1.18.0 // indirect
 	github.com/localrivet/wilduri v0.0.0-20250504021349-6ce732e97cca // indirect
 	github.com/mitchellh/mapstructure v1.5.0 // indirect
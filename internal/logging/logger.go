@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Log formats
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Rotation defaults for log files
+const (
+	DefaultMaxSizeMB  = 10
+	DefaultMaxBackups = 3
+)
+
+// Options configures the server logger
+type Options struct {
+	// Level is the minimum level logged: debug, info, warn or error. Defaults to info.
+	Level string
+	// LevelVar, if set, is initialized to Level and controls the logger's level,
+	// so it can be changed while the logger is in use
+	LevelVar *slog.LevelVar
+	// Format is FormatJSON (the default) or FormatText
+	Format string
+	// File, if set, is a log file rotated at MaxSizeMB megabytes keeping MaxBackups
+	// old files. Logs go to stderr otherwise.
+	File       string
+	MaxSizeMB  int
+	MaxBackups int
+	// Secrets and RedactPatterns are scrubbed from all output, see NewRedactingHandler
+	Secrets        []string
+	RedactPatterns []string
+}
+
+// New builds a redacting logger from opts. It never writes to stdout, which
+// belongs to the stdio transport. Close the returned io.Closer on shutdown to
+// close the log file.
+func New(opts Options) (*slog.Logger, io.Closer, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+	var leveler slog.Leveler = level
+	if opts.LevelVar != nil {
+		opts.LevelVar.Set(level)
+		leveler = opts.LevelVar
+	}
+
+	format := strings.ToLower(opts.Format)
+	if format == "" {
+		format = FormatJSON
+	}
+	if format != FormatJSON && format != FormatText {
+		return nil, nil, fmt.Errorf("invalid log format %q: use %s or %s", opts.Format, FormatJSON, FormatText)
+	}
+
+	var out io.Writer = os.Stderr
+	var closer io.Closer = nopCloser{}
+	if opts.File != "" {
+		file, err := OpenRotatingFile(opts.File, int64(opts.MaxSizeMB)*1024*1024, opts.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		out, closer = file, file
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: leveler}
+	var handler slog.Handler = slog.NewJSONHandler(out, handlerOpts)
+	if format == FormatText {
+		handler = slog.NewTextHandler(out, handlerOpts)
+	}
+
+	redacting, err := NewRedactingHandler(handler, opts.Secrets, opts.RedactPatterns)
+	if err != nil {
+		closer.Close()
+		return nil, nil, err
+	}
+	return slog.New(redacting), closer, nil
+}
+
+// ParseLevel parses a log level name, defaulting to info when name is empty
+func ParseLevel(name string) (slog.Level, error) {
+	level := slog.LevelInfo
+	if name != "" {
+		if err := level.UnmarshalText([]byte(name)); err != nil {
+			return level, fmt.Errorf("invalid log level %q: use debug, info, warn or error", name)
+		}
+	}
+	return level, nil
+}
+
+// nopCloser is returned by New when logging to stderr
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
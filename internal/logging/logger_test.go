@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureStdout runs fn with os.Stdout redirected and returns what was written to it
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	original := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = original }()
+
+	fn()
+	w.Close()
+	out, _ := io.ReadAll(r)
+	return string(out)
+}
+
+func TestNew_FileLevelAndFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+
+	stdout := captureStdout(t, func() {
+		logger, closer, err := New(Options{Level: "warn", File: path, Secrets: []string{"super-secret"}})
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		logger.Info("dropped")
+		logger.Warn("kept", "key", "super-secret")
+		closer.Close()
+	})
+	if stdout != "" {
+		t.Errorf("Expected nothing on stdout, got: %q", stdout)
+	}
+
+	lines := strings.Split(strings.TrimSpace(readLog(t, path)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected only the warning to be logged, got: %v", lines)
+	}
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Expected a JSON log line, got %q: %v", lines[0], err)
+	}
+	if record["msg"] != "kept" || record["level"] != "WARN" || record["key"] != Redacted {
+		t.Errorf("Unexpected log record: %v", record)
+	}
+}
+
+func TestNew_TextFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	logger, closer, err := New(Options{Format: "TEXT", Level: "debug", File: path})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	logger.Debug("listing folder", "path", "/docs")
+	closer.Close()
+
+	if got := readLog(t, path); !strings.Contains(got, `level=DEBUG msg="listing folder" path=/docs`) {
+		t.Errorf("Expected a text log line, got: %q", got)
+	}
+}
+
+func TestNew_InvalidOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "Level", opts: Options{Level: "verbose"}},
+		{name: "Format", opts: Options{Format: "xml"}},
+		{name: "Pattern", opts: Options{RedactPatterns: []string{"("}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := New(tt.opts); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
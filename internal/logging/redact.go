@@ -0,0 +1,186 @@
+// Package logging provides the slog handlers used by the server.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Redacted replaces secret material in log output
+const Redacted = "[REDACTED]"
+
+// minSecretLength is the shortest configured secret that is redacted. Shorter
+// values would match too much unrelated text to be useful.
+const minSecretLength = 4
+
+// sensitiveKeyWords mark attributes whose whole value is secret when they end
+// the key, as in "token", "refresh_token", "client_secret" or "apiKey". Keys
+// where they are only a qualifier, like "token_count" or "max_tokens", are not.
+var sensitiveKeyWords = map[string]bool{
+	"token": true, "secret": true, "password": true, "passwd": true, "apikey": true,
+	"authorization": true, "credential": true, "credentials": true, "cookie": true,
+}
+
+// builtinPatterns find secrets inside free text. The first group, if any, is kept.
+var builtinPatterns = []*regexp.Regexp{
+	// Authorization headers and bearer tokens
+	regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=\-]+`),
+	// key=value and "key": "value" pairs of well known secret names
+	regexp.MustCompile(`(?i)((?:api[_-]?key|access[_-]?token|refresh[_-]?token|client[_-]?secret|app[_-]?secret|password)["']?\s*[:=]\s*["']?)[^\s"'&,;})\]]+`),
+	// Dropbox short-lived access tokens
+	regexp.MustCompile(`\bsl\.[A-Za-z0-9._\-]{16,}`),
+}
+
+// RedactingHandler wraps a slog.Handler and scrubs bearer tokens, API keys and
+// other secrets from messages and attribute values before passing records on.
+// Attributes with sensitive keys such as "token", "access_token" or "api_key" are redacted whole.
+type RedactingHandler struct {
+	next     slog.Handler
+	secrets  []string
+	patterns []*regexp.Regexp
+}
+
+// NewRedactingHandler wraps next. secrets are exact values to scrub, such as
+// the configured API key; patterns are additional regular expressions whose
+// matches are scrubbed.
+func NewRedactingHandler(next slog.Handler, secrets []string, patterns []string) (*RedactingHandler, error) {
+	h := &RedactingHandler{next: next, patterns: append([]*regexp.Regexp{}, builtinPatterns...)}
+
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength {
+			h.secrets = append(h.secrets, secret)
+		}
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+		h.patterns = append(h.patterns, re)
+	}
+	return h, nil
+}
+
+// Enabled reports whether the wrapped handler handles records at level
+func (h *RedactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle redacts the record's message and attributes and passes it on
+func (h *RedactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, h.RedactString(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+// WithAttrs redacts attrs once, when they are attached
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redactAttr(a)
+	}
+	return &RedactingHandler{next: h.next.WithAttrs(redacted), secrets: h.secrets, patterns: h.patterns}
+}
+
+// WithGroup returns a handler that nests further attributes under name
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{next: h.next.WithGroup(name), secrets: h.secrets, patterns: h.patterns}
+}
+
+// RedactString replaces every secret and pattern match in s
+func (h *RedactingHandler) RedactString(s string) string {
+	for _, secret := range h.secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	for _, re := range h.patterns {
+		if re.NumSubexp() > 0 {
+			s = re.ReplaceAllString(s, "${1}"+Redacted)
+		} else {
+			s = re.ReplaceAllLiteralString(s, Redacted)
+		}
+	}
+	return s
+}
+
+// redactAttr redacts a single attribute, recursing into groups
+func (h *RedactingHandler) redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		redacted := make([]slog.Attr, len(attrs))
+		for i, attr := range attrs {
+			redacted[i] = h.redactAttr(attr)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	}
+
+	if isSensitiveKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, h.RedactString(a.Value.String()))
+	case slog.KindAny:
+		// Errors, structs and other values are formatted and only replaced when they contain a secret
+		var text string
+		if err, ok := a.Value.Any().(error); ok {
+			text = err.Error()
+		} else {
+			text = fmt.Sprintf("%+v", a.Value.Any())
+		}
+		if redacted := h.RedactString(text); redacted != text {
+			return slog.String(a.Key, redacted)
+		}
+	}
+	return a
+}
+
+// isSensitiveKey reports whether an attribute key names a secret: its last
+// segment, or its last two joined as in "api_key", is a sensitive word
+func isSensitiveKey(key string) bool {
+	segments := keySegments(key)
+	if len(segments) == 0 {
+		return false
+	}
+	last := segments[len(segments)-1]
+	if sensitiveKeyWords[last] {
+		return true
+	}
+	return len(segments) > 1 && sensitiveKeyWords[segments[len(segments)-2]+last]
+}
+
+// keySegments splits a key into lowercase words at "_", "-", "." and other
+// separators and at camelCase boundaries, so "refreshToken" is [refresh token]
+func keySegments(key string) []string {
+	var segments []string
+	var current []rune
+	flush := func() {
+		if len(current) > 0 {
+			segments = append(segments, strings.ToLower(string(current)))
+			current = current[:0]
+		}
+	}
+	runes := []rune(key)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))):
+			// A word starts at "T" in "refreshToken" and in "APIToken"
+			flush()
+		}
+		current = append(current, r)
+	}
+	flush()
+	return segments
+}
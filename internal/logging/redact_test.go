@@ -0,0 +1,123 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+const testToken = "sl.u.AbCdEfGhIjKlMnOpQrStUvWxYz0123456789"
+
+func newTestLogger(t *testing.T, secrets, patterns []string) (*slog.Logger, *bytes.Buffer) {
+	t.Helper()
+	var buf bytes.Buffer
+	handler, err := NewRedactingHandler(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}), secrets, patterns)
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	return slog.New(handler), &buf
+}
+
+func TestRedactingHandler_ScrubsSecrets(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(*slog.Logger)
+		kept string
+	}{
+		{
+			name: "Bearer token in message",
+			log:  func(l *slog.Logger) { l.Info("sending Authorization: Bearer " + testToken) },
+			kept: "Authorization: Bearer [REDACTED]",
+		},
+		{
+			name: "Configured secret in attribute",
+			log:  func(l *slog.Logger) { l.Info("request", "url", "https://example.com/?key=my-app-secret-value") },
+			kept: "https://example.com/?key=[REDACTED]",
+		},
+		{
+			name: "Sensitive key",
+			log:  func(l *slog.Logger) { l.Info("refreshed", "access_token", "opaque", "expires_in", 14400) },
+			kept: `"access_token":"[REDACTED]","expires_in":14400`,
+		},
+		{
+			name: "Key value pair in text",
+			log:  func(l *slog.Logger) { l.Info("config", "line", `{"refresh_token": "abc123xyz"}`) },
+			kept: `refresh_token\": \"[REDACTED]`,
+		},
+		{
+			name: "Error value",
+			log:  func(l *slog.Logger) { l.Error("failed", "error", errors.New("401 for token "+testToken)) },
+			kept: "401 for token [REDACTED]",
+		},
+		{
+			name: "Struct value",
+			log: func(l *slog.Logger) {
+				l.Info("args", "args", struct{ Header string }{Header: "Bearer " + testToken})
+			},
+			kept: "{Header:Bearer [REDACTED]}",
+		},
+		{
+			name: "Grouped and attached attributes",
+			log: func(l *slog.Logger) {
+				l.With("client", testToken).WithGroup("http").Info("call", slog.Group("req", "auth", "Bearer "+testToken))
+			},
+			kept: `"msg":"call","client":"[REDACTED]","http":{"req":{"auth":"Bearer [REDACTED]"}}`,
+		},
+		{
+			name: "Configured pattern",
+			log:  func(l *slog.Logger) { l.Info("account ACCT-123456 created") },
+			kept: "account [REDACTED] created",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, buf := newTestLogger(t, []string{"my-app-secret-value"}, []string{`ACCT-\d+`})
+			tt.log(logger)
+
+			out := buf.String()
+			for _, secret := range []string{testToken, "my-app-secret-value", "opaque", "abc123xyz", "ACCT-123456"} {
+				if strings.Contains(out, secret) {
+					t.Errorf("Expected %q to be redacted, got: %s", secret, out)
+				}
+			}
+			if !strings.Contains(out, tt.kept) {
+				t.Errorf("Expected output to contain %s, got: %s", tt.kept, out)
+			}
+		})
+	}
+}
+
+func TestRedactingHandler_LeavesOrdinaryValues(t *testing.T) {
+	logger, buf := newTestLogger(t, []string{"ab"}, nil)
+	logger.Info("listing folder", "path", "/about/tables", "count", 3, "complete", true, "max_tokens", 100, "token_count", 42)
+
+	out := buf.String()
+	if !strings.Contains(out, `"path":"/about/tables","count":3,"complete":true,"max_tokens":100,"token_count":42`) {
+		t.Errorf("Expected ordinary values and short secrets to be left alone, got: %s", out)
+	}
+}
+
+func TestIsSensitiveKey(t *testing.T) {
+	sensitive := []string{"token", "access_token", "refresh_token", "refreshToken", "APIToken", "client_secret", "api_key", "apiKey", "X-API-Key", "Authorization", "password", "set-cookie"}
+	for _, key := range sensitive {
+		if !isSensitiveKey(key) {
+			t.Errorf("Expected %q to be sensitive", key)
+		}
+	}
+
+	ordinary := []string{"max_tokens", "token_count", "tokens", "key", "path", "secretary", "author", "cursor"}
+	for _, key := range ordinary {
+		if isSensitiveKey(key) {
+			t.Errorf("Expected %q not to be sensitive", key)
+		}
+	}
+}
+
+func TestNewRedactingHandler_InvalidPattern(t *testing.T) {
+	if _, err := NewRedactingHandler(slog.NewTextHandler(&bytes.Buffer{}, nil), nil, []string{"("}); err == nil {
+		t.Fatal("Expected an error for an invalid pattern")
+	}
+}
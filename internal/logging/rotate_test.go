@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readLog(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return string(content)
+}
+
+func TestRotatingFile_RotatesAndKeepsBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "server.log")
+	f, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer f.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	}
+
+	expected := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	}
+	for file, content := range expected {
+		if got := readLog(t, file); got != content {
+			t.Errorf("Expected %s to contain %q, got %q", filepath.Base(file), content, got)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected only 2 backups to be kept, stat error: %v", err)
+	}
+}
+
+func TestRotatingFile_AppendsToExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	if err := os.WriteFile(path, []byte("earlier\n"), 0600); err != nil {
+		t.Fatalf("failed to write log: %v", err)
+	}
+
+	f, err := OpenRotatingFile(path, 12, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	f.Write([]byte("later\n"))
+	f.Close()
+
+	// The existing size counts towards the limit
+	if got := readLog(t, path); got != "later\n" || readLog(t, path+".1") != "earlier\n" {
+		t.Errorf("Expected the existing content to be rotated out, got %q", got)
+	}
+	if _, err := f.Write([]byte("closed")); err == nil {
+		t.Error("Expected an error writing to a closed file")
+	}
+}
+
+func TestRotatingFile_NoRotationWithoutLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	f, err := OpenRotatingFile(path, 0, 3)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer f.Close()
+
+	f.Write([]byte(strings.Repeat("x", 100)))
+	f.Write([]byte(strings.Repeat("y", 100)))
+	if got := readLog(t, path); len(got) != 200 {
+		t.Errorf("Expected a single 200 byte file, got %d bytes", len(got))
+	}
+}
@@ -0,0 +1,456 @@
+// Package nettransport serves the MCP server over the network, as streamable
+// HTTP with legacy SSE or as WebSocket, with optional bearer token auth and TLS.
+// It owns its listener, unlike the gomcp network transports, so every endpoint
+// sits behind the same authentication.
+package nettransport
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/localrivet/gomcp/transport"
+)
+
+// Transport kinds
+const (
+	KindHTTP      = "http"
+	KindWebSocket = "websocket"
+)
+
+// Endpoints. The HTTP transport serves streamable HTTP at MCPPath and, for
+// clients of the 2024-11-05 protocol, an SSE stream at SSEPath that takes
+// requests at MessagePath. The WebSocket transport serves WebSocketPath.
+const (
+	MCPPath       = "/mcp"
+	SSEPath       = "/sse"
+	MessagePath   = "/message"
+	WebSocketPath = "/ws"
+)
+
+// maxMessageSize bounds a single JSON-RPC message from a client
+const maxMessageSize = 10 << 20
+
+// peerBuffer is how many server messages may queue for a slow client before
+// further ones are dropped
+const peerBuffer = 64
+
+// keepAliveInterval is how often an idle SSE stream gets a comment to keep proxies from closing it
+const keepAliveInterval = 30 * time.Second
+
+// shutdownTimeout bounds how long Stop waits for in-flight requests
+const shutdownTimeout = 5 * time.Second
+
+// Options configures a network transport
+type Options struct {
+	// Kind is KindHTTP or KindWebSocket
+	Kind string
+	// Addr is the address to listen on, e.g. "127.0.0.1:8080"
+	Addr string
+	// AuthToken, if set, must be sent by clients as "Authorization: Bearer <token>"
+	AuthToken string
+	// TLSCertFile and TLSKeyFile, if set, serve HTTPS and WSS
+	TLSCertFile string
+	TLSKeyFile  string
+	// AllowedOrigins are browser origins, e.g. "https://app.example.com", allowed
+	// besides localhost. Requests from any other origin are refused.
+	AllowedOrigins []string
+}
+
+// Transport is a gomcp transport serving MCP clients over the network. Set it
+// on a server with SetTransport.
+type Transport struct {
+	transport.BaseTransport
+
+	opts      Options
+	tlsConfig *tls.Config
+	server    *http.Server
+	listener  net.Listener
+
+	mu    sync.Mutex
+	peers map[string]*peer
+}
+
+// peer is a connected client receiving messages outside of request/response:
+// an SSE stream or a WebSocket connection
+type peer struct {
+	messages chan []byte
+	done     <-chan struct{}
+}
+
+// New validates opts and builds a transport. Nothing listens until Start.
+func New(opts Options) (*Transport, error) {
+	t := &Transport{opts: opts, peers: make(map[string]*peer)}
+
+	mux := http.NewServeMux()
+	switch opts.Kind {
+	case KindHTTP:
+		mux.HandleFunc(MCPPath, t.handleMCP)
+		mux.HandleFunc(SSEPath, t.handleLegacySSE)
+		mux.HandleFunc(MessagePath, t.handleLegacyMessage)
+	case KindWebSocket:
+		mux.HandleFunc(WebSocketPath, t.handleWebSocket)
+	default:
+		return nil, fmt.Errorf("unknown transport %q: use %s or %s", opts.Kind, KindHTTP, KindWebSocket)
+	}
+
+	var handler http.Handler = mux
+	if opts.AuthToken != "" {
+		handler = requireBearerToken(opts.AuthToken, handler)
+	}
+	handler = requireAllowedOrigin(opts.AllowedOrigins, handler)
+	t.server = &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+
+	if (opts.TLSCertFile == "") != (opts.TLSKeyFile == "") {
+		return nil, fmt.Errorf("TLS needs both a certificate and a key file")
+	}
+	if opts.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.TLSCertFile, opts.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		t.tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+		t.server.TLSConfig = t.tlsConfig.Clone()
+	}
+	return t, nil
+}
+
+// requireBearerToken rejects requests without the expected Authorization header
+func requireBearerToken(token string, next http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mcp"`)
+			http.Error(w, "missing or invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAllowedOrigin rejects requests sent by browser pages from origins
+// other than localhost and allowed. Without it any web page, or one reached
+// through DNS rebinding, could call tools on a server listening on localhost.
+// Clients outside a browser send no Origin.
+func requireAllowedOrigin(allowed []string, next http.Handler) http.Handler {
+	allowedSet := make(map[string]bool, len(allowed))
+	for _, origin := range allowed {
+		allowedSet[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" && !allowedSet[strings.ToLower(origin)] && !isLocalOrigin(origin) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLocalOrigin reports whether origin is a page served from this machine
+func isLocalOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Initialize does nothing; the transport is set up by New
+func (t *Transport) Initialize() error {
+	return nil
+}
+
+// Start listens on the configured address and serves clients in the background
+func (t *Transport) Start() error {
+	listener, err := net.Listen("tcp", t.opts.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", t.opts.Addr, err)
+	}
+	t.listener = listener
+
+	go func() {
+		var err error
+		if t.tlsConfig != nil {
+			err = t.server.ServeTLS(listener, "", "")
+		} else {
+			err = t.server.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			t.GetLogger().Error("Network transport stopped", "error", err)
+		}
+	}()
+
+	t.GetLogger().Info("Serving MCP", "transport", t.opts.Kind, "url", t.URL(), "auth", t.opts.AuthToken != "")
+	return nil
+}
+
+// URL is the base URL clients connect to, with the endpoint of the transport kind
+func (t *Transport) URL() string {
+	scheme, path := "http", MCPPath
+	if t.opts.Kind == KindWebSocket {
+		scheme, path = "ws", WebSocketPath
+	}
+	if t.tlsConfig != nil {
+		scheme += "s"
+	}
+	addr := t.opts.Addr
+	if t.listener != nil {
+		addr = t.listener.Addr().String()
+	}
+	return scheme + "://" + addr + path
+}
+
+// Stop closes the listener and waits briefly for in-flight requests
+func (t *Transport) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return t.server.Shutdown(ctx)
+}
+
+// Send delivers a server-initiated message to every connected client. The
+// gomcp server doesn't track which client a notification belongs to.
+func (t *Transport) Send(message []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for id, p := range t.peers {
+		select {
+		case p.messages <- message:
+		default:
+			t.GetLogger().Warn("Dropping message for slow client", "session", id)
+		}
+	}
+	return nil
+}
+
+// Receive isn't supported; messages are handled as they arrive
+func (t *Transport) Receive() ([]byte, error) {
+	return nil, errors.New("receive not supported for network transports")
+}
+
+// addPeer registers a client for server messages until done is closed
+func (t *Transport) addPeer(done <-chan struct{}) (string, *peer) {
+	id := newSessionID()
+	p := &peer{messages: make(chan []byte, peerBuffer), done: done}
+
+	t.mu.Lock()
+	t.peers[id] = p
+	t.mu.Unlock()
+	return id, p
+}
+
+// removePeer unregisters a client
+func (t *Transport) removePeer(id string) {
+	t.mu.Lock()
+	delete(t.peers, id)
+	t.mu.Unlock()
+}
+
+// reply queues a response for a peer, giving up when the peer disconnects
+func (p *peer) reply(message []byte) {
+	select {
+	case p.messages <- message:
+	case <-p.done:
+	}
+}
+
+// newSessionID returns a random, unguessable session ID
+func newSessionID() string {
+	random := make([]byte, 16)
+	rand.Read(random)
+	return hex.EncodeToString(random)
+}
+
+// handleMCP serves the streamable HTTP endpoint: POST sends a message and gets
+// the response in return, GET opens a stream of server messages
+func (t *Transport) handleMCP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		response, ok := t.handleRequestBody(w, r)
+		if !ok {
+			return
+		}
+		if response == nil {
+			// Notifications and responses have nothing to return
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(response)
+	case http.MethodGet:
+		id, p := t.addPeer(r.Context().Done())
+		defer t.removePeer(id)
+		t.streamEvents(w, r, p, "")
+	case http.MethodDelete:
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleLegacySSE opens a 2024-11-05 SSE stream. Its first event names the
+// endpoint for requests, whose responses arrive on the stream.
+func (t *Transport) handleLegacySSE(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, p := t.addPeer(r.Context().Done())
+	defer t.removePeer(id)
+	t.streamEvents(w, r, p, MessagePath+"?sessionId="+id)
+}
+
+// handleLegacyMessage takes a request for a legacy SSE stream
+func (t *Transport) handleLegacyMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	t.mu.Lock()
+	p, ok := t.peers[r.URL.Query().Get("sessionId")]
+	t.mu.Unlock()
+	if !ok {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+
+	response, ok := t.handleRequestBody(w, r)
+	if !ok {
+		return
+	}
+	if response != nil {
+		p.reply(response)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleRequestBody reads a JSON-RPC message from the request and handles it.
+// On failure it writes an error response and returns false.
+func (t *Transport) handleRequestBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	// Browsers send other content types cross-origin without a CORS preflight
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	response, err := t.HandleMessage(body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to handle message: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+	return response, true
+}
+
+// streamEvents writes a peer's messages as SSE events until the client
+// disconnects. A non-empty endpoint is announced first.
+func (t *Transport) streamEvents(w http.ResponseWriter, r *http.Request, p *peer, endpoint string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if endpoint != "" {
+		fmt.Fprintf(w, "event: endpoint\ndata: %s\n\n", endpoint)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case message := <-p.messages:
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", strings.ReplaceAll(string(message), "\n", ""))
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// upgrader accepts WebSocket connections. The origin has already been checked
+// by requireAllowedOrigin.
+var upgrader = websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+
+// handleWebSocket serves a WebSocket connection, one JSON-RPC message per frame
+func (t *Transport) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already written an error response
+		return
+	}
+	conn.SetReadLimit(maxMessageSize)
+
+	done := make(chan struct{})
+	id, p := t.addPeer(done)
+	defer t.removePeer(id)
+
+	// A single writer drains responses and server messages
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		for {
+			select {
+			case message := <-p.messages:
+				if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+					conn.Close()
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		// Handle messages concurrently so a cancellation can overtake a running call
+		go func() {
+			response, err := t.HandleMessage(message)
+			if err != nil {
+				t.GetLogger().Error("Failed to handle WebSocket message", "error", err)
+				return
+			}
+			if response != nil {
+				p.reply(response)
+			}
+		}()
+	}
+
+	close(done)
+	<-writerDone
+	conn.Close()
+}
@@ -0,0 +1,348 @@
+package nettransport
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const testToken = "secret-token"
+
+// startTransport starts a transport on a free port whose handler answers
+// requests with their method and ignores notifications
+func startTransport(t *testing.T, opts Options) *Transport {
+	t.Helper()
+	opts.Addr = "127.0.0.1:0"
+	tr, err := New(opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	tr.SetMessageHandler(func(message []byte) ([]byte, error) {
+		var request struct {
+			ID     any    `json:"id"`
+			Method string `json:"method"`
+		}
+		if err := json.Unmarshal(message, &request); err != nil {
+			return nil, err
+		}
+		if request.ID == nil {
+			return nil, nil
+		}
+		return json.Marshal(map[string]any{"jsonrpc": "2.0", "id": request.ID, "result": request.Method})
+	})
+	if err := tr.Start(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	t.Cleanup(func() { tr.Stop() })
+	return tr
+}
+
+func baseURL(tr *Transport) string {
+	return "http://" + tr.listener.Addr().String()
+}
+
+func post(t *testing.T, url, token, body string) (*http.Response, string) {
+	t.Helper()
+	req, _ := http.NewRequest("POST", url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	content, _ := io.ReadAll(resp.Body)
+	return resp, string(content)
+}
+
+func TestHTTP_RequestResponse(t *testing.T) {
+	tr := startTransport(t, Options{Kind: KindHTTP})
+
+	resp, body := post(t, baseURL(tr)+MCPPath, "", `{"jsonrpc": "2.0", "id": 1, "method": "tools/list"}`)
+	if resp.StatusCode != http.StatusOK || body != `{"id":1,"jsonrpc":"2.0","result":"tools/list"}` {
+		t.Errorf("Expected the response, got %d: %s", resp.StatusCode, body)
+	}
+
+	resp, _ = post(t, baseURL(tr)+MCPPath, "", `{"jsonrpc": "2.0", "method": "notifications/initialized"}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected 202 for a notification, got %d", resp.StatusCode)
+	}
+}
+
+func TestHTTP_BearerToken(t *testing.T) {
+	tr := startTransport(t, Options{Kind: KindHTTP, AuthToken: testToken})
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{name: "Missing", token: "", status: http.StatusUnauthorized},
+		{name: "Wrong", token: "guess", status: http.StatusUnauthorized},
+		{name: "Valid", token: testToken, status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _ := post(t, baseURL(tr)+MCPPath, tt.token, `{"jsonrpc": "2.0", "id": 1, "method": "ping"}`)
+			if resp.StatusCode != tt.status {
+				t.Errorf("Expected %d, got %d", tt.status, resp.StatusCode)
+			}
+			if tt.status == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+				t.Error("Expected a WWW-Authenticate challenge")
+			}
+		})
+	}
+
+	// Streams are protected too
+	resp, err := http.Get(baseURL(tr) + SSEPath)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected the SSE stream to require the token, got %d", resp.StatusCode)
+	}
+}
+
+// readEvent reads the next SSE event, skipping comments
+func readEvent(t *testing.T, r *bufio.Reader) (event, data string) {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read event: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && event != "":
+			return event, data
+		}
+	}
+}
+
+func TestHTTP_LegacySSE(t *testing.T) {
+	tr := startTransport(t, Options{Kind: KindHTTP})
+
+	resp, err := http.Get(baseURL(tr) + SSEPath)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	events := bufio.NewReader(resp.Body)
+
+	event, endpoint := readEvent(t, events)
+	if event != "endpoint" || !strings.HasPrefix(endpoint, MessagePath+"?sessionId=") {
+		t.Fatalf("Expected an endpoint event, got %s: %s", event, endpoint)
+	}
+
+	postResp, _ := post(t, baseURL(tr)+endpoint, "", `{"jsonrpc": "2.0", "id": "a", "method": "initialize"}`)
+	if postResp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", postResp.StatusCode)
+	}
+	if event, data := readEvent(t, events); event != "message" || data != `{"id":"a","jsonrpc":"2.0","result":"initialize"}` {
+		t.Errorf("Expected the response on the stream, got %s: %s", event, data)
+	}
+
+	// Server-initiated messages reach the stream as well
+	tr.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`))
+	if _, data := readEvent(t, events); !strings.Contains(data, "list_changed") {
+		t.Errorf("Expected the notification on the stream, got %s", data)
+	}
+
+	if resp, _ := post(t, baseURL(tr)+MessagePath+"?sessionId=unknown", "", `{}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown session, got %d", resp.StatusCode)
+	}
+}
+
+func TestHTTP_Origin(t *testing.T) {
+	tr := startTransport(t, Options{Kind: KindHTTP, AllowedOrigins: []string{"https://app.example.com/"}})
+
+	tests := []struct {
+		name   string
+		origin string
+		status int
+	}{
+		{name: "No origin", origin: "", status: http.StatusOK},
+		{name: "Localhost", origin: "http://localhost:3000", status: http.StatusOK},
+		{name: "Loopback IP", origin: "http://127.0.0.1:8080", status: http.StatusOK},
+		{name: "Loopback IPv6", origin: "http://[::1]:8080", status: http.StatusOK},
+		{name: "Allowed", origin: "https://APP.example.com", status: http.StatusOK},
+		{name: "Cross-origin", origin: "https://evil.example", status: http.StatusForbidden},
+		{name: "Localhost lookalike", origin: "http://localhost.evil.example", status: http.StatusForbidden},
+		{name: "Opaque", origin: "null", status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", baseURL(tr)+MCPPath, strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "tools/call"}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Errorf("Expected %d, got %d", tt.status, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestHTTP_ContentType(t *testing.T) {
+	tr := startTransport(t, Options{Kind: KindHTTP})
+
+	tests := []struct {
+		contentType string
+		status      int
+	}{
+		{contentType: "application/json", status: http.StatusOK},
+		{contentType: "application/json; charset=utf-8", status: http.StatusOK},
+		{contentType: "text/plain", status: http.StatusUnsupportedMediaType},
+		{contentType: "application/x-www-form-urlencoded", status: http.StatusUnsupportedMediaType},
+		{contentType: "", status: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", baseURL(tr)+MCPPath, strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "tools/call"}`))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("Expected %d for %q, got %d", tt.status, tt.contentType, resp.StatusCode)
+		}
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	tr := startTransport(t, Options{Kind: KindWebSocket, AuthToken: testToken})
+	url := "ws://" + tr.listener.Addr().String() + WebSocketPath
+
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected the connection to be refused without a token, got %v", err)
+	}
+
+	crossOrigin := http.Header{"Authorization": {"Bearer " + testToken}, "Origin": {"https://evil.example"}}
+	if _, resp, err := websocket.DefaultDialer.Dial(url, crossOrigin); err == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expected a cross-origin connection to be refused, got %v", err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": {"Bearer " + testToken}})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc": "2.0", "id": 7, "method": "tools/call"}`))
+	_, message, err := conn.ReadMessage()
+	if err != nil || string(message) != `{"id":7,"jsonrpc":"2.0","result":"tools/call"}` {
+		t.Fatalf("Expected the response, got %s and error %v", message, err)
+	}
+
+	tr.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/message"}`))
+	if _, message, err = conn.ReadMessage(); err != nil || !strings.Contains(string(message), "notifications/message") {
+		t.Errorf("Expected the notification, got %s and error %v", message, err)
+	}
+}
+
+// writeTestCertificate writes a self-signed certificate for 127.0.0.1 and returns its paths
+func writeTestCertificate(t *testing.T) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	cert, _ = x509.ParseCertificate(der)
+	return certFile, keyFile, cert
+}
+
+func TestHTTP_TLS(t *testing.T) {
+	certFile, keyFile, cert := writeTestCertificate(t)
+	tr := startTransport(t, Options{Kind: KindHTTP, TLSCertFile: certFile, TLSKeyFile: keyFile})
+
+	if !strings.HasPrefix(tr.URL(), "https://127.0.0.1:") {
+		t.Errorf("Expected an https URL, got %s", tr.URL())
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	resp, err := client.Post(tr.URL(), "application/json", strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "ping"}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 over TLS, got %d", resp.StatusCode)
+	}
+}
+
+func TestNew_InvalidOptions(t *testing.T) {
+	certFile, _, _ := writeTestCertificate(t)
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "Unknown kind", opts: Options{Kind: "grpc"}},
+		{name: "Certificate without key", opts: Options{Kind: KindHTTP, TLSCertFile: certFile}},
+		{name: "Missing certificate", opts: Options{Kind: KindHTTP, TLSCertFile: "/missing.pem", TLSKeyFile: "/missing.key"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.opts); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package pathpolicy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// ErrOutsideAllowedDirectories is returned when a path resolves outside every configured root
+var ErrOutsideAllowedDirectories = errors.New("path is outside the allowed directories")
+
+// ErrProtectedPath is returned when a path resolves to one the policy denies
+// regardless of the roots, such as the server's own config files
+var ErrProtectedPath = errors.New("path is protected")
+
+// Policy restricts filesystem access to a set of allowed root directories.
+// A Policy with no roots allows every path that isn't denied.
+type Policy struct {
+	roots  []string
+	denied []string
+}
+
+// New creates a Policy from the configured allowed directories.
+// Each root is expanded and canonicalized so symlinked roots match their targets.
+func New(allowedDirectories []string) (*Policy, error) {
+	policy := &Policy{}
+	for _, dir := range allowedDirectories {
+		if strings.TrimSpace(dir) == "" {
+			continue
+		}
+		root, err := canonicalize(dir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed directory %q: %w", dir, err)
+		}
+		policy.roots = append(policy.roots, root)
+	}
+	return policy, nil
+}
+
+// WithDenied returns a copy of the policy that also denies paths and anything
+// below them, even inside an allowed root. The paths don't need to exist.
+func (p *Policy) WithDenied(paths ...string) *Policy {
+	policy := &Policy{roots: p.roots, denied: append([]string(nil), p.denied...)}
+	for _, path := range paths {
+		denied, err := canonicalize(path)
+		if err != nil {
+			denied = filepath.Clean(path)
+		}
+		policy.denied = append(policy.denied, denied)
+	}
+	return policy
+}
+
+// Roots returns the canonical allowed directories
+func (p *Policy) Roots() []string {
+	return append([]string(nil), p.roots...)
+}
+
+// Resolve expands and canonicalizes path, following symlinks, and returns the
+// resulting absolute path if it lies within one of the allowed roots.
+// The path does not need to exist, which allows it to be used for write targets.
+func (p *Policy) Resolve(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("path cannot be empty")
+	}
+
+	resolved, err := canonicalize(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path %s: %w", path, err)
+	}
+
+	if p.denies(resolved) {
+		return "", fmt.Errorf("%w: %s", ErrProtectedPath, resolved)
+	}
+	if !p.Allows(resolved) {
+		return "", fmt.Errorf("%w: %s", ErrOutsideAllowedDirectories, resolved)
+	}
+	return resolved, nil
+}
+
+// Allows reports whether an already canonical path lies within one of the
+// allowed roots and isn't denied
+func (p *Policy) Allows(canonicalPath string) bool {
+	if p.denies(canonicalPath) {
+		return false
+	}
+	if len(p.roots) == 0 {
+		return true
+	}
+	for _, root := range p.roots {
+		if isWithin(root, canonicalPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// denies reports whether a canonical path is or lies below a denied path. The
+// comparison ignores case, since the default filesystems of macOS and Windows do.
+func (p *Policy) denies(canonicalPath string) bool {
+	for _, denied := range p.denied {
+		if isWithin(strings.ToLower(denied), strings.ToLower(canonicalPath)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Expand expands a leading ~ to the home directory and converts path to an absolute path
+func Expand(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		usr, err := user.Current()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(usr.HomeDir, path[1:])
+	}
+	return filepath.Abs(path)
+}
+
+// maxDanglingLinks bounds how many dangling symlinks canonicalize will follow
+const maxDanglingLinks = 40
+
+// canonicalize expands path and resolves symlinks in its longest existing prefix.
+// Components that do not exist yet are appended to the resolved prefix unchanged.
+func canonicalize(path string) (string, error) {
+	absPath, err := Expand(path)
+	if err != nil {
+		return "", err
+	}
+	return resolveExisting(absPath, 0)
+}
+
+// resolveExisting resolves symlinks in absPath, including dangling ones, so that a
+// later write through the path cannot land anywhere other than the returned location
+func resolveExisting(absPath string, depth int) (string, error) {
+	if depth > maxDanglingLinks {
+		return "", fmt.Errorf("too many levels of symbolic links: %s", absPath)
+	}
+
+	existing := absPath
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+
+		// A dangling symlink must be followed by hand, otherwise writing through
+		// it would create its target wherever it points
+		if info, lerr := os.Lstat(existing); lerr == nil && info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(existing)
+			if err != nil {
+				return "", err
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(existing), target)
+			}
+			return resolveExisting(filepath.Join(append([]string{target}, missing...)...), depth+1)
+		}
+
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return absPath, nil
+		}
+		missing = append([]string{filepath.Base(existing)}, missing...)
+		existing = parent
+	}
+}
+
+// isWithin reports whether path is root or a descendant of root
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
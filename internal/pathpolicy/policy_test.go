@@ -0,0 +1,156 @@
+package pathpolicy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupTree creates an allowed root and a sibling outside directory, both canonicalized
+func setupTree(t *testing.T) (allowed, outside string) {
+	t.Helper()
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to resolve temp dir: %v", err)
+	}
+	allowed = filepath.Join(base, "allowed")
+	outside = filepath.Join(base, "allowed-evil")
+	for _, dir := range []string{allowed, outside, filepath.Join(allowed, "sub")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(allowed, "file.txt"), []byte("ok"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	return allowed, outside
+}
+
+func TestPolicy_Resolve(t *testing.T) {
+	allowed, outside := setupTree(t)
+
+	// Symlinks inside the allowed root pointing outside of it
+	mustSymlink(t, filepath.Join(outside, "secret.txt"), filepath.Join(allowed, "link-to-secret"))
+	mustSymlink(t, outside, filepath.Join(allowed, "link-to-outside"))
+	mustSymlink(t, filepath.Join(outside, "new.txt"), filepath.Join(allowed, "dangling"))
+	mustSymlink(t, filepath.Join(allowed, "sub"), filepath.Join(allowed, "link-to-sub"))
+
+	policy, err := New([]string{allowed})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+		escape   bool
+	}{
+		{name: "Existing file", path: filepath.Join(allowed, "file.txt"), expected: filepath.Join(allowed, "file.txt")},
+		{name: "Root itself", path: allowed, expected: allowed},
+		{name: "New file", path: filepath.Join(allowed, "new.txt"), expected: filepath.Join(allowed, "new.txt")},
+		{name: "New nested file", path: filepath.Join(allowed, "a", "b", "c.txt"), expected: filepath.Join(allowed, "a", "b", "c.txt")},
+		{name: "Dot dot staying inside", path: filepath.Join(allowed, "sub", "..", "file.txt"), expected: filepath.Join(allowed, "file.txt")},
+		{name: "Symlink staying inside", path: filepath.Join(allowed, "link-to-sub", "x.txt"), expected: filepath.Join(allowed, "sub", "x.txt")},
+		{name: "Dot dot traversal", path: allowed + "/../allowed-evil/secret.txt", escape: true},
+		{name: "Sibling with shared prefix", path: filepath.Join(outside, "secret.txt"), escape: true},
+		{name: "Parent of root", path: filepath.Dir(allowed), escape: true},
+		{name: "Absolute system path", path: "/etc/passwd", escape: true},
+		{name: "Symlinked file", path: filepath.Join(allowed, "link-to-secret"), escape: true},
+		{name: "Symlinked directory", path: filepath.Join(allowed, "link-to-outside", "secret.txt"), escape: true},
+		{name: "New file through symlinked directory", path: filepath.Join(allowed, "link-to-outside", "new.txt"), escape: true},
+		{name: "Dangling symlink", path: filepath.Join(allowed, "dangling"), escape: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, err := policy.Resolve(tt.path)
+			if tt.escape {
+				if !errors.Is(err, ErrOutsideAllowedDirectories) {
+					t.Fatalf("Expected ErrOutsideAllowedDirectories for %s, got resolved=%q err=%v", tt.path, resolved, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if resolved != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, resolved)
+			}
+		})
+	}
+}
+
+func TestPolicy_SymlinkedRoot(t *testing.T) {
+	allowed, outside := setupTree(t)
+	linkedRoot := filepath.Join(outside, "root-link")
+	mustSymlink(t, allowed, linkedRoot)
+
+	policy, err := New([]string{linkedRoot})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if _, err := policy.Resolve(filepath.Join(allowed, "file.txt")); err != nil {
+		t.Errorf("Expected target of symlinked root to be allowed, got: %v", err)
+	}
+	if _, err := policy.Resolve(filepath.Join(outside, "secret.txt")); err == nil {
+		t.Error("Expected directory containing the root symlink to be rejected")
+	}
+}
+
+func TestPolicy_NoRootsAllowsAll(t *testing.T) {
+	for _, roots := range [][]string{nil, {}, {""}} {
+		policy, err := New(roots)
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		if _, err := policy.Resolve("/etc/passwd"); err != nil {
+			t.Errorf("Expected no restriction for roots %v, got: %v", roots, err)
+		}
+	}
+}
+
+func TestPolicy_WithDenied(t *testing.T) {
+	root := t.TempDir()
+	configPath := filepath.Join(root, "config", "config.json")
+	if err := os.Mkdir(filepath.Join(root, "config"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	mustSymlink(t, configPath, filepath.Join(root, "link.json"))
+
+	for _, roots := range [][]string{nil, {root}} {
+		base, _ := New(roots)
+		policy := base.WithDenied(configPath, configPath+".bak")
+
+		for _, path := range []string{configPath, configPath + ".bak", filepath.Join(root, "link.json"), filepath.Join(root, "CONFIG", "Config.json")} {
+			if _, err := policy.Resolve(path); !errors.Is(err, ErrProtectedPath) {
+				t.Errorf("Expected ErrProtectedPath for %s with roots %v, got: %v", path, roots, err)
+			}
+		}
+		if _, err := policy.Resolve(filepath.Join(root, "config", "other.json")); err != nil {
+			t.Errorf("Expected a sibling of a denied file to be allowed, got: %v", err)
+		}
+		if _, err := base.Resolve(configPath); err != nil {
+			t.Errorf("Expected the original policy to be unchanged, got: %v", err)
+		}
+	}
+}
+
+func TestPolicy_EmptyPath(t *testing.T) {
+	policy, _ := New(nil)
+	if _, err := policy.Resolve(""); err == nil {
+		t.Fatal("Expected error for empty path")
+	}
+}
+
+func mustSymlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatalf("failed to create symlink %s -> %s: %v", link, target, err)
+	}
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"context"
+	"sync"
+
+	"github.com/localrivet/gomcp/server"
+)
+
+// callContextKey is the server.Context metadata key holding the tool call's context
+const callContextKey = "golang-mcp-testing.callContext"
+
+// callContextMu guards the metadata of contexts shared between a handler's goroutines
+var callContextMu sync.Mutex
+
+// BeginCall attaches a context to ctx that is cancelled when the MCP client
+// cancels the tool call, or when the returned stop function is called at the
+// end of the call. Handlers reach it with CallContext. Registering replaces the
+// channel the server itself waits on, so the server answers a cancelled call
+// once its handler returns.
+func BeginCall(ctx *server.Context) (stop func()) {
+	callCtx, cancel := context.WithCancel(context.Background())
+	// Closed by the server on notifications/cancelled; never closed for
+	// contexts without a request ID, such as those of the call subcommand
+	cancelled := ctx.RegisterForCancellation()
+	go func() {
+		select {
+		case <-cancelled:
+			cancel()
+		case <-callCtx.Done():
+		}
+	}()
+
+	callContextMu.Lock()
+	defer callContextMu.Unlock()
+	if ctx.Metadata == nil {
+		ctx.Metadata = make(map[string]interface{})
+	}
+	ctx.Metadata[callContextKey] = callCtx
+	return cancel
+}
+
+// CallContext returns the context of the tool call ctx belongs to, for
+// cancelling outgoing requests and commands along with the call. Handlers
+// called without BeginCall, as in tests, get a context that is never cancelled.
+func CallContext(ctx *server.Context) context.Context {
+	callContextMu.Lock()
+	defer callContextMu.Unlock()
+	if callCtx, ok := ctx.Metadata[callContextKey].(context.Context); ok {
+		return callCtx
+	}
+	return context.Background()
+}
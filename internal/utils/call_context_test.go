@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/localrivet/gomcp/server"
+)
+
+func TestCallContext_CancelledByClient(t *testing.T) {
+	s := server.NewServer("test")
+	ctx, err := server.NewContext(context.Background(), []byte(`{"jsonrpc": "2.0", "id": 7, "method": "tools/call", "params": {"name": "wait"}}`), s.GetServer())
+	if err != nil {
+		t.Fatalf("failed to create context: %v", err)
+	}
+
+	started := make(chan struct{})
+	tool := NewTool("wait", "Wait for cancellation", func(ctx *server.Context, _ struct{}) (string, error) {
+		callCtx := CallContext(ctx)
+		close(started)
+		<-callCtx.Done()
+		return "", callCtx.Err()
+	})
+	handler := tool.handler.(HandlerFunc[struct{}, string])
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := handler(ctx, struct{}{})
+		errs <- err
+	}()
+	<-started
+	if err := s.GetServer().HandleCancelledNotification([]byte(`{"jsonrpc": "2.0", "method": "notifications/cancelled", "params": {"requestId": "7"}}`)); err != nil {
+		t.Fatalf("failed to cancel: %v", err)
+	}
+
+	select {
+	case err := <-errs:
+		if err != context.Canceled {
+			t.Errorf("Expected the call context to be cancelled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected cancelling the request to cancel the call context")
+	}
+}
+
+func TestCallContext_Direct(t *testing.T) {
+	if err := CallContext(CreateServerContext(discardLogger())).Err(); err != nil {
+		t.Errorf("Expected a context without a call never to be cancelled, got %v", err)
+	}
+
+	var callCtx context.Context
+	_, err := CallHandlerDirectly(discardLogger(), "capture", struct{}{}, func(ctx *server.Context, _ struct{}) (string, error) {
+		callCtx = CallContext(ctx)
+		return "", callCtx.Err()
+	})
+	if err != nil {
+		t.Fatalf("Expected the call context to be live during the call, got %v", err)
+	}
+	if callCtx.Err() == nil {
+		t.Error("Expected the call context to end with the call")
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"log/slog"
 
-	"github.com/davecgh/go-spew/spew"
 	"github.com/localrivet/gomcp/server"
 )
 
@@ -13,23 +12,24 @@ type HandlerFunc[T any, R any] func(*server.Context, T) (R, error)
 
 // CallHandlerDirectly is a generic utility function that can call any handler directly
 // with proper logging context. This replaces the package-specific helper functions.
-func CallHandlerDirectly[T any, R any](logger *slog.Logger, functionName string, args T, handler HandlerFunc[T, R]) error {
+func CallHandlerDirectly[T any, R any](logger *slog.Logger, functionName string, args T, handler HandlerFunc[T, R]) (R, error) {
 	serverContext := &server.Context{
 		Logger: logger,
 	}
 
+	stop := BeginCall(serverContext)
+	defer stop()
+
 	logger.Info("Calling handler directly", "function", functionName)
 
 	result, err := handler(serverContext, args)
 	if err != nil {
 		logger.Error("Handler call failed", "function", functionName, "error", err)
-		return fmt.Errorf("failed to call %s: %w", functionName, err)
+		return result, fmt.Errorf("failed to call %s: %w", functionName, err)
 	}
 
 	logger.Info("Handler call successful", "function", functionName)
-	spew.Dump(result)
-
-	return nil
+	return result, nil
 }
 
 // CreateServerContext creates a new server context with the provided logger
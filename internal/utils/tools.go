@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/localrivet/gomcp/server"
+)
+
+// Tool is a named handler that can be registered with the MCP server or
+// called directly, e.g. from the command line
+type Tool struct {
+	Name        string
+	Description string
+
+	handler any
+	call    func(logger *slog.Logger, args []byte) (any, error)
+}
+
+// NewTool wraps a handler so it can be both served and called with JSON arguments
+func NewTool[T any, R any](name, description string, handler HandlerFunc[T, R]) Tool {
+	return Tool{
+		Name:        name,
+		Description: description,
+		handler: HandlerFunc[T, R](func(ctx *server.Context, args T) (R, error) {
+			stop := BeginCall(ctx)
+			defer stop()
+			return handler(ctx, args)
+		}),
+		call: func(logger *slog.Logger, args []byte) (any, error) {
+			return CallHandlerJSON(logger, name, args, handler)
+		},
+	}
+}
+
+// Register adds the tool to the server
+func (t Tool) Register(s server.Server) {
+	s.Tool(t.Name, t.Description, t.handler)
+}
+
+// Call runs the tool with arguments given as a JSON object
+func (t Tool) Call(logger *slog.Logger, args []byte) (any, error) {
+	return t.call(logger, args)
+}
+
+// Tools is a set of tools, looked up by name
+type Tools []Tool
+
+// Find returns the tool called name
+func (tools Tools) Find(name string) (Tool, bool) {
+	for _, tool := range tools {
+		if tool.Name == name {
+			return tool, true
+		}
+	}
+	return Tool{}, false
+}
+
+// CallHandlerJSON decodes args, a JSON object, into the handler's argument type
+// and calls it with CallHandlerDirectly. Unknown fields are rejected so typos in
+// hand-written arguments don't go unnoticed.
+func CallHandlerJSON[T any, R any](logger *slog.Logger, functionName string, args []byte, handler HandlerFunc[T, R]) (R, error) {
+	var typedArgs T
+	if len(bytes.TrimSpace(args)) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(args))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&typedArgs); err != nil {
+			var zero R
+			return zero, fmt.Errorf("invalid arguments for %s: %w", functionName, err)
+		}
+		if decoder.More() {
+			var zero R
+			return zero, fmt.Errorf("invalid arguments for %s: expected a single JSON object", functionName)
+		}
+	}
+	return CallHandlerDirectly(logger, functionName, typedArgs, handler)
+}
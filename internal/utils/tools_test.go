@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/localrivet/gomcp/server"
+)
+
+type echoArgs struct {
+	Text  string `json:"text"`
+	Times int    `json:"times,omitempty"`
+}
+
+func echo(ctx *server.Context, args echoArgs) (string, error) {
+	if args.Text == "" {
+		return "", errors.New("text cannot be empty")
+	}
+	return strings.Repeat(args.Text, max(args.Times, 1)), nil
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestCallHandlerJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     string
+		expected string
+		err      string
+	}{
+		{name: "Decodes arguments", args: `{"text": "ab", "times": 2}`, expected: "abab"},
+		{name: "Handler error", args: ``, err: "failed to call echo: text cannot be empty"},
+		{name: "Unknown field", args: `{"txt": "ab"}`, err: `unknown field "txt"`},
+		{name: "Wrong type", args: `{"text": 1}`, err: "invalid arguments for echo"},
+		{name: "Trailing data", args: `{"text": "a"} {}`, err: "expected a single JSON object"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := CallHandlerJSON(discardLogger(), "echo", []byte(tt.args), echo)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Expected error containing %q, got: %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil || result != tt.expected {
+				t.Errorf("Expected %q, got %q and error %v", tt.expected, result, err)
+			}
+		})
+	}
+}
+
+func TestTools_FindAndCall(t *testing.T) {
+	tools := Tools{NewTool("echo", "Echo text.", echo)}
+
+	tool, ok := tools.Find("echo")
+	if !ok {
+		t.Fatal("Expected to find the echo tool")
+	}
+	result, err := tool.Call(discardLogger(), []byte(`{"text": "hi"}`))
+	if err != nil || result != "hi" {
+		t.Errorf("Expected hi, got %v and error %v", result, err)
+	}
+
+	if _, ok := tools.Find("missing"); ok {
+		t.Error("Expected no tool called missing")
+	}
+}
@@ -1,48 +1,371 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"log/slog"
+	"net"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
 
+	"golang-mcp-testing/internal/logging"
+	"golang-mcp-testing/internal/nettransport"
 	"golang-mcp-testing/internal/utils"
 	"golang-mcp-testing/tools/config"
-	"golang-mcp-testing/tools/dropbox"
 	"golang-mcp-testing/tools/terminal"
 
 	"github.com/localrivet/gomcp/server"
+	"github.com/localrivet/gomcp/transport/stdio"
 )
 
+const usage = `Usage:
+  golang-mcp-testing [serve] [flags]         Run the MCP server, on stdio by default.
+                                             See serve -h for network transports.
+  golang-mcp-testing list-tools              List the available tools
+  golang-mcp-testing call <tool> [--json '{...}']
+                                             Call a tool directly and print its result as JSON.
+                                             --json - reads the arguments from stdin.
+  golang-mcp-testing config-schema           Print the JSON Schema of config files
+
+The configuration is read from, in increasing precedence: built-in defaults,
+/etc/golang-mcp-testing/config.json, config/config.json next to the executable,
+$XDG_CONFIG_HOME/golang-mcp-testing/config.json (~/.config when unset, also
+on macOS), the file given with --config, and MCP_* environment variables such
+as MCP_DEFAULT_SHELL or MCP_ALLOWED_DIRECTORIES (comma separated). get_config
+shows where each setting came from.
+`
+
 func main() {
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	}))
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	var err error
+	switch command {
+	case "serve":
+		err = runServe(args)
+	case "list-tools":
+		err = runListTools(args)
+	case "call":
+		err = runCall(args)
+	case "config-schema":
+		os.Stdout.Write(config.Schema)
+	case "help":
+		fmt.Fprint(os.Stderr, usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// logLevel is the level of the logger built by setupLogger. Serving applies
+// logLevel changes in the config file to it without a restart.
+var logLevel = new(slog.LevelVar)
+
+// setupLogger loads the config and builds the logger it describes. Logs never go
+// to stdout, which carries protocol frames when serving and results when calling.
+func setupLogger(extraSecrets ...string) (*slog.Logger, io.Closer, error) {
+	secrets := append([]string{os.Getenv("DROPBOX_API_KEY"), os.Getenv("DROPBOX_APP_SECRET"), os.Getenv("DROPBOX_REFRESH_TOKEN")}, extraSecrets...)
+	bootstrapLogger, _, err := logging.New(logging.Options{Secrets: secrets})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create logger: %w", err)
+	}
+	cfg, err := config.Load(utils.CreateServerContext(bootstrapLogger))
+	if err != nil {
+		bootstrapLogger.Warn("Failed to load config, using default logging", "error", err)
+		cfg = &config.ServerConfig{}
+	}
+	opts := loggingOptions(cfg, secrets)
+	opts.LevelVar = logLevel
+	logger, closer, err := logging.New(opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid logging config: %w", err)
+	}
+	return logger, closer, nil
+}
+
+// runServe runs the MCP server on stdio, or on the network with --transport,
+// until stdin closes or a signal arrives
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	transportKind := flags.String("transport", "stdio", "Transport: stdio, http (streamable HTTP and SSE) or websocket")
+	listen := flags.String("listen", "127.0.0.1:8080", "Address to listen on for http and websocket")
+	authTokenFile := flags.String("auth-token-file", "", "File holding the bearer token clients must send. Defaults to $MCP_AUTH_TOKEN")
+	tlsCert := flags.String("tls-cert", "", "TLS certificate file, enables HTTPS/WSS together with --tls-key")
+	tlsKey := flags.String("tls-key", "", "TLS private key file")
+	allowedOrigins := flags.String("allowed-origins", "", "Comma separated browser origins allowed besides localhost, e.g. https://app.example.com")
+	allowUnauthenticated := flags.Bool("allow-unauthenticated", false, "Allow listening on a non-loopback address without a bearer token")
+	configFile := flags.String("config", "", "Config file overriding the system and user config files")
+	flags.Parse(args)
+	if err := setConfigFile(*configFile); err != nil {
+		return err
+	}
+
+	authToken := os.Getenv("MCP_AUTH_TOKEN")
+	if *authTokenFile != "" {
+		content, err := os.ReadFile(*authTokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read auth token: %w", err)
+		}
+		authToken = strings.TrimSpace(string(content))
+	}
+
+	logger, logCloser, err := setupLogger(authToken)
+	if err != nil {
+		return err
+	}
+	defer logCloser.Close()
+
+	if err := watchConfig(logger); err != nil {
+		logger.Warn("Config changes will not be picked up until restart", "error", err)
+	}
 
 	s := server.NewServer("ColeMCPServer",
 		server.WithLogger(logger),
-	).AsStdio()
+	)
+	// Closed when the client closes stdin, the usual way a stdio server is shut down
+	var stdinClosed chan struct{}
+	switch *transportKind {
+	case "stdio":
+		// Build the transport rather than using AsStdio, whose process monitor
+		// exits the process on disconnect before process sessions are cleaned up
+		stdinClosed = make(chan struct{})
+		stdioTransport := stdio.NewTransportWithIO(&eofNotifier{r: os.Stdin, done: stdinClosed}, os.Stdout)
+		stdioTransport.SetLogger(logger)
+		stdioTransport.DisableProcessMonitoring()
+		s.GetServer().SetTransport(stdioTransport)
+
+		// The transport holds on to the real stdout. Anything else printing to
+		// os.Stdout from here on ends up on stderr instead of between protocol frames.
+		os.Stdout = os.Stderr
+	default:
+		if authToken == "" && !isLoopback(*listen) && !*allowUnauthenticated {
+			return fmt.Errorf("refusing to serve tools such as terminal_exec on %s without a bearer token: set $MCP_AUTH_TOKEN or --auth-token-file, or pass --allow-unauthenticated", *listen)
+		}
+		netTransport, err := nettransport.New(nettransport.Options{
+			Kind:           *transportKind,
+			Addr:           *listen,
+			AuthToken:      authToken,
+			TLSCertFile:    *tlsCert,
+			TLSKeyFile:     *tlsKey,
+			AllowedOrigins: splitList(*allowedOrigins),
+		})
+		if err != nil {
+			return err
+		}
+		netTransport.SetLogger(logger)
+		s.GetServer().SetTransport(netTransport)
+	}
+
+	for _, tool := range newTools(logger) {
+		tool.Register(s)
+	}
+
+	// Make sure process sessions don't outlive the server
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	runErr := make(chan error, 1)
+	go func() { runErr <- s.Run() }()
+
+	select {
+	case sig := <-signals:
+		logger.Info("Received signal, shutting down", "signal", sig.String())
+	case <-stdinClosed:
+		logger.Info("Client closed stdin, shutting down")
+	case err = <-runErr:
+		err = fmt.Errorf("server exited with error: %w", err)
+	}
+	terminal.CloseAllProcesses()
+	if stopErr := s.Shutdown(); stopErr != nil {
+		logger.Warn("Failed to stop the transport", "error", stopErr)
+	}
+	return err
+}
+
+// eofNotifier reads from r and closes done once r returns EOF or an error
+type eofNotifier struct {
+	r    io.Reader
+	done chan struct{}
+	once sync.Once
+}
+
+func (n *eofNotifier) Read(p []byte) (int, error) {
+	count, err := n.r.Read(p)
+	if err != nil {
+		n.once.Do(func() { close(n.done) })
+	}
+	return count, err
+}
 
-	s.Tool("get_config", "Get the complete server configuration as JSON.",
-		config.HandleGetConfig)
+// watchConfig reloads the config file when it changes. Tools read the active
+// config on every call. Of the logging settings only the level is applied
+// live, the others take effect on restart.
+func watchConfig(logger *slog.Logger) error {
+	ctx := utils.CreateServerContext(logger)
+	_, err := config.Subscribe(ctx, func(cfg *config.ServerConfig) {
+		name := ""
+		if cfg.LogLevel != nil {
+			name = *cfg.LogLevel
+		}
+		level, err := logging.ParseLevel(name)
+		if err != nil {
+			logger.Warn("Ignoring logLevel from config", "error", err)
+			return
+		}
+		logLevel.Set(level)
+	})
+	if err != nil {
+		return err
+	}
+	return config.Watch(ctx, config.DefaultPollInterval, nil)
+}
 
-	s.Tool("dropbox_list_dropbox_folder", "List all dropbox folders within a given path.",
-		dropbox.HandleListDropboxFolder)
+// setConfigFile makes the file given with --config the highest precedence config
+// file. It has to exist so that a typo doesn't silently fall back to other files.
+func setConfigFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("invalid --config: %w", err)
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("invalid --config: %w", err)
+	}
+	config.SetConfigFile(absPath)
+	return nil
+}
 
-	s.Tool("dropbox_files_download", "Download a file at a provided path.",
-		dropbox.HandleFilesDownload)
+// isLoopback reports whether addr only accepts connections from this machine
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// splitList splits a comma separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// runListTools prints the name and description of every tool
+func runListTools(args []string) error {
+	flags := flag.NewFlagSet("list-tools", flag.ExitOnError)
+	flags.Parse(args)
 
-	s.Tool("terminal_write_file", "Write a file to the filesystem.",
-		terminal.HandleWriteFile)
+	for _, tool := range newTools(slog.New(slog.NewTextHandler(io.Discard, nil))) {
+		fmt.Printf("%s\n    %s\n", tool.Name, tool.Description)
+	}
+	return nil
+}
+
+// runCall calls a single tool with JSON arguments and prints its result
+func runCall(args []string) error {
+	flags := flag.NewFlagSet("call", flag.ExitOnError)
+	jsonArgs := flags.String("json", "{}", "The tool arguments as a JSON object, or - to read them from stdin")
+	configFile := flags.String("config", "", "Config file overriding the system and user config files")
 
-	// for testing - using the new generic handler utility
-	err := utils.CallHandlerDirectly(logger, "HandleWriteFile",
-		terminal.WriteFileArgs{Path: "/Users/bittelc/Desktop/file.txt", Content: "this content"},
-		terminal.HandleWriteFile)
+	// Accept the tool name before or after the flags
+	var name string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	flags.Parse(args)
+	if name == "" {
+		name = flags.Arg(0)
+	}
+	if name == "" {
+		return fmt.Errorf("call needs a tool name, see list-tools\n\n%s", usage)
+	}
+	if err := setConfigFile(*configFile); err != nil {
+		return err
+	}
+
+	toolArgs := []byte(*jsonArgs)
+	if *jsonArgs == "-" {
+		var err error
+		if toolArgs, err = io.ReadAll(os.Stdin); err != nil {
+			return fmt.Errorf("failed to read arguments from stdin: %w", err)
+		}
+	}
+
+	logger, logCloser, err := setupLogger()
+	if err != nil {
+		return err
+	}
+	defer logCloser.Close()
+	defer terminal.CloseAllProcesses()
+
+	tool, ok := newTools(logger).Find(name)
+	if !ok {
+		return fmt.Errorf("unknown tool %q, see list-tools", name)
+	}
+
+	result, err := tool.Call(logger, toolArgs)
 	if err != nil {
-		log.Fatalf("direct call to handler failed: %v", err)
+		return err
 	}
 
-	if err := s.Run(); err != nil {
-		log.Fatalf("Server exited with error: %v", err)
+	// Tools such as get_config already return formatted text
+	if text, ok := result.(string); ok {
+		fmt.Println(text)
+		return nil
+	}
+	output, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+	fmt.Println(string(output))
+	return nil
+}
+
+// loggingOptions builds the logger options from the config, defaulting to JSON
+// logs at info level on stderr
+func loggingOptions(cfg *config.ServerConfig, secrets []string) logging.Options {
+	opts := logging.Options{
+		MaxSizeMB:      logging.DefaultMaxSizeMB,
+		MaxBackups:     logging.DefaultMaxBackups,
+		Secrets:        secrets,
+		RedactPatterns: cfg.LogRedactPatterns,
+	}
+	if cfg.LogLevel != nil {
+		opts.Level = *cfg.LogLevel
+	}
+	if cfg.LogFormat != nil {
+		opts.Format = *cfg.LogFormat
+	}
+	if cfg.LogFile != nil {
+		opts.File = *cfg.LogFile
+	}
+	if cfg.LogMaxSizeMB != nil {
+		opts.MaxSizeMB = *cfg.LogMaxSizeMB
+	}
+	if cfg.LogMaxBackups != nil {
+		opts.MaxBackups = *cfg.LogMaxBackups
 	}
+	return opts
 }
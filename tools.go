@@ -0,0 +1,70 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+
+	"golang-mcp-testing/internal/utils"
+	"golang-mcp-testing/tools/config"
+	"golang-mcp-testing/tools/dropbox"
+	"golang-mcp-testing/tools/terminal"
+)
+
+// newTools returns every tool the server offers. The same handlers back both the
+// MCP server and the call subcommand.
+func newTools(logger *slog.Logger) utils.Tools {
+	dropboxOpts := []dropbox.ClientOption{dropbox.WithLogger(logger)}
+	if refreshToken := os.Getenv("DROPBOX_REFRESH_TOKEN"); refreshToken != "" {
+		dropboxOpts = append(dropboxOpts, dropbox.WithRefreshToken(os.Getenv("DROPBOX_APP_KEY"), os.Getenv("DROPBOX_APP_SECRET"), refreshToken))
+	}
+	dropboxClient := dropbox.NewClient(os.Getenv("DROPBOX_API_KEY"), dropboxOpts...)
+
+	return utils.Tools{
+		utils.NewTool("get_config", "Get the effective server configuration as JSON, with the layer (defaults, system, executable, user, flag or env) each setting came from.",
+			config.HandleGetConfig),
+		utils.NewTool("set_config_value", "Change one server configuration value by JSON pointer. The result is validated, the previous file is backed up, and changes that loosen security are refused unless the server allows them.",
+			config.HandleSetConfigValue),
+		utils.NewTool("validate_config", "Check the config files and MCP_* environment overrides against the config schema, reporting every problem with its file, line and column.",
+			config.HandleValidateConfig),
+		utils.NewTool("dropbox_list_dropbox_folder", "List all dropbox files and folders within a given path with their metadata, following pagination. Returns complete=false and a cursor when max_entries stops the listing early.",
+			dropboxClient.HandleListDropboxFolder),
+		utils.NewTool("dropbox_search", "Search Dropbox file names and contents, optionally scoped to a path and filtered by extension or category. Returns matches with highlighted snippets; complete=false and a cursor when max_results stops the search early.",
+			dropboxClient.HandleSearch),
+		utils.NewTool("dropbox_files_download", "Download a file at a provided path to a local destination, with a policy for existing files.",
+			dropboxClient.HandleFilesDownload),
+		utils.NewTool("dropbox_files_upload", "Upload a local file to a Dropbox path, with add, overwrite or update-by-rev write modes.",
+			dropboxClient.HandleFilesUpload),
+		utils.NewTool("dropbox_create_folder", "Create a Dropbox folder. With dry_run, check the paths and report what would change without changing anything.",
+			dropboxClient.HandleCreateFolder),
+		utils.NewTool("dropbox_move", "Move a Dropbox file or folder to a new path. With dry_run, check the paths and report what would change without changing anything.",
+			dropboxClient.HandleMove),
+		utils.NewTool("dropbox_copy", "Copy a Dropbox file or folder to a new path. With dry_run, check the paths and report what would change without changing anything.",
+			dropboxClient.HandleCopy),
+		utils.NewTool("dropbox_delete", "Delete a Dropbox file or folder, including folder contents. With dry_run, check the paths and report what would change without changing anything.",
+			dropboxClient.HandleDelete),
+		utils.NewTool("dropbox_create_folder_batch", "Create several Dropbox folders in one batch job, reporting the outcome of each.",
+			dropboxClient.HandleCreateFolderBatch),
+		utils.NewTool("dropbox_move_batch", "Move several Dropbox files or folders in one batch job, reporting the outcome of each.",
+			dropboxClient.HandleMoveBatch),
+		utils.NewTool("dropbox_copy_batch", "Copy several Dropbox files or folders in one batch job, reporting the outcome of each.",
+			dropboxClient.HandleCopyBatch),
+		utils.NewTool("dropbox_delete_batch", "Delete several Dropbox files or folders in one batch job, reporting the outcome of each.",
+			dropboxClient.HandleDeleteBatch),
+		utils.NewTool("terminal_write_file", "Write a file to the filesystem.",
+			terminal.HandleWriteFile),
+		utils.NewTool("terminal_cat", "Read the content of the file at the provided path. Supports byte ranges (offset/limit), line ranges (start_line/line_count) and paging via next_cursor.",
+			terminal.HandleCat),
+		utils.NewTool("terminal_exec", "Run a command line through the configured shell and return its exit code, stdout and stderr. Commands in BlockedCommands are rejected.",
+			terminal.HandleExec),
+		utils.NewTool("terminal_process_start", "Start a long-running process (dev server, REPL) through the configured shell and return its session ID.",
+			terminal.HandleProcessStart),
+		utils.NewTool("terminal_process_read", "Read output a process session has written since the given stdout/stderr offsets.",
+			terminal.HandleProcessRead),
+		utils.NewTool("terminal_process_write", "Write input to the stdin of a process session.",
+			terminal.HandleProcessWrite),
+		utils.NewTool("terminal_process_list", "List all process sessions and whether they are still running. Exited sessions are kept for 10 minutes.",
+			terminal.HandleProcessList),
+		utils.NewTool("terminal_process_kill", "Terminate a process session.",
+			terminal.HandleProcessKill),
+	}
+}
@@ -1,11 +1,14 @@
 package config
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath" // Keep for potential DefaultShell logic later
+	"path/filepath"
+	"strings"
 	"sync"
+	"time"
+
+	"golang-mcp-testing/internal/pathpolicy"
 
 	"github.com/localrivet/gomcp/server"
 )
@@ -20,66 +23,115 @@ type ServerConfig struct {
 	DefaultShell       *string  `json:"defaultShell,omitempty"`       // Pointer to distinguish between empty string and not set
 	AllowedDirectories []string `json:"allowedDirectories,omitempty"` // Use omitempty; nil slice means not set, empty slice means allow all
 	TelemetryEnabled   *bool    `json:"telemetryEnabled,omitempty"`   // Pointer for explicit true/false/not set
+	DownloadDirectory  *string  `json:"downloadDirectory,omitempty"`  // Default destination for Dropbox downloads
+	LogRedactPatterns  []string `json:"logRedactPatterns,omitempty"`  // Extra regular expressions scrubbed from log output
+	LogLevel           *string  `json:"logLevel,omitempty"`           // debug, info (default), warn or error
+	LogFormat          *string  `json:"logFormat,omitempty"`          // json (default) or text
+	LogFile            *string  `json:"logFile,omitempty"`            // Rotating log file; logs go to stderr when not set
+	LogMaxSizeMB       *int     `json:"logMaxSizeMB,omitempty"`       // Size at which the log file is rotated, default 10
+	LogMaxBackups      *int     `json:"logMaxBackups,omitempty"`      // Rotated log files to keep, default 3
 }
 
-var currentConfig *ServerConfig
-var loadConfigOnce sync.Once
-var loadConfigErr error
+var defaultStore *Store
+var defaultStoreOnce sync.Once
+var configFile string
 
 // For testing purposes
 var testConfigDir string
 
-// loadConfig loads the configuration from file or creates default. Used internally.
-func loadConfig(ctx *server.Context) (*ServerConfig, error) {
-	loadConfigOnce.Do(func() {
-		configPath, err := getConfigPath()
-		if err != nil {
-			loadConfigErr = fmt.Errorf("failed to get config path: %w", err)
-			return
+// IsolateForTesting points the system, executable and user config layers at
+// files under dir, which need not exist, unsets every MCP_* environment variable
+// and forgets any loaded configuration, so the next use starts from the defaults.
+// Tests of packages that use the config call it from TestMain to be independent
+// of the machine they run on.
+func IsolateForTesting(dir string) {
+	testConfigDir, systemConfigDir = filepath.Join(dir, "exe"), filepath.Join(dir, "etc")
+	os.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+	for _, env := range os.Environ() {
+		if name, _, _ := strings.Cut(env, "="); strings.HasPrefix(name, envPrefix) {
+			os.Unsetenv(name)
 		}
+	}
+	defaultStore, defaultStoreOnce, configFile = nil, sync.Once{}, ""
+}
 
-		content, err := os.ReadFile(configPath)
-		if err != nil {
-			if os.IsNotExist(err) {
-				ctx.Logger.Info("Config file not found at %s, creating default for internal use", "configPath", configPath)
-				cfg := ServerConfig{
-					BlockedCommands: defaultBlockedCommands, // Use var from get_config.go
-				}
-				// Attempt to write default file, but proceed even if write fails
-				configJson, marshalErr := json.MarshalIndent(cfg, "", "  ")
-				if marshalErr == nil {
-					_ = os.MkdirAll(filepath.Dir(configPath), 0755) // Ignore error
-					_ = os.WriteFile(configPath, configJson, 0644)  // Ignore error
-				} else {
-					ctx.Logger.Info("Error marshalling default config for write", "error", marshalErr)
-				}
-				currentConfig = &cfg // Use in-memory default
-			} else {
-				loadConfigErr = fmt.Errorf("error reading config file %s: %w", configPath, err)
-				return
-			}
-		} else {
-			var cfg ServerConfig
-			if err := json.Unmarshal(content, &cfg); err != nil {
-				loadConfigErr = fmt.Errorf("error unmarshalling config file %s: %w", configPath, err)
-				return
-			}
-			// Ensure BlockedCommands is not nil if file exists but key is missing
-			if cfg.BlockedCommands == nil {
-				cfg.BlockedCommands = []string{} // Initialize to empty slice
-			}
-			currentConfig = &cfg
-		}
+// SetConfigFile adds path, e.g. from a --config flag, as the highest precedence
+// config file, below environment overrides. set_config_value writes to it. Must
+// be called before the config is first used.
+func SetConfigFile(path string) {
+	configFile = path
+}
+
+// getStore returns the store of the layered configuration, loading it on first use
+func getStore(ctx *server.Context) (*Store, error) {
+	defaultStoreOnce.Do(func() {
+		defaultStore = NewLayeredStore(defaultLayers(configFile), ctx.Logger)
 	})
-	return currentConfig, loadConfigErr
+	return defaultStore, nil
+}
+
+// Default returns the built-in configuration the config files are layered on
+func Default() *ServerConfig {
+	cfg := defaultConfig()
+	return &cfg
+}
+
+// Load provides access to the active configuration, which changes when a config
+// file is edited while Watch is running. Callers must not modify it.
+func Load(ctx *server.Context) (*ServerConfig, error) {
+	store, err := getStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return store.Current()
+}
+
+// Save writes cfg to the highest precedence config file and activates it, see
+// Store.Save. Changes that loosen security are refused unless allowUnsafe is set.
+func Save(ctx *server.Context, cfg *ServerConfig, allowUnsafe bool) (loosened []string, backupPath string, err error) {
+	store, err := getStore(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	return store.Save(cfg, allowUnsafe)
+}
+
+// GetPathPolicy returns the filesystem access policy built from the configured AllowedDirectories.
+// Tools that read or write local files must resolve paths through it.
+func GetPathPolicy(ctx *server.Context) (*pathpolicy.Policy, error) {
+	store, err := getStore(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	policy, err := store.PathPolicy()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	return policy, nil
 }
 
-// GetCurrentConfig provides access to the loaded configuration.
-func GetCurrentConfig(ctx *server.Context) (*ServerConfig, error) {
-	return loadConfig(ctx)
+// Subscribe calls fn with every configuration activated by a reload, see Store.Subscribe
+func Subscribe(ctx *server.Context, fn func(*ServerConfig)) (unsubscribe func(), err error) {
+	store, err := getStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return store.Subscribe(fn), nil
+}
+
+// Watch reloads the config file in the background whenever it changes, logging
+// to ctx.Logger, until stop is closed
+func Watch(ctx *server.Context, interval time.Duration, stop <-chan struct{}) error {
+	store, err := getStore(ctx)
+	if err != nil {
+		return err
+	}
+	store.setLogger(ctx.Logger)
+	go store.Watch(interval, stop)
+	return nil
 }
 
-// getConfigPath returns the absolute path to the configuration file.
+// getConfigPath returns the absolute path to the configuration file next to the executable.
 func getConfigPath() (string, error) {
 	if testConfigDir != "" {
 		return filepath.Join(testConfigDir, configFileName), nil
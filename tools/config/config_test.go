@@ -0,0 +1,152 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/localrivet/gomcp/server"
+)
+
+// TestMain keeps the tests independent of the config files and MCP_* variables
+// of the machine they run on
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "config")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	IsolateForTesting(dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// useTestConfig points the package-level store at temp directories: testConfigDir
+// for the file next to the executable, and empty system and user config
+// directories. It returns a context and the path of the file next to the executable.
+func useTestConfig(t *testing.T, content string) (*server.Context, string) {
+	t.Helper()
+	dir := t.TempDir()
+	testConfigDir, systemConfigDir = filepath.Join(dir, "exe"), filepath.Join(dir, "etc")
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+	resetStore := func() {
+		defaultStore, defaultStoreOnce, configFile = nil, sync.Once{}, ""
+	}
+	resetStore()
+	t.Cleanup(func() {
+		testConfigDir, systemConfigDir = "", filepath.Join("/etc", appName)
+		resetStore()
+	})
+
+	path := filepath.Join(testConfigDir, configFileName)
+	if content != "" {
+		os.MkdirAll(testConfigDir, 0755)
+		writeConfig(t, path, content)
+	}
+	return &server.Context{Logger: discardLogger()}, path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	ctx, path := useTestConfig(t, "")
+
+	cfg, err := Load(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Errorf("Expected the defaults, got %+v", cfg)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected loading not to write a config file")
+	}
+
+	// Default returns a copy
+	Default().BlockedCommands[0] = "changed"
+	if Default().BlockedCommands[0] == "changed" {
+		t.Error("Expected Default to return a fresh copy")
+	}
+}
+
+func TestLoad_ExecutableDirConfig(t *testing.T) {
+	allowed := t.TempDir()
+	ctx, _ := useTestConfig(t, `{"blockedCommands": ["rm"], "allowedDirectories": ["`+allowed+`"]}`)
+
+	cfg, err := Load(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(cfg.BlockedCommands, []string{"rm"}) {
+		t.Errorf("Expected the file's blocked commands, got %v", cfg.BlockedCommands)
+	}
+
+	policy, err := GetPathPolicy(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	canonical, _ := filepath.EvalSymlinks(allowed)
+	if !reflect.DeepEqual(policy.Roots(), []string{canonical}) {
+		t.Errorf("Expected the allowed directory as the only root, got %v", policy.Roots())
+	}
+}
+
+func TestLoad_InvalidConfig(t *testing.T) {
+	ctx, _ := useTestConfig(t, `{"blockedCommand": []}`)
+
+	if _, err := Load(ctx); err == nil {
+		t.Error("Expected an error")
+	}
+	if _, err := GetPathPolicy(ctx); err == nil {
+		t.Error("Expected an error")
+	}
+}
+
+func TestSave(t *testing.T) {
+	ctx, exePath := useTestConfig(t, `{"blockedCommands": ["rm", "sudo"]}`)
+	userPath := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), appName, configFileName)
+
+	cfg := *Default()
+	cfg.BlockedCommands = []string{"rm", "sudo", "dd"}
+	shell := "/bin/sh"
+	cfg.DefaultShell = &shell
+
+	// Setting a shell loosens security
+	if _, _, err := Save(ctx, &cfg, false); err == nil {
+		t.Fatal("Expected an error")
+	}
+	cfg.DefaultShell = nil
+
+	loosened, backupPath, err := Save(ctx, &cfg, false)
+	if err != nil || len(loosened) != 0 || backupPath != "" {
+		t.Fatalf("Expected a clean save, got %v, %q and error %v", loosened, backupPath, err)
+	}
+	if loaded, _ := Load(ctx); !reflect.DeepEqual(loaded.BlockedCommands, cfg.BlockedCommands) {
+		t.Errorf("Expected the saved config to be active, got %v", loaded.BlockedCommands)
+	}
+
+	// The user file is written, the file next to the executable is left alone
+	var saved ServerConfig
+	content, _ := os.ReadFile(userPath)
+	if err := json.Unmarshal(content, &saved); err != nil || !reflect.DeepEqual(saved.BlockedCommands, cfg.BlockedCommands) {
+		t.Errorf("Expected the config in the user file, got %s", content)
+	}
+	if content, _ := os.ReadFile(exePath); string(content) != `{"blockedCommands": ["rm", "sudo"]}` {
+		t.Errorf("Expected the executable's config to be unchanged, got %s", content)
+	}
+}
+
+func TestSetConfigFile(t *testing.T) {
+	ctx, _ := useTestConfig(t, `{"logLevel": "warn"}`)
+	flagPath := filepath.Join(t.TempDir(), "custom.json")
+	writeConfig(t, flagPath, `{"logLevel": "debug"}`)
+	SetConfigFile(flagPath)
+
+	cfg, err := Load(ctx)
+	if err != nil || *cfg.LogLevel != "debug" {
+		t.Errorf("Expected the --config file to take precedence, got %v and error %v", cfg, err)
+	}
+}
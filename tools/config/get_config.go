@@ -2,8 +2,6 @@ package config
 
 import (
 	"encoding/json"
-	"os"
-	"path/filepath"
 
 	"github.com/localrivet/gomcp/server"
 )
@@ -28,54 +26,20 @@ var defaultBlockedCommands = []string{
 type GetConfigArgs struct{}
 
 // HandleGetConfig implements the logic for the get_config tool using the new API.
+// It reports the active configuration, the same one the other tools use, along
+// with the layer each setting came from and the config files considered.
 func HandleGetConfig(ctx *server.Context, args GetConfigArgs) (string, error) {
 	ctx.Logger.Info("Handling get_config tool call")
 
-	configPath, err := getConfigPath()
+	store, err := getStore(ctx)
 	if err != nil {
-		ctx.Logger.Info("Error getting config path", "error", err)
-		return "Error getting configuration file path", err
+		ctx.Logger.Info("Error loading config", "error", err)
+		return "Error loading configuration", err
 	}
-
-	// Read the config file
-	content, err := os.ReadFile(configPath)
+	config, err := store.Effective()
 	if err != nil {
-		// If the file doesn't exist, create it with default BlockedCommands and return that
-		if os.IsNotExist(err) {
-			ctx.Logger.Info("Config file not found at %s, creating with default BlockedCommands", "configPath", configPath)
-			// Only set BlockedCommands in the default config written to file
-			defaultConfig := ServerConfig{
-				BlockedCommands: defaultBlockedCommands,
-				// DefaultShell, AllowedDirectories, TelemetryEnabled remain nil/zero
-			}
-			configJson, marshalErr := json.MarshalIndent(defaultConfig, "", "  ")
-			if marshalErr != nil {
-				ctx.Logger.Info("Error marshalling default config", "error", marshalErr)
-				return "Error generating default config output", marshalErr
-			}
-
-			// Create the config directory if it doesn't exist
-			configDirPath := filepath.Join(filepath.Dir(configPath))
-			if err := os.MkdirAll(configDirPath, 0755); err != nil {
-				ctx.Logger.Info("Error creating config directory", "configDirPath", configDirPath, "error", err)
-				return "Error creating configuration directory", err
-			}
-
-			if writeErr := os.WriteFile(configPath, configJson, 0644); writeErr != nil {
-				ctx.Logger.Info("Error writing default config file", "configPath", configPath, "error", writeErr)
-				return "Error writing default configuration file", writeErr
-			}
-
-			return string(configJson), nil
-		}
-		ctx.Logger.Info("Error reading config file", "configPath", configPath, "error", err)
-		return "Error reading configuration file", err
-	}
-
-	var config ServerConfig
-	if err := json.Unmarshal(content, &config); err != nil {
-		ctx.Logger.Info("Error unmarshalling config file", "configPath", configPath, "error", err)
-		return "Error parsing configuration file", err
+		ctx.Logger.Info("Error loading config", "error", err)
+		return "Error loading configuration", err
 	}
 
 	configJson, err := json.MarshalIndent(config, "", "  ")
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestHandleGetConfig(t *testing.T) {
+	ctx, path := useTestConfig(t, `{"blockedCommands": ["rm"], "logFormat": "text"}`)
+	t.Setenv("MCP_LOG_LEVEL", "debug")
+
+	output, err := HandleGetConfig(ctx, GetConfigArgs{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var effective EffectiveConfig
+	if err := json.Unmarshal([]byte(output), &effective); err != nil {
+		t.Fatalf("Expected JSON output, got %s", output)
+	}
+	if !reflect.DeepEqual(effective.Config.BlockedCommands, []string{"rm"}) || *effective.Config.LogLevel != "debug" {
+		t.Errorf("Expected the effective config, got %+v", effective.Config)
+	}
+	wantSources := map[string]string{"blockedCommands": LayerExecutable, "logFormat": LayerExecutable, "logLevel": "env MCP_LOG_LEVEL"}
+	if !reflect.DeepEqual(effective.Sources, wantSources) {
+		t.Errorf("Expected sources %v, got %v", wantSources, effective.Sources)
+	}
+	if len(effective.Layers) != 3 || effective.Layers[1].Path != path || !effective.Layers[1].Loaded || !effective.Layers[2].Writable {
+		t.Errorf("Expected the system, executable and user layers, got %+v", effective.Layers)
+	}
+
+	// The tools see the same config get_config reports
+	if cfg, _ := Load(ctx); !reflect.DeepEqual(cfg, effective.Config) {
+		t.Errorf("Expected get_config to report the active config, got %+v and %+v", effective.Config, cfg)
+	}
+}
+
+func TestHandleGetConfig_InvalidConfig(t *testing.T) {
+	ctx, _ := useTestConfig(t, `{"blockedCommands": "rm"}`)
+
+	if _, err := HandleGetConfig(ctx, GetConfigArgs{}); err == nil {
+		t.Error("Expected an error")
+	}
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// Layer names, from lowest to highest precedence. Each setting comes from the
+// highest layer that sets it.
+const (
+	LayerDefaults   = "defaults"
+	LayerSystem     = "system"
+	LayerExecutable = "executable"
+	LayerUser       = "user"
+	LayerFlag       = "flag"
+	LayerEnv        = "env"
+)
+
+// appName names the config directories under /etc and the user config directory
+const appName = "golang-mcp-testing"
+
+// envPrefix is prepended to the upper snake case config key to form the
+// environment variable overriding it, e.g. MCP_DEFAULT_SHELL for defaultShell
+const envPrefix = "MCP_"
+
+// systemConfigDir holds the system-wide config file. Overridden in tests.
+var systemConfigDir = filepath.Join("/etc", appName)
+
+// FileLayer is a config file and the name of the layer it provides
+type FileLayer struct {
+	Name string
+	Path string
+}
+
+// configField is a ServerConfig field as it appears in config files and the environment
+type configField struct {
+	key    string
+	envVar string
+	typ    reflect.Type
+}
+
+// configFields lists the ServerConfig fields in declaration order
+func configFields() []configField {
+	structType := reflect.TypeOf(ServerConfig{})
+	fields := make([]configField, 0, structType.NumField())
+	for i := 0; i < structType.NumField(); i++ {
+		key, _, _ := strings.Cut(structType.Field(i).Tag.Get("json"), ",")
+		fields = append(fields, configField{key: key, envVar: envPrefix + upperSnake(key), typ: structType.Field(i).Type})
+	}
+	return fields
+}
+
+// isConfigKey reports whether key is the JSON name of a ServerConfig field
+func isConfigKey(key string) bool {
+	for _, field := range configFields() {
+		if field.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// upperSnake converts a camel case key such as logMaxSizeMB to LOG_MAX_SIZE_MB
+func upperSnake(key string) string {
+	var b strings.Builder
+	var prev rune
+	for i, r := range key {
+		if i > 0 && unicode.IsUpper(r) && !unicode.IsUpper(prev) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+		prev = r
+	}
+	return b.String()
+}
+
+// defaultLayers returns the config files read by the server: the system file,
+// the file next to the executable that older installs use, the user's file and
+// configFile, if set
+func defaultLayers(configFile string) []FileLayer {
+	layers := []FileLayer{{Name: LayerSystem, Path: filepath.Join(systemConfigDir, configFileName)}}
+	if path, err := getConfigPath(); err == nil {
+		layers = append(layers, FileLayer{Name: LayerExecutable, Path: path})
+	}
+	if dir, err := userConfigDir(); err == nil {
+		layers = append(layers, FileLayer{Name: LayerUser, Path: filepath.Join(dir, appName, configFileName)})
+	}
+	if configFile != "" {
+		layers = append(layers, FileLayer{Name: LayerFlag, Path: configFile})
+	}
+	return layers
+}
+
+// userConfigDir returns $XDG_CONFIG_HOME, or ~/.config when it isn't set, on
+// every platform. os.UserConfigDir would use ~/Library/Application Support on
+// macOS and %AppData% on Windows instead.
+func userConfigDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config"), nil
+}
+
+// envOverride is a config value set through an environment variable
+type envOverride struct {
+	key    string
+	envVar string
+	value  json.RawMessage
+}
+
+// envOverrides returns the config values set through MCP_* environment
+// variables. Strings are taken as is, lists are JSON arrays or comma separated,
+// and other values are JSON.
+func envOverrides() []envOverride {
+	var overrides []envOverride
+	for _, field := range configFields() {
+		value, ok := os.LookupEnv(field.envVar)
+		if !ok {
+			continue
+		}
+
+		var raw json.RawMessage
+		switch {
+		case field.typ.Kind() == reflect.Pointer && field.typ.Elem().Kind() == reflect.String:
+			raw, _ = json.Marshal(value)
+		case field.typ.Kind() == reflect.Slice && !strings.HasPrefix(strings.TrimSpace(value), "["):
+			items := []string{}
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			raw, _ = json.Marshal(items)
+		default:
+			raw = json.RawMessage(value)
+		}
+		overrides = append(overrides, envOverride{key: field.key, envVar: field.envVar, value: raw})
+	}
+	return overrides
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestUpperSnake(t *testing.T) {
+	tests := map[string]string{
+		"defaultShell":       "DEFAULT_SHELL",
+		"allowedDirectories": "ALLOWED_DIRECTORIES",
+		"logMaxSizeMB":       "LOG_MAX_SIZE_MB",
+		"blockedCommands":    "BLOCKED_COMMANDS",
+	}
+	for key, want := range tests {
+		if got := upperSnake(key); got != want {
+			t.Errorf("upperSnake(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestDefaultLayers(t *testing.T) {
+	dir := t.TempDir()
+	systemConfigDir, testConfigDir = filepath.Join(dir, "etc"), filepath.Join(dir, "exe")
+	t.Cleanup(func() { systemConfigDir, testConfigDir = filepath.Join("/etc", appName), "" })
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+
+	want := []FileLayer{
+		{Name: LayerSystem, Path: filepath.Join(dir, "etc", "config.json")},
+		{Name: LayerExecutable, Path: filepath.Join(dir, "exe", "config.json")},
+		{Name: LayerUser, Path: filepath.Join(dir, "xdg", appName, "config.json")},
+		{Name: LayerFlag, Path: "/custom.json"},
+	}
+	if got := defaultLayers("/custom.json"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if got := defaultLayers(""); !reflect.DeepEqual(got, want[:3]) {
+		t.Errorf("Expected no flag layer, got %v", got)
+	}
+}
+
+func TestUserConfigDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "xdg"))
+	if dir, err := userConfigDir(); err != nil || dir != filepath.Join(home, "xdg") {
+		t.Errorf("Expected $XDG_CONFIG_HOME, got %s (%v)", dir, err)
+	}
+
+	// Unset or relative, XDG_CONFIG_HOME is ignored
+	for _, value := range []string{"", "relative/dir"} {
+		t.Setenv("XDG_CONFIG_HOME", value)
+		if dir, err := userConfigDir(); err != nil || dir != filepath.Join(home, ".config") {
+			t.Errorf("Expected ~/.config for XDG_CONFIG_HOME=%q, got %s (%v)", value, dir, err)
+		}
+	}
+}
+
+func TestLayeredStore_Precedence(t *testing.T) {
+	dir := t.TempDir()
+	system, user, flag := filepath.Join(dir, "system.json"), filepath.Join(dir, "user.json"), filepath.Join(dir, "flag.json")
+	writeConfig(t, system, `{"blockedCommands": ["rm"], "defaultShell": "/bin/sh", "logLevel": "warn"}`)
+	writeConfig(t, user, `{"defaultShell": "/bin/bash", "logFormat": "text"}`)
+	writeConfig(t, flag, `{"logFormat": "json"}`)
+	t.Setenv("MCP_LOG_LEVEL", "debug")
+	os.Mkdir(filepath.Join(dir, "other"), 0755)
+	t.Setenv("MCP_ALLOWED_DIRECTORIES", dir+", "+filepath.Join(dir, "other"))
+
+	store := NewLayeredStore([]FileLayer{
+		{Name: LayerSystem, Path: system},
+		{Name: LayerExecutable, Path: filepath.Join(dir, "missing.json")},
+		{Name: LayerUser, Path: user},
+		{Name: LayerFlag, Path: flag},
+	}, discardLogger())
+
+	effective, err := store.Effective()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	cfg := effective.Config
+	if *cfg.DefaultShell != "/bin/bash" || *cfg.LogFormat != "json" || *cfg.LogLevel != "debug" || cfg.BlockedCommands[0] != "rm" {
+		t.Errorf("Expected each setting from its highest layer, got %+v", cfg)
+	}
+	if !reflect.DeepEqual(cfg.AllowedDirectories, []string{dir, filepath.Join(dir, "other")}) {
+		t.Errorf("Expected the comma separated directories, got %v", cfg.AllowedDirectories)
+	}
+
+	wantSources := map[string]string{
+		"blockedCommands":    LayerSystem,
+		"defaultShell":       LayerUser,
+		"logFormat":          LayerFlag,
+		"logLevel":           "env MCP_LOG_LEVEL",
+		"allowedDirectories": "env MCP_ALLOWED_DIRECTORIES",
+	}
+	if !reflect.DeepEqual(effective.Sources, wantSources) {
+		t.Errorf("Expected sources %v, got %v", wantSources, effective.Sources)
+	}
+
+	wantLayers := []LayerStatus{
+		{Name: LayerSystem, Path: system, Loaded: true},
+		{Name: LayerExecutable, Path: filepath.Join(dir, "missing.json")},
+		{Name: LayerUser, Path: user, Loaded: true},
+		{Name: LayerFlag, Path: flag, Loaded: true, Writable: true},
+	}
+	if !reflect.DeepEqual(effective.Layers, wantLayers) {
+		t.Errorf("Expected layers %v, got %v", wantLayers, effective.Layers)
+	}
+
+	// Environment settings can't be changed through the file
+	if _, _, err := store.Set("/logLevel", []byte(`"info"`), false); err == nil {
+		t.Error("Expected an error when setting a key overridden by the environment")
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	t.Setenv("MCP_BLOCKED_COMMANDS", `["rm", "dd"]`)
+	t.Setenv("MCP_TELEMETRY_ENABLED", "false")
+	t.Setenv("MCP_LOG_MAX_BACKUPS", "5")
+
+	store := NewStore(filepath.Join(t.TempDir(), "config.json"), discardLogger())
+	cfg, err := store.Current()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(cfg.BlockedCommands, []string{"rm", "dd"}) || *cfg.TelemetryEnabled || *cfg.LogMaxBackups != 5 {
+		t.Errorf("Expected the environment overrides, got %+v", cfg)
+	}
+
+	t.Setenv("MCP_LOG_MAX_BACKUPS", "five")
+	if err := store.Reload(); err == nil {
+		t.Error("Expected an error for an invalid number")
+	}
+}
+
+func TestLayeredStore_SetCreatesWritableFile(t *testing.T) {
+	dir := t.TempDir()
+	system, user := filepath.Join(dir, "system.json"), filepath.Join(dir, "user", "config.json")
+	writeConfig(t, system, `{"blockedCommands": ["rm"]}`)
+	store := NewLayeredStore([]FileLayer{{Name: LayerSystem, Path: system}, {Name: LayerUser, Path: user}}, discardLogger())
+
+	// Appending starts from the value of the system file
+	_, backupPath, err := store.Set("/blockedCommands/-", []byte(`"dd"`), false)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if backupPath != "" {
+		t.Errorf("Expected no backup of a new file, got %s", backupPath)
+	}
+
+	effective, _ := store.Effective()
+	if !reflect.DeepEqual(effective.Config.BlockedCommands, []string{"rm", "dd"}) || effective.Sources["blockedCommands"] != LayerUser {
+		t.Errorf("Expected the user file to extend the blocklist, got %v from %s", effective.Config.BlockedCommands, effective.Sources["blockedCommands"])
+	}
+	if content, _ := os.ReadFile(system); string(content) != `{"blockedCommands": ["rm"]}` {
+		t.Errorf("Expected the system file to be unchanged, got %s", content)
+	}
+
+	// A loaded file disappearing doesn't silently fall back to the layers below
+	os.Remove(user)
+	if err := store.Reload(); err == nil {
+		t.Error("Expected an error for a removed file")
+	}
+	if cfg, _ := store.Current(); len(cfg.BlockedCommands) != 2 {
+		t.Errorf("Expected the last good config, got %v", cfg.BlockedCommands)
+	}
+}
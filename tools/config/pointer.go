@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parsePointer splits a JSON pointer (RFC 6901) such as /blockedCommands/0 into
+// its unescaped reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" || pointer == "/" {
+		return nil, fmt.Errorf("pointer must refer to a config key, e.g. /defaultShell")
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid pointer %q: must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// setPointer returns doc with the value at tokens replaced by value, or removed
+// if remove is set. In arrays the token - appends.
+func setPointer(doc any, tokens []string, value any, remove bool) (any, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	token, rest := tokens[0], tokens[1:]
+
+	switch node := doc.(type) {
+	case map[string]any:
+		if len(rest) == 0 {
+			if remove {
+				delete(node, token)
+			} else {
+				node[token] = value
+			}
+			return node, nil
+		}
+		child, ok := node[token]
+		if !ok {
+			return nil, fmt.Errorf("key %q does not exist", token)
+		}
+		updated, err := setPointer(child, rest, value, remove)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = updated
+		return node, nil
+
+	case []any:
+		if token == "-" && len(rest) == 0 && !remove {
+			return append(node, value), nil
+		}
+		index, err := strconv.Atoi(token)
+		if err != nil || index < 0 || index >= len(node) || (len(token) > 1 && token[0] == '0') {
+			return nil, fmt.Errorf("invalid array index %q for an array of %d elements", token, len(node))
+		}
+		if len(rest) == 0 {
+			if remove {
+				return append(node[:index], node[index+1:]...), nil
+			}
+			node[index] = value
+			return node, nil
+		}
+		updated, err := setPointer(node[index], rest, value, remove)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = updated
+		return node, nil
+
+	default:
+		return nil, fmt.Errorf("cannot index into %T with %q", doc, token)
+	}
+}
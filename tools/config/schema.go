@@ -0,0 +1,317 @@
+package config
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Schema is the JSON Schema of config files. Validation follows it, supporting
+// the keywords it uses: type, properties, additionalProperties, items, enum and minimum.
+//
+//go:embed schema.json
+var Schema []byte
+
+// schemaNode is the subset of JSON Schema used by Schema
+type schemaNode struct {
+	Type                 string                 `json:"type"`
+	Properties           map[string]*schemaNode `json:"properties"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *schemaNode            `json:"items"`
+	Enum                 []any                  `json:"enum"`
+	Minimum              *float64               `json:"minimum"`
+}
+
+// configSchema is the parsed Schema
+var configSchema = func() *schemaNode {
+	var schema schemaNode
+	if err := json.Unmarshal(Schema, &schema); err != nil {
+		panic(fmt.Sprintf("invalid config schema: %v", err))
+	}
+	return &schema
+}()
+
+// Problem is a single reason a configuration is invalid
+type Problem struct {
+	Source  string `json:"source"` // Config file or environment variable
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Pointer string `json:"pointer,omitempty"` // JSON pointer to the offending value
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	var b strings.Builder
+	b.WriteString(p.Source)
+	if p.Line > 0 {
+		fmt.Fprintf(&b, ":%d:%d", p.Line, p.Column)
+	}
+	if p.Pointer != "" {
+		b.WriteString(": " + p.Pointer)
+	}
+	b.WriteString(": " + p.Message)
+	return b.String()
+}
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		lines[i] = "  " + problem.String()
+	}
+	return fmt.Sprintf("invalid config, %d problem(s):\n%s", len(e.Problems), strings.Join(lines, "\n"))
+}
+
+// jsonNode is a decoded JSON value together with its position in the source
+type jsonNode struct {
+	line, column       int
+	keyLine, keyColumn int // position of the key, for object members
+	value              any // string, json.Number, bool or nil for scalars
+	object             map[string]*jsonNode
+	keys               []string // object keys in source order
+	array              []*jsonNode
+	isObject           bool
+	isArray            bool
+}
+
+// kind returns the JSON Schema type name of the node's value
+func (n *jsonNode) kind() string {
+	switch v := n.value.(type) {
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	switch {
+	case n.isObject:
+		return "object"
+	case n.isArray:
+		return "array"
+	}
+	return "null"
+}
+
+// decoder parses JSON into jsonNodes, tracking line and column numbers
+type decoder struct {
+	content    []byte
+	dec        *json.Decoder
+	lineStarts []int
+	problems   []Problem
+	source     string
+}
+
+// parseDocument parses content, reporting syntax errors and duplicate keys as
+// problems attributed to source
+func parseDocument(source string, content []byte) (*jsonNode, []Problem) {
+	d := &decoder{content: content, dec: json.NewDecoder(bytes.NewReader(content)), lineStarts: []int{0}, source: source}
+	d.dec.UseNumber()
+	for i, c := range content {
+		if c == '\n' {
+			d.lineStarts = append(d.lineStarts, i+1)
+		}
+	}
+
+	root, err := d.parse("")
+	if err == nil {
+		if _, err = d.dec.Token(); err == io.EOF {
+			err = nil
+		} else if err == nil {
+			err = fmt.Errorf("unexpected data after the top-level value")
+		}
+	}
+	if err != nil {
+		offset := int(d.dec.InputOffset())
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			// Offset counts the offending byte
+			offset = max(int(syntaxErr.Offset)-1, 0)
+		} else if errors.Is(err, io.ErrUnexpectedEOF) || err == io.EOF {
+			err = fmt.Errorf("unexpected end of JSON input")
+		}
+		line, column := d.position(offset)
+		return nil, append(d.problems, Problem{Source: source, Line: line, Column: column, Message: err.Error()})
+	}
+	return root, d.problems
+}
+
+// parse decodes the next value, found at pointer
+func (d *decoder) parse(pointer string) (*jsonNode, error) {
+	node := &jsonNode{}
+	node.line, node.column = d.position(d.nextOffset())
+	token, err := d.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		node.isObject, node.object = true, make(map[string]*jsonNode)
+		for d.dec.More() {
+			line, column := d.position(d.nextOffset())
+			keyToken, err := d.dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := keyToken.(string)
+			child, err := d.parse(pointer + "/" + escapePointer(key))
+			if err != nil {
+				return nil, err
+			}
+			child.keyLine, child.keyColumn = line, column
+			if _, ok := node.object[key]; ok {
+				d.problems = append(d.problems, Problem{Source: d.source, Line: line, Column: column, Pointer: pointer + "/" + escapePointer(key), Message: "duplicate key"})
+			} else {
+				node.keys = append(node.keys, key)
+			}
+			node.object[key] = child
+		}
+		_, err = d.dec.Token()
+		return node, err
+	case json.Delim('['):
+		node.isArray, node.array = true, []*jsonNode{}
+		for d.dec.More() {
+			child, err := d.parse(fmt.Sprintf("%s/%d", pointer, len(node.array)))
+			if err != nil {
+				return nil, err
+			}
+			node.array = append(node.array, child)
+		}
+		_, err = d.dec.Token()
+		return node, err
+	default:
+		node.value = token
+		return node, nil
+	}
+}
+
+// nextOffset returns the offset of the next token, skipping whitespace and separators
+func (d *decoder) nextOffset() int {
+	offset := int(d.dec.InputOffset())
+	for offset < len(d.content) && strings.IndexByte(" \t\r\n,:", d.content[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// position converts a byte offset into a 1-based line and column
+func (d *decoder) position(offset int) (line, column int) {
+	line = sort.Search(len(d.lineStarts), func(i int) bool { return d.lineStarts[i] > offset })
+	return line, offset - d.lineStarts[line-1] + 1
+}
+
+// escapePointer escapes a key for use as a JSON pointer token
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// validate checks node against schema, reporting problems attributed to source
+func (schema *schemaNode) validate(source string, node *jsonNode, pointer string) []Problem {
+	problem := func(format string, args ...any) []Problem {
+		return []Problem{{Source: source, Line: node.line, Column: node.column, Pointer: pointer, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	kind := node.kind()
+	if schema.Type != "" && kind != schema.Type && !(schema.Type == "number" && kind == "integer") {
+		return problem("expected %s, got %s", schemaArticle(schema.Type), schemaArticle(kind))
+	}
+	if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, node.value) {
+		return problem("must be one of %s", formatEnum(schema.Enum))
+	}
+	if schema.Minimum != nil {
+		if number, ok := node.value.(json.Number); ok {
+			if value, _ := number.Float64(); value < *schema.Minimum {
+				return problem("must be at least %v", *schema.Minimum)
+			}
+		}
+	}
+
+	var problems []Problem
+	if node.isObject {
+		for _, key := range node.keys {
+			childPointer := pointer + "/" + escapePointer(key)
+			child := node.object[key]
+			if property, ok := schema.Properties[key]; ok {
+				problems = append(problems, property.validate(source, child, childPointer)...)
+			} else if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+				message := fmt.Sprintf("unknown key %q", key)
+				if suggestion := closestKey(key, schema.Properties); suggestion != "" {
+					message += fmt.Sprintf(", did you mean %q?", suggestion)
+				}
+				problems = append(problems, Problem{Source: source, Line: child.keyLine, Column: child.keyColumn, Pointer: childPointer, Message: message})
+			}
+		}
+	}
+	if node.isArray && schema.Items != nil {
+		for i, item := range node.array {
+			problems = append(problems, schema.Items.validate(source, item, fmt.Sprintf("%s/%d", pointer, i))...)
+		}
+	}
+	return problems
+}
+
+// schemaArticle prefixes a type name with its indefinite article
+func schemaArticle(kind string) string {
+	switch kind {
+	case "object", "array", "integer":
+		return "an " + kind
+	case "null":
+		return kind
+	}
+	return "a " + kind
+}
+
+func formatEnum(values []any) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = fmt.Sprintf("%q", value)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// closestKey returns the known key most similar to key, if it is close enough
+// to be a typo
+func closestKey(key string, properties map[string]*schemaNode) string {
+	best, bestDistance := "", len(key)/3+1
+	for candidate := range properties {
+		if distance := editDistance(strings.ToLower(key), strings.ToLower(candidate)); distance < bestDistance || (distance == bestDistance && candidate < best) {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
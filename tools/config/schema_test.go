@@ -0,0 +1,117 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSchema_MatchesServerConfig(t *testing.T) {
+	kinds := map[reflect.Kind]string{reflect.String: "string", reflect.Bool: "boolean", reflect.Int: "integer", reflect.Slice: "array"}
+
+	properties := make(map[string]bool)
+	for key := range configSchema.Properties {
+		properties[key] = true
+	}
+	delete(properties, "$schema")
+
+	for _, field := range configFields() {
+		property, ok := configSchema.Properties[field.key]
+		if !ok {
+			t.Errorf("Schema is missing %s", field.key)
+			continue
+		}
+		delete(properties, field.key)
+		typ := field.typ
+		if typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		if kinds[typ.Kind()] != property.Type {
+			t.Errorf("Schema type of %s is %s, expected %s", field.key, property.Type, kinds[typ.Kind()])
+		}
+	}
+	for key := range properties {
+		t.Errorf("Schema has %s, which is not in ServerConfig", key)
+	}
+}
+
+// validateContent returns the problems of a config file with content
+func validateContent(t *testing.T, content string) (string, []Problem) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, content)
+	return path, NewStore(path, discardLogger()).Validate()
+}
+
+func TestValidate_SchemaProblems(t *testing.T) {
+	path, problems := validateContent(t, `{
+  "allowedDirectory": ["/tmp"],
+  "blockedCommands": ["rm", 5],
+  "logLevel": "verbose",
+  "logMaxBackups": -1,
+  "logFormat": "text",
+  "logFormat": "json"
+}`)
+
+	want := []Problem{
+		{Source: path, Line: 7, Column: 3, Pointer: "/logFormat", Message: "duplicate key"},
+		{Source: path, Line: 2, Column: 3, Pointer: "/allowedDirectory", Message: `unknown key "allowedDirectory", did you mean "allowedDirectories"?`},
+		{Source: path, Line: 3, Column: 29, Pointer: "/blockedCommands/1", Message: "expected a string, got an integer"},
+		{Source: path, Line: 4, Column: 15, Pointer: "/logLevel", Message: `must be one of "debug", "info", "warn", "error"`},
+		{Source: path, Line: 5, Column: 20, Pointer: "/logMaxBackups", Message: "must be at least 0"},
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("Expected problems:\n%v\ngot:\n%v", want, problems)
+	}
+}
+
+func TestValidate_SyntaxError(t *testing.T) {
+	// The trailing comma
+	path, problems := validateContent(t, "{\n  \"logLevel\": \"info\",\n}")
+
+	if len(problems) != 1 || problems[0].Source != path || problems[0].Line != 2 || problems[0].Column != 21 {
+		t.Errorf("Expected a syntax error at 2:21, got %v", problems)
+	}
+}
+
+func TestValidate_Semantics(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	os.WriteFile(file, nil, 0644)
+
+	path, problems := validateContent(t, `{
+  "allowedDirectories": ["`+dir+`", "`+filepath.Join(dir, "missing")+`", "`+file+`"],
+  "defaultShell": "`+file+`",
+  "logRedactPatterns": ["ok", "("]
+}`)
+
+	wantPointers := []string{"/allowedDirectories/1", "/allowedDirectories/2", "/defaultShell", "/logRedactPatterns/1"}
+	if len(problems) != len(wantPointers) {
+		t.Fatalf("Expected %d problems, got %v", len(wantPointers), problems)
+	}
+	for i, problem := range problems {
+		if problem.Source != path || problem.Pointer != wantPointers[i] || problem.Line == 0 {
+			t.Errorf("Expected a located problem at %s, got %v", wantPointers[i], problem)
+		}
+	}
+}
+
+func TestValidate_EnvironmentAndActiveConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, `{"logLevel": "warn"}`)
+	store := NewStore(path, discardLogger())
+
+	t.Setenv("MCP_LOG_LEVEL", "verbose")
+	writeConfig(t, path, `{"logLevel": "warn", "extra": true}`)
+
+	problems := store.Validate()
+	if len(problems) != 2 || problems[0].Pointer != "/extra" || problems[1].Source != "MCP_LOG_LEVEL" || problems[1].Line != 0 {
+		t.Errorf("Expected the unknown key and the environment problem, got %v", problems)
+	}
+
+	// Validation doesn't change the active config
+	if cfg, err := store.Current(); err != nil || *cfg.LogLevel != "warn" {
+		t.Errorf("Expected the active config to be kept, got %v and error %v", cfg, err)
+	}
+}
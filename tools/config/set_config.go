@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"github.com/localrivet/gomcp/server"
+)
+
+// AllowUnsafeEnv is the environment variable that lets set_config_value loosen
+// security. It is deliberately not a config key, so a client can't enable it.
+const AllowUnsafeEnv = "MCP_CONFIG_ALLOW_UNSAFE"
+
+// SetConfigValueArgs defines the arguments for the set_config_value tool
+type SetConfigValueArgs struct {
+	Pointer string `json:"pointer" description:"JSON pointer to the value to change, e.g. /defaultShell, /allowedDirectories/0 or /blockedCommands/- to append." required:"true"`
+	Value   string `json:"value" description:"The new value as JSON, e.g. \"/bin/zsh\" or [\"~/projects\"]. null removes the key or array element." required:"true"`
+}
+
+// SetConfigValueResult defines the result structure for the set_config_value tool
+type SetConfigValueResult struct {
+	Config     *ServerConfig `json:"config"`
+	Loosened   []string      `json:"loosened,omitempty"`
+	BackupPath string        `json:"backup_path,omitempty"`
+}
+
+// HandleSetConfigValue implements the logic for the set_config_value tool
+// This handler edits a single config value, refusing invalid results and, unless
+// AllowUnsafeEnv is set, changes that loosen security.
+func HandleSetConfigValue(ctx *server.Context, args SetConfigValueArgs) (SetConfigValueResult, error) {
+	ctx.Logger.Info("Handling set_config_value tool call", "pointer", args.Pointer)
+
+	store, err := getStore(ctx)
+	if err != nil {
+		return SetConfigValueResult{}, err
+	}
+
+	loosened, backupPath, err := store.Set(args.Pointer, []byte(args.Value), allowUnsafe())
+	if err != nil {
+		return SetConfigValueResult{}, err
+	}
+	if len(loosened) > 0 {
+		ctx.Logger.Warn("Config change loosens security", "pointer", args.Pointer, "changes", loosened)
+	}
+
+	cfg, err := store.Current()
+	if err != nil {
+		return SetConfigValueResult{}, err
+	}
+	return SetConfigValueResult{Config: cfg, Loosened: loosened, BackupPath: backupPath}, nil
+}
+
+// allowUnsafe reports whether AllowUnsafeEnv is set in the server's environment
+func allowUnsafe() bool {
+	allow, _ := strconv.ParseBool(os.Getenv(AllowUnsafeEnv))
+	return allow
+}
+
+// loosenings describes how next is less restrictive than prev: unblocked
+// commands, newly reachable directories, a log file or download directory moved
+// outside them, dropped redaction patterns and a different shell, which could
+// run commands the blocklist doesn't recognize
+func loosenings(prev, next *snapshot) []string {
+	var changes []string
+	for _, command := range prev.config.BlockedCommands {
+		if !slices.Contains(next.config.BlockedCommands, command) {
+			changes = append(changes, fmt.Sprintf("unblocks command %q", command))
+		}
+	}
+
+	if len(prev.policy.Roots()) > 0 {
+		if len(next.policy.Roots()) == 0 {
+			changes = append(changes, "removes the allowed directory restriction")
+		}
+		for _, root := range next.policy.Roots() {
+			if !prev.policy.Allows(root) {
+				changes = append(changes, fmt.Sprintf("allows directory %s", root))
+			}
+		}
+	}
+
+	// The server writes to these itself, so they could overwrite files the tools can't
+	if outside := writtenOutsideRoots(prev.config.LogFile, next.config.LogFile, next); outside != "" {
+		changes = append(changes, fmt.Sprintf("writes the log file outside the allowed directories to %s", outside))
+	}
+	if outside := writtenOutsideRoots(prev.config.DownloadDirectory, next.config.DownloadDirectory, next); outside != "" {
+		changes = append(changes, fmt.Sprintf("downloads outside the allowed directories to %s", outside))
+	}
+
+	for _, pattern := range prev.config.LogRedactPatterns {
+		if !slices.Contains(next.config.LogRedactPatterns, pattern) {
+			changes = append(changes, fmt.Sprintf("stops redacting %q", pattern))
+		}
+	}
+
+	if !reflect.DeepEqual(prev.config.DefaultShell, next.config.DefaultShell) {
+		changes = append(changes, "changes the default shell")
+	}
+	return changes
+}
+
+// writtenOutsideRoots returns the changed path next if next's path policy
+// doesn't allow it, and "" otherwise
+func writtenOutsideRoots(prev, next *string, snap *snapshot) string {
+	if next == nil || *next == "" || reflect.DeepEqual(prev, next) {
+		return ""
+	}
+	if _, err := snap.policy.Resolve(*next); err != nil {
+		return *next
+	}
+	return ""
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParsePointer(t *testing.T) {
+	tokens, err := parsePointer("/a~1b/m~0n/0")
+	if err != nil || !reflect.DeepEqual(tokens, []string{"a/b", "m~n", "0"}) {
+		t.Errorf("Expected unescaped tokens, got %v and error %v", tokens, err)
+	}
+	for _, pointer := range []string{"", "/", "defaultShell"} {
+		if _, err := parsePointer(pointer); err == nil {
+			t.Errorf("Expected an error for %q", pointer)
+		}
+	}
+}
+
+func TestStore_Set(t *testing.T) {
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to resolve temp dir: %v", err)
+	}
+	allowed := filepath.Join(base, "allowed")
+	os.MkdirAll(filepath.Join(allowed, "sub"), 0755)
+	initial := `{"blockedCommands": ["rm", "sudo"], "allowedDirectories": ["` + allowed + `"], "defaultShell": "/bin/sh"}`
+
+	tests := []struct {
+		name        string
+		pointer     string
+		value       string
+		allowUnsafe bool
+		wantErr     bool
+		loosened    int
+		check       func(t *testing.T, cfg *ServerConfig)
+	}{
+		{
+			name: "Append blocked command", pointer: "/blockedCommands/-", value: `"dd"`,
+			check: func(t *testing.T, cfg *ServerConfig) {
+				if !reflect.DeepEqual(cfg.BlockedCommands, []string{"rm", "sudo", "dd"}) {
+					t.Errorf("Expected dd to be appended, got %v", cfg.BlockedCommands)
+				}
+			},
+		},
+		{
+			name: "Narrow allowed directories", pointer: "/allowedDirectories/0", value: `"` + filepath.Join(allowed, "sub") + `"`,
+			check: func(t *testing.T, cfg *ServerConfig) {
+				if cfg.AllowedDirectories[0] != filepath.Join(allowed, "sub") {
+					t.Errorf("Expected the narrower directory, got %v", cfg.AllowedDirectories)
+				}
+			},
+		},
+		{
+			name: "Set telemetry", pointer: "/telemetryEnabled", value: `false`,
+			check: func(t *testing.T, cfg *ServerConfig) {
+				if cfg.TelemetryEnabled == nil || *cfg.TelemetryEnabled {
+					t.Errorf("Expected telemetry to be disabled, got %v", cfg.TelemetryEnabled)
+				}
+			},
+		},
+		{name: "Unblock command", pointer: "/blockedCommands/0", value: `null`, wantErr: true},
+		{name: "Widen allowed directories", pointer: "/allowedDirectories/-", value: `"` + base + `"`, wantErr: true},
+		{name: "Remove allowed directories", pointer: "/allowedDirectories", value: `null`, wantErr: true},
+		{name: "Change shell", pointer: "/defaultShell", value: `"/bin/bash"`, wantErr: true},
+		{name: "Log file outside allowed directories", pointer: "/logFile", value: `"` + filepath.Join(base, "outside.log") + `"`, wantErr: true},
+		{name: "Download directory outside allowed directories", pointer: "/downloadDirectory", value: `"` + base + `"`, wantErr: true},
+		{
+			name: "Log file inside allowed directories", pointer: "/logFile", value: `"` + filepath.Join(allowed, "server.log") + `"`,
+			check: func(t *testing.T, cfg *ServerConfig) {
+				if cfg.LogFile == nil || *cfg.LogFile != filepath.Join(allowed, "server.log") {
+					t.Errorf("Expected the log file to be set, got %v", cfg.LogFile)
+				}
+			},
+		},
+		{
+			name: "Unblock command with allow unsafe", pointer: "/blockedCommands/0", value: `null`, allowUnsafe: true, loosened: 1,
+			check: func(t *testing.T, cfg *ServerConfig) {
+				if !reflect.DeepEqual(cfg.BlockedCommands, []string{"sudo"}) {
+					t.Errorf("Expected rm to be removed, got %v", cfg.BlockedCommands)
+				}
+			},
+		},
+		{name: "Unknown key", pointer: "/allowedDirectory", value: `[]`, wantErr: true},
+		{name: "Wrong type", pointer: "/blockedCommands", value: `"rm"`, wantErr: true},
+		{name: "Invalid JSON value", pointer: "/defaultShell", value: `/bin/sh`, wantErr: true},
+		{name: "Index out of range", pointer: "/blockedCommands/5", value: `"dd"`, wantErr: true},
+		{name: "Invalid redact pattern", pointer: "/logRedactPatterns", value: `["("]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			writeConfig(t, path, initial)
+			store := NewStore(path, discardLogger())
+
+			loosened, backupPath, err := store.Set(tt.pointer, []byte(tt.value), tt.allowUnsafe)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				if content, _ := os.ReadFile(path); string(content) != initial {
+					t.Errorf("Expected the file to be unchanged, got %s", content)
+				}
+				if _, err := os.Stat(path + backupSuffix); !os.IsNotExist(err) {
+					t.Error("Expected no backup for a refused change")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if len(loosened) != tt.loosened {
+				t.Errorf("Expected %d loosening changes, got %v", tt.loosened, loosened)
+			}
+
+			// The change is active right away and persisted, with the old file backed up
+			cfg, _ := store.Current()
+			tt.check(t, cfg)
+			reloaded := NewStore(path, discardLogger())
+			cfg, _ = reloaded.Current()
+			tt.check(t, cfg)
+			if backup, _ := os.ReadFile(backupPath); string(backup) != initial {
+				t.Errorf("Expected the previous config in the backup, got %s", backup)
+			}
+		})
+	}
+}
@@ -0,0 +1,670 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang-mcp-testing/internal/pathpolicy"
+)
+
+// DefaultPollInterval is how often Watch checks the config files for changes
+const DefaultPollInterval = 2 * time.Second
+
+// backupSuffix is appended to the config path for the copy Set keeps of the previous file
+const backupSuffix = ".bak"
+
+// Store holds the active configuration, merged from the built-in defaults, config
+// files and environment overrides. Reload swaps in a new configuration only if
+// it is valid, so a bad edit keeps the last good one active.
+type Store struct {
+	layers []FileLayer
+	logger *slog.Logger
+
+	// active is swapped atomically so readers never see a partial update
+	active atomic.Pointer[snapshot]
+
+	// writeMu serializes edits made through Set
+	writeMu sync.Mutex
+
+	// mu serializes reloads and guards the fields below
+	mu          sync.Mutex
+	lastErr     error
+	stamps      map[string]fileStamp
+	loaded      map[string]bool
+	contentHash [sha256.Size]byte
+	subscribers map[int]func(*ServerConfig)
+	nextID      int
+}
+
+// snapshot is a validated configuration together with what is derived from it
+type snapshot struct {
+	config  *ServerConfig
+	policy  *pathpolicy.Policy
+	sources map[string]string
+}
+
+// fileStamp identifies a version of the config file without reading it
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// LayerStatus describes a config file layer
+type LayerStatus struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Loaded   bool   `json:"loaded"`
+	Writable bool   `json:"writable,omitempty"`
+}
+
+// EffectiveConfig is the active configuration along with where it came from
+type EffectiveConfig struct {
+	Config  *ServerConfig     `json:"config"`
+	Sources map[string]string `json:"sources"` // Layer that set each config key
+	Layers  []LayerStatus     `json:"layers"`
+}
+
+// NewStore loads the configuration from the file at path on top of the defaults
+func NewStore(path string, logger *slog.Logger) *Store {
+	return NewLayeredStore([]FileLayer{{Name: LayerFlag, Path: path}}, logger)
+}
+
+// NewLayeredStore loads the configuration from layers, ordered from lowest to
+// highest precedence, on top of the defaults and below environment overrides.
+// Missing files are skipped. Set writes to the last layer. A store is returned
+// even if the configuration is invalid; Current reports the error until a reload
+// succeeds.
+func NewLayeredStore(layers []FileLayer, logger *slog.Logger) *Store {
+	s := &Store{
+		layers:      layers,
+		logger:      logger,
+		loaded:      make(map[string]bool),
+		subscribers: make(map[int]func(*ServerConfig)),
+	}
+	if err := s.Reload(); err != nil {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			problems := make([]string, len(validationErr.Problems))
+			for i, problem := range validationErr.Problems {
+				problems[i] = problem.String()
+			}
+			logger.Error("Invalid config", "problems", problems)
+		} else {
+			logger.Error("Failed to load config", "error", err)
+		}
+	}
+	return s
+}
+
+// Current returns the active configuration. It is shared and must not be modified.
+func (s *Store) Current() (*ServerConfig, error) {
+	if snap := s.active.Load(); snap != nil {
+		return snap.config, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return nil, s.lastErr
+}
+
+// PathPolicy returns the filesystem access policy of the active configuration
+func (s *Store) PathPolicy() (*pathpolicy.Policy, error) {
+	if snap := s.active.Load(); snap != nil {
+		return snap.policy, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return nil, s.lastErr
+}
+
+// Effective returns the active configuration, the layer each setting came from
+// and the config files considered
+func (s *Store) Effective() (EffectiveConfig, error) {
+	snap := s.active.Load()
+	if snap == nil {
+		_, err := s.Current()
+		return EffectiveConfig{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	effective := EffectiveConfig{Config: snap.config, Sources: snap.sources}
+	for i, layer := range s.layers {
+		effective.Layers = append(effective.Layers, LayerStatus{
+			Name:     layer.Name,
+			Path:     layer.Path,
+			Loaded:   s.loaded[layer.Path],
+			Writable: i == len(s.layers)-1,
+		})
+	}
+	return effective, nil
+}
+
+// Subscribe registers fn to be called with each newly activated configuration.
+// fn runs on the reloading goroutine and must not call Subscribe or Reload.
+// The returned function removes the subscription.
+func (s *Store) Subscribe(fn func(*ServerConfig)) (unsubscribe func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextID
+	s.nextID++
+	s.subscribers[id] = fn
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subscribers, id)
+	}
+}
+
+// Reload reads and validates all layers and activates the result. On failure the
+// previous configuration stays active and the error, a *ValidationError for an
+// invalid configuration, is returned.
+func (s *Store) Reload() error {
+	return s.reload(false)
+}
+
+// reload implements Reload. If guarded, a configuration that loosens security
+// compared to the active one is refused unless AllowUnsafeEnv is set, like a
+// change made through Set, since a command may have edited the file.
+func (s *Store) reload(guarded bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Stamped before reading, so a write in between is picked up by the next poll
+	s.stamps = s.statLayers()
+	result, err := s.build("", nil)
+	if err != nil {
+		s.lastErr = err
+		return err
+	}
+
+	if active := s.active.Load(); guarded && active != nil && !allowUnsafe() {
+		if loosened := loosenings(active, result.snap); len(loosened) > 0 {
+			s.lastErr = fmt.Errorf("refusing to apply a config change that loosens security (%s): revert it, set %s=1 in the server's environment or restart the server", strings.Join(loosened, "; "), AllowUnsafeEnv)
+			return s.lastErr
+		}
+	}
+
+	// Touching a file without changing it doesn't count as a change
+	if s.active.Load() != nil && result.hash == s.contentHash {
+		s.lastErr = nil
+		return nil
+	}
+
+	s.active.Store(result.snap)
+	s.contentHash = result.hash
+	s.loaded = result.loaded
+	s.lastErr = nil
+	for _, fn := range s.subscribers {
+		fn(result.snap.config)
+	}
+	return nil
+}
+
+// Validate checks the configuration currently on disk without activating it
+// and returns its problems, if any
+func (s *Store) Validate() []Problem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.build("", nil)
+	var validationErr *ValidationError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &validationErr):
+		return validationErr.Problems
+	default:
+		return []Problem{{Message: err.Error()}}
+	}
+}
+
+// buildResult is a validated configuration built from the layers
+type buildResult struct {
+	snap   *snapshot
+	hash   [sha256.Size]byte
+	loaded map[string]bool
+}
+
+// origin is where the effective value of a config key was set
+type origin struct {
+	source string    // config file, environment variable or LayerDefaults
+	node   *jsonNode // the value, for its position; nil for the defaults
+	inFile bool
+}
+
+// build combines the defaults, the layers and the environment into a single
+// configuration and validates it, collecting every problem. Each top-level key
+// comes from the highest layer setting it. If replacePath is set, replacement is
+// used as that file's content. Must be called with mu held.
+func (s *Store) build(replacePath string, replacement []byte) (*buildResult, error) {
+	merged := make(map[string]json.RawMessage)
+	sources := make(map[string]string)
+	origins := make(map[string]origin)
+	loaded := make(map[string]bool)
+	var problems []Problem
+
+	defaults, err := json.Marshal(defaultConfig())
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling default config: %w", err)
+	}
+	var defaultFields map[string]json.RawMessage
+	json.Unmarshal(defaults, &defaultFields)
+	for key, value := range defaultFields {
+		merged[key], sources[key], origins[key] = value, LayerDefaults, origin{source: LayerDefaults}
+	}
+
+	for _, layer := range s.layers {
+		content := replacement
+		if layer.Path != replacePath || replacement == nil {
+			if content, err = s.readLayer(layer); err != nil {
+				return nil, err
+			}
+			if content == nil {
+				continue
+			}
+		}
+
+		root, parseProblems := parseDocument(layer.Path, content)
+		problems = append(problems, parseProblems...)
+		if root == nil {
+			continue
+		}
+		loaded[layer.Path] = true
+		problems = append(problems, configSchema.validate(layer.Path, root, "")...)
+		var fields map[string]json.RawMessage
+		if !root.isObject || json.Unmarshal(content, &fields) != nil {
+			continue
+		}
+		for key, value := range fields {
+			if key == "$schema" {
+				continue
+			}
+			merged[key], sources[key], origins[key] = value, layer.Name, origin{source: layer.Path, node: root.object[key], inFile: true}
+		}
+	}
+
+	for _, override := range envOverrides() {
+		root, parseProblems := parseDocument(override.envVar, override.value)
+		if root != nil {
+			parseProblems = append(parseProblems, configSchema.Properties[override.key].validate(override.envVar, root, "/"+override.key)...)
+		}
+		for _, problem := range parseProblems {
+			problem.Line, problem.Column = 0, 0
+			problems = append(problems, problem)
+		}
+		merged[override.key], sources[override.key], origins[override.key] = override.value, LayerEnv+" "+override.envVar, origin{source: override.envVar, node: root}
+	}
+
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	content, err := json.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling config: %w", err)
+	}
+	snap, problems := parseSnapshot(content, func(key string, index int, format string, args ...any) Problem {
+		return origins[key].problem(key, index, fmt.Sprintf(format, args...))
+	})
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	snap.sources = sources
+	// The file tools may not rewrite the config behind the store's back
+	snap.policy = snap.policy.WithDenied(s.protectedPaths()...)
+
+	// Sources are part of the hash so get_config reflects a setting moving between layers
+	sourcesJSON, _ := json.Marshal(sources)
+	return &buildResult{snap: snap, hash: sha256.Sum256(append(content, sourcesJSON...)), loaded: loaded}, nil
+}
+
+// problem reports message about the value of key, or its element at index if
+// index isn't negative
+func (o origin) problem(key string, index int, message string) Problem {
+	problem := Problem{Source: o.source, Pointer: "/" + key, Message: message}
+	node := o.node
+	if index >= 0 {
+		problem.Pointer += fmt.Sprintf("/%d", index)
+		if node != nil && index < len(node.array) {
+			node = node.array[index]
+		}
+	}
+	if node != nil && o.inFile {
+		problem.Line, problem.Column = node.line, node.column
+	}
+	return problem
+}
+
+// protectedPaths returns the config files and their backups, which the path
+// policy denies to the tools
+func (s *Store) protectedPaths() []string {
+	paths := make([]string, 0, 2*len(s.layers))
+	for _, layer := range s.layers {
+		paths = append(paths, layer.Path, layer.Path+backupSuffix)
+	}
+	return paths
+}
+
+// readLayer returns the content of a layer's file, or nil if it doesn't exist.
+// A file that provided the active configuration may not disappear, as falling
+// back to the layers below could silently loosen restrictions. Must be called
+// with mu held.
+func (s *Store) readLayer(layer FileLayer) ([]byte, error) {
+	content, err := os.ReadFile(layer.Path)
+	if os.IsNotExist(err) {
+		if s.loaded[layer.Path] {
+			return nil, fmt.Errorf("config file %s was removed, restore it or restart the server", layer.Path)
+		}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file %s: %w", layer.Path, err)
+	}
+	return content, nil
+}
+
+// Watch polls the config files every interval and reloads them when one
+// changes, until stop is closed. Invalid changes, and changes that loosen
+// security unless AllowUnsafeEnv is set, are logged and the last good
+// configuration stays active.
+func (s *Store) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if !s.changed() {
+			continue
+		}
+		if err := s.reload(true); err != nil {
+			s.logger.Error("Failed to reload config, keeping the last good configuration", "error", err)
+		} else {
+			s.logger.Info("Config reloaded")
+		}
+	}
+}
+
+// changed reports whether any config file was created, modified or removed
+// since it was last read
+func (s *Store) changed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !maps.Equal(s.statLayers(), s.stamps)
+}
+
+// statLayers returns the current stamp of each config file, zero for missing ones
+func (s *Store) statLayers() map[string]fileStamp {
+	stamps := make(map[string]fileStamp, len(s.layers))
+	for _, layer := range s.layers {
+		var stamp fileStamp
+		if info, err := os.Stat(layer.Path); err == nil {
+			stamp = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+		stamps[layer.Path] = stamp
+	}
+	return stamps
+}
+
+// Set changes the value at pointer, a JSON pointer into the configuration, to
+// value (JSON) in the highest file layer. A null value removes the key or array
+// element. The result must be a valid configuration, and changes that loosen
+// security are refused unless allowUnsafe is set; the loosening changes made are
+// returned. The file is replaced atomically, after copying the previous version
+// to the returned backup path, and the new configuration is activated right away.
+func (s *Store) Set(pointer string, value []byte, allowUnsafe bool) (loosened []string, backupPath string, err error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, "", err
+	}
+	if !isConfigKey(tokens[0]) {
+		return nil, "", fmt.Errorf("unknown config key %q", tokens[0])
+	}
+	var newValue any
+	if err := json.Unmarshal(value, &newValue); err != nil {
+		return nil, "", fmt.Errorf("value is not valid JSON: %w", err)
+	}
+
+	active, path, content, doc, err := s.writableLayer()
+	if err != nil {
+		return nil, "", err
+	}
+	if source := active.sources[tokens[0]]; strings.HasPrefix(source, LayerEnv) {
+		return nil, "", fmt.Errorf("%s is set by the environment (%s), which takes precedence over %s", tokens[0], source, path)
+	}
+
+	// Edits inside a value set by a lower layer start from the effective value
+	if _, ok := doc[tokens[0]]; !ok && len(tokens) > 1 {
+		var effective map[string]any
+		activeJSON, _ := json.Marshal(active.config)
+		json.Unmarshal(activeJSON, &effective)
+		if current, ok := effective[tokens[0]]; ok {
+			doc[tokens[0]] = current
+		}
+	}
+
+	updated, err := setPointer(doc, tokens, newValue, newValue == nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot set %s: %w", pointer, err)
+	}
+	return s.commit(active, path, updated, content, allowUnsafe)
+}
+
+// Save replaces the highest file layer with cfg, with the same validation,
+// security checks and backup as Set. Keys set by the environment keep their
+// value in the file, since the environment takes precedence anyway.
+func (s *Store) Save(cfg *ServerConfig, allowUnsafe bool) (loosened []string, backupPath string, err error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	active, path, content, doc, err := s.writableLayer()
+	if err != nil {
+		return nil, "", err
+	}
+
+	cfgJSON, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, "", fmt.Errorf("error marshalling config: %w", err)
+	}
+	updated := make(map[string]any)
+	json.Unmarshal(cfgJSON, &updated)
+	for key, source := range active.sources {
+		if strings.HasPrefix(source, LayerEnv) {
+			delete(updated, key)
+			if value, ok := doc[key]; ok {
+				updated[key] = value
+			}
+		}
+	}
+	if schema, ok := doc["$schema"]; ok {
+		updated["$schema"] = schema
+	}
+	return s.commit(active, path, updated, content, allowUnsafe)
+}
+
+// writableLayer returns the active configuration and the path, content and
+// decoded content of the highest file layer, which Set and Save write to. The
+// content is nil if the file doesn't exist yet.
+func (s *Store) writableLayer() (active *snapshot, path string, content []byte, doc map[string]any, err error) {
+	if len(s.layers) == 0 {
+		return nil, "", nil, nil, fmt.Errorf("there is no config file to write to")
+	}
+	path = s.layers[len(s.layers)-1].Path
+
+	active = s.active.Load()
+	if active == nil {
+		_, err := s.Current()
+		return nil, "", nil, nil, fmt.Errorf("the config must be fixed by hand first: %w", err)
+	}
+
+	content, err = os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, "", nil, nil, fmt.Errorf("error reading config file %s: %w", path, err)
+	}
+	doc = make(map[string]any)
+	if content != nil {
+		if err := json.Unmarshal(content, &doc); err != nil {
+			return nil, "", nil, nil, fmt.Errorf("the config file %s must be fixed by hand first: %w", path, err)
+		}
+	}
+	return active, path, content, doc, nil
+}
+
+// commit validates updated as the new content of the file at path, checks it
+// against active for loosened security, writes it and activates it. Must be
+// called with writeMu held.
+func (s *Store) commit(active *snapshot, path string, updated any, previous []byte, allowUnsafe bool) (loosened []string, backupPath string, err error) {
+	newContent, err := json.MarshalIndent(updated, "", "  ")
+	if err != nil {
+		return nil, "", fmt.Errorf("error marshalling config: %w", err)
+	}
+
+	s.mu.Lock()
+	result, err := s.build(path, newContent)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, "", fmt.Errorf("the change would make the config invalid: %w", err)
+	}
+
+	loosened = loosenings(active, result.snap)
+	if len(loosened) > 0 && !allowUnsafe {
+		return nil, "", fmt.Errorf("refusing to loosen security (%s): set %s=1 in the server's environment to allow it", strings.Join(loosened, "; "), AllowUnsafeEnv)
+	}
+
+	if err := writeFileAtomic(path, newContent, previous); err != nil {
+		return nil, "", err
+	}
+	if previous != nil {
+		backupPath = path + backupSuffix
+	}
+	if err := s.Reload(); err != nil {
+		return nil, "", err
+	}
+	return loosened, backupPath, nil
+}
+
+// writeFileAtomic replaces the file at path with content. previous, the current
+// content, is kept at the backup path unless the file is new.
+func writeFileAtomic(path string, content, previous []byte) error {
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	if previous != nil {
+		if err := os.WriteFile(path+backupSuffix, previous, perm); err != nil {
+			return fmt.Errorf("failed to back up config file: %w", err)
+		}
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // no-op once the file has been moved into place
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return fmt.Errorf("failed to set file permissions: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to move config into place: %w", err)
+	}
+	return nil
+}
+
+// setLogger changes where the store logs reloads
+func (s *Store) setLogger(logger *slog.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger = logger
+}
+
+// parseSnapshot decodes merged config content, which passed schema validation,
+// and checks what the schema can't: that allowed directories exist, that the
+// default shell is executable and that redaction patterns compile. Problems
+// are located through at.
+func parseSnapshot(content []byte, at func(key string, index int, format string, args ...any) Problem) (*snapshot, []Problem) {
+	var cfg ServerConfig
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return nil, []Problem{{Message: fmt.Sprintf("error unmarshalling config: %v", err)}}
+	}
+	// Ensure BlockedCommands is not nil if the key is missing
+	if cfg.BlockedCommands == nil {
+		cfg.BlockedCommands = []string{}
+	}
+
+	var problems []Problem
+	for i, dir := range cfg.AllowedDirectories {
+		expanded, err := pathpolicy.Expand(dir)
+		if err != nil {
+			problems = append(problems, at("allowedDirectories", i, "invalid directory %q: %v", dir, err))
+			continue
+		}
+		if info, err := os.Stat(expanded); err != nil {
+			problems = append(problems, at("allowedDirectories", i, "directory %s does not exist", expanded))
+		} else if !info.IsDir() {
+			problems = append(problems, at("allowedDirectories", i, "%s is not a directory", expanded))
+		}
+	}
+	if cfg.DefaultShell != nil && *cfg.DefaultShell != "" {
+		if _, err := exec.LookPath(*cfg.DefaultShell); err != nil {
+			problems = append(problems, at("defaultShell", -1, "default shell is not executable: %v", err))
+		}
+	}
+	for i, pattern := range cfg.LogRedactPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			problems = append(problems, at("logRedactPatterns", i, "invalid regular expression: %v", err))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+
+	policy, err := pathpolicy.New(cfg.AllowedDirectories)
+	if err != nil {
+		return nil, []Problem{at("allowedDirectories", -1, "%v", err)}
+	}
+	return &snapshot{config: &cfg, policy: policy}, nil
+}
+
+// defaultConfig is the built-in configuration the layers are applied to
+func defaultConfig() ServerConfig {
+	return ServerConfig{
+		BlockedCommands: append([]string(nil), defaultBlockedCommands...),
+	}
+}
@@ -0,0 +1,235 @@
+package config
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-mcp-testing/internal/pathpolicy"
+)
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// configWrites moves the modification time of every write forward so polling
+// notices changes even on filesystems with coarse timestamps
+var configWrites int
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	configWrites++
+	modTime := time.Now().Add(time.Duration(configWrites) * time.Second)
+	os.Chtimes(path, modTime, modTime)
+}
+
+func TestNewStore_DefaultsWithoutFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config", "config.json")
+	store := NewStore(path, discardLogger())
+
+	cfg, err := store.Current()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(cfg.BlockedCommands) != len(defaultBlockedCommands) {
+		t.Errorf("Expected the default blocked commands, got %v", cfg.BlockedCommands)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected no config file to be written")
+	}
+}
+
+func TestStore_KeepsLastGoodConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	writeConfig(t, path, `{"blockedCommands": ["rm"], "allowedDirectories": ["`+dir+`"]}`)
+	store := NewStore(path, discardLogger())
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "Invalid JSON", content: `{"blockedCommands": [`},
+		{name: "Wrong type", content: `{"blockedCommands": "rm"}`},
+		{name: "Invalid redact pattern", content: `{"logRedactPatterns": ["("]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeConfig(t, path, tt.content)
+			if err := store.Reload(); err == nil {
+				t.Fatal("Expected an error")
+			}
+			cfg, err := store.Current()
+			if err != nil || len(cfg.BlockedCommands) != 1 || cfg.BlockedCommands[0] != "rm" {
+				t.Errorf("Expected the last good config, got %v and error %v", cfg, err)
+			}
+			policy, err := store.PathPolicy()
+			if err != nil || len(policy.Roots()) != 1 {
+				t.Errorf("Expected the last good path policy, got %v and error %v", policy, err)
+			}
+		})
+	}
+
+	os.Remove(path)
+	if err := store.Reload(); err == nil {
+		t.Error("Expected an error for a removed file")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected a removed file not to be replaced with the defaults")
+	}
+}
+
+func TestStore_InvalidInitialConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, `not json`)
+	store := NewStore(path, discardLogger())
+
+	if _, err := store.Current(); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if _, err := store.PathPolicy(); err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	writeConfig(t, path, `{"blockedCommands": ["sudo"]}`)
+	if err := store.Reload(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg, err := store.Current(); err != nil || cfg.BlockedCommands[0] != "sudo" {
+		t.Errorf("Expected the fixed config, got %v and error %v", cfg, err)
+	}
+}
+
+func TestStore_Subscribe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, `{"blockedCommands": ["rm"]}`)
+	store := NewStore(path, discardLogger())
+
+	var notified []*ServerConfig
+	unsubscribe := store.Subscribe(func(cfg *ServerConfig) { notified = append(notified, cfg) })
+
+	// Same content, e.g. the file was only touched
+	writeConfig(t, path, `{"blockedCommands": ["rm"]}`)
+	store.Reload()
+	// Invalid content
+	writeConfig(t, path, `{`)
+	store.Reload()
+	if len(notified) != 0 {
+		t.Fatalf("Expected no notification, got %d", len(notified))
+	}
+
+	writeConfig(t, path, `{"blockedCommands": ["shutdown"]}`)
+	store.Reload()
+	if len(notified) != 1 || notified[0].BlockedCommands[0] != "shutdown" {
+		t.Fatalf("Expected one notification with the new config, got %v", notified)
+	}
+
+	unsubscribe()
+	writeConfig(t, path, `{"blockedCommands": ["reboot"]}`)
+	store.Reload()
+	if len(notified) != 1 {
+		t.Errorf("Expected no notification after unsubscribing, got %d", len(notified))
+	}
+}
+
+func TestStore_Watch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, `{"blockedCommands": ["rm"]}`)
+	store := NewStore(path, discardLogger())
+
+	reloaded := make(chan struct{}, 1)
+	store.Subscribe(func(cfg *ServerConfig) {
+		select {
+		case reloaded <- struct{}{}:
+		default:
+		}
+	})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go store.Watch(10*time.Millisecond, stop)
+
+	writeConfig(t, path, `{"blockedCommands": ["rm", "mkfs"]}`)
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the change to be picked up")
+	}
+	if cfg, _ := store.Current(); len(cfg.BlockedCommands) != 2 || cfg.BlockedCommands[1] != "mkfs" {
+		t.Errorf("Expected the new config, got %v", cfg.BlockedCommands)
+	}
+}
+
+func TestStore_WatchRefusesLoosening(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, `{"blockedCommands": ["rm"]}`)
+	store := NewStore(path, discardLogger())
+
+	// An edit made behind the store's back, e.g. by a command, is checked like Set
+	writeConfig(t, path, `{"blockedCommands": []}`)
+	if !store.changed() {
+		t.Fatal("Expected the edit to be noticed")
+	}
+	if err := store.reload(true); err == nil || !strings.Contains(err.Error(), "loosens security") {
+		t.Fatalf("Expected the loosening change to be refused, got: %v", err)
+	}
+	if cfg, _ := store.Current(); len(cfg.BlockedCommands) != 1 {
+		t.Errorf("Expected the last good config, got %v", cfg.BlockedCommands)
+	}
+
+	t.Setenv(AllowUnsafeEnv, "1")
+	if err := store.reload(true); err != nil {
+		t.Fatalf("Expected the change to be allowed with %s, got: %v", AllowUnsafeEnv, err)
+	}
+	if cfg, _ := store.Current(); len(cfg.BlockedCommands) != 0 {
+		t.Errorf("Expected the new config, got %v", cfg.BlockedCommands)
+	}
+}
+
+func TestStore_PathPolicyProtectsConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	writeConfig(t, path, `{"allowedDirectories": ["`+dir+`"]}`)
+	store := NewStore(path, discardLogger())
+
+	policy, err := store.PathPolicy()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	for _, protected := range []string{path, path + backupSuffix} {
+		if _, err := policy.Resolve(protected); !errors.Is(err, pathpolicy.ErrProtectedPath) {
+			t.Errorf("Expected %s to be protected, got: %v", protected, err)
+		}
+	}
+	if _, err := policy.Resolve(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("Expected other files to be allowed, got: %v", err)
+	}
+}
+
+// The config file shipped next to the executable has to load on any machine
+func TestShippedConfig(t *testing.T) {
+	store := NewStore(filepath.Join("..", "..", "config", configFileName), discardLogger())
+	if problems := store.Validate(); len(problems) > 0 {
+		t.Errorf("Expected the shipped config to be valid, got %v", problems)
+	}
+	cfg, err := store.Current()
+	if err != nil || len(cfg.AllowedDirectories) > 0 || cfg.DefaultShell != nil {
+		t.Fatalf("Expected a portable config without directories or a shell, got %+v (%v)", cfg, err)
+	}
+	// The file's list replaces the defaults, so it has to repeat every one of them
+	for _, command := range defaultBlockedCommands {
+		if !slices.Contains(cfg.BlockedCommands, command) {
+			t.Errorf("Expected the shipped config to block %s, got %v", command, cfg.BlockedCommands)
+		}
+	}
+}
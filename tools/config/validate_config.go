@@ -0,0 +1,29 @@
+package config
+
+import (
+	"github.com/localrivet/gomcp/server"
+)
+
+// ValidateConfigArgs defines the arguments for the validate_config tool.
+type ValidateConfigArgs struct{}
+
+// ValidateConfigResult defines the result structure for the validate_config tool
+type ValidateConfigResult struct {
+	Valid    bool      `json:"valid"`
+	Problems []Problem `json:"problems,omitempty"`
+}
+
+// HandleValidateConfig implements the logic for the validate_config tool
+// This handler checks the config files and environment overrides as they are now
+// against Schema and reports every problem with its location. While there are
+// problems the server keeps using the last valid configuration.
+func HandleValidateConfig(ctx *server.Context, args ValidateConfigArgs) (ValidateConfigResult, error) {
+	ctx.Logger.Info("Handling validate_config tool call")
+
+	store, err := getStore(ctx)
+	if err != nil {
+		return ValidateConfigResult{}, err
+	}
+	problems := store.Validate()
+	return ValidateConfigResult{Valid: len(problems) == 0, Problems: problems}, nil
+}
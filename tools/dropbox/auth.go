@@ -0,0 +1,239 @@
+package dropbox
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/localrivet/gomcp/server"
+)
+
+// tokenRefreshMargin is how long before expiry a cached access token is refreshed
+const tokenRefreshMargin = 5 * time.Minute
+
+// tokenRequestTimeout bounds a single request to the OAuth2 token endpoint
+const tokenRequestTimeout = 30 * time.Second
+
+// oauthTokenSource exchanges an OAuth2 refresh token for short-lived access
+// tokens and caches the current one in memory
+type oauthTokenSource struct {
+	appKey       string
+	appSecret    string
+	refreshToken string
+
+	mu          sync.Mutex
+	accessToken string
+	expiry      time.Time
+	now         func() time.Time
+}
+
+// WithRefreshToken authenticates with short-lived access tokens obtained from
+// refreshToken, refreshing them before they expire or when Dropbox reports them expired.
+// appSecret may be empty for refresh tokens obtained with PKCE.
+func WithRefreshToken(appKey, appSecret, refreshToken string) ClientOption {
+	return func(c *Client) {
+		c.oauth = &oauthTokenSource{
+			appKey:       appKey,
+			appSecret:    appSecret,
+			refreshToken: refreshToken,
+			now:          time.Now,
+		}
+	}
+}
+
+// OAuthToken is the response of the OAuth2 token endpoint
+type OAuthToken struct {
+	AccessToken  string `json:"access_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	AccountID    string `json:"account_id"`
+	Scope        string `json:"scope"`
+}
+
+// tokenURL returns the URL of the OAuth2 token endpoint
+func (c *Client) tokenURL() string {
+	return c.apiBaseURL + "/oauth2/token"
+}
+
+// authorize sets the Authorization header of an attempt. Requests are built with
+// the static API key; with a refresh token the cached access token replaces it,
+// refreshed first when it is about to expire or when forceRefresh is set.
+func (c *Client) authorize(ctx *server.Context, req *http.Request, forceRefresh bool) error {
+	if c.oauth == nil {
+		return nil
+	}
+
+	token, err := c.oauth.token(req.Context(), forceRefresh, func(reqCtx context.Context, form url.Values) (OAuthToken, error) {
+		ctx.Logger.Info("Refreshing Dropbox access token")
+		return requestToken(reqCtx, c.httpClient, c.tokenURL(), form)
+	})
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// token returns the cached access token, refreshing it with fetch when needed.
+// The lock is held while refreshing so concurrent calls share one refresh.
+func (s *oauthTokenSource) token(ctx context.Context, forceRefresh bool, fetch func(context.Context, url.Values) (OAuthToken, error)) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !forceRefresh && s.accessToken != "" && s.now().Add(tokenRefreshMargin).Before(s.expiry) {
+		return s.accessToken, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {s.refreshToken},
+		"client_id":     {s.appKey},
+	}
+	if s.appSecret != "" {
+		form.Set("client_secret", s.appSecret)
+	}
+
+	resp, err := fetch(ctx, form)
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh Dropbox access token: %w", err)
+	}
+	if resp.AccessToken == "" {
+		return "", fmt.Errorf("failed to refresh Dropbox access token: response has no access_token")
+	}
+
+	s.accessToken = resp.AccessToken
+	s.expiry = s.now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	return s.accessToken, nil
+}
+
+// requestToken posts a form to the OAuth2 token endpoint. Errors are returned as
+// *APIError tagged with the OAuth2 error code, e.g. invalid_grant.
+func requestToken(ctx context.Context, httpClient *http.Client, tokenURL string, form url.Values) (OAuthToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, tokenRequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return OAuthToken{}, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return OAuthToken{}, fmt.Errorf("token http request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return OAuthToken{}, fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var oauthErr struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Error != "" {
+			return OAuthToken{}, &APIError{StatusCode: resp.StatusCode, Endpoint: "oauth2/token", Tags: []string{oauthErr.Error}, UserMessage: oauthErr.ErrorDescription}
+		}
+		return OAuthToken{}, parseAPIError(resp.StatusCode, "oauth2/token", body)
+	}
+
+	var token OAuthToken
+	if err := json.Unmarshal(body, &token); err != nil {
+		return OAuthToken{}, fmt.Errorf("failed to unmarshal token response: %w", err)
+	}
+	return token, nil
+}
+
+// PKCEFlow obtains a refresh token with the OAuth2 authorization code flow and
+// PKCE: the user opens AuthorizeURL, approves the app and pastes the code shown
+// by Dropbox, which Exchange trades for tokens.
+type PKCEFlow struct {
+	appKey       string
+	appSecret    string
+	verifier     string
+	authorizeURL string
+	tokenURL     string
+	httpClient   *http.Client
+}
+
+// NewPKCEFlow starts an authorization for appKey with a fresh code verifier.
+// appSecret is optional; PKCE doesn't need it.
+func NewPKCEFlow(appKey, appSecret string) (*PKCEFlow, error) {
+	if appKey == "" {
+		return nil, fmt.Errorf("app key cannot be empty")
+	}
+
+	// 32 random bytes give a 43 character verifier, the minimum RFC 7636 allows
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("failed to generate code verifier: %w", err)
+	}
+
+	return &PKCEFlow{
+		appKey:       appKey,
+		appSecret:    appSecret,
+		verifier:     base64.RawURLEncoding.EncodeToString(random),
+		authorizeURL: DROPBOX_AUTHORIZE_URL,
+		tokenURL:     DROPBOX_API_URL + "/oauth2/token",
+		httpClient:   &http.Client{},
+	}, nil
+}
+
+// codeChallenge is the S256 challenge for the flow's verifier
+func (f *PKCEFlow) codeChallenge() string {
+	sum := sha256.Sum256([]byte(f.verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthorizeURL is the page where the user approves the app. It requests
+// offline access, so the exchange returns a refresh token.
+func (f *PKCEFlow) AuthorizeURL() string {
+	query := url.Values{
+		"client_id":             {f.appKey},
+		"response_type":         {"code"},
+		"code_challenge":        {f.codeChallenge()},
+		"code_challenge_method": {"S256"},
+		"token_access_type":     {"offline"},
+	}
+	return f.authorizeURL + "?" + query.Encode()
+}
+
+// Exchange trades the authorization code for an access and refresh token
+func (f *PKCEFlow) Exchange(ctx context.Context, code string) (OAuthToken, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return OAuthToken{}, fmt.Errorf("authorization code cannot be empty")
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {f.appKey},
+		"code_verifier": {f.verifier},
+	}
+	if f.appSecret != "" {
+		form.Set("client_secret", f.appSecret)
+	}
+
+	token, err := requestToken(ctx, f.httpClient, f.tokenURL, form)
+	if err != nil {
+		return OAuthToken{}, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+	if token.RefreshToken == "" {
+		return OAuthToken{}, fmt.Errorf("token response has no refresh_token")
+	}
+	return token, nil
+}
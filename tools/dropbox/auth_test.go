@@ -0,0 +1,199 @@
+package dropbox
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// oauthServer is a fake Dropbox API issuing numbered access tokens and
+// accepting only the latest one on list_folder
+type oauthServer struct {
+	mu        sync.Mutex
+	issued    int
+	forms     []url.Values
+	expireNow bool
+}
+
+func (o *oauthServer) server(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		if r.URL.Path == "/oauth2/token" {
+			r.ParseForm()
+			o.forms = append(o.forms, r.PostForm)
+			if r.PostForm.Get("refresh_token") == "revoked" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error": "invalid_grant", "error_description": "refresh token is invalid or revoked"}`))
+				return
+			}
+			o.issued++
+			fmt.Fprintf(w, `{"access_token": "access-%d", "expires_in": 14400, "token_type": "bearer"}`, o.issued)
+			return
+		}
+
+		if o.expireNow || r.Header.Get("Authorization") != fmt.Sprintf("Bearer access-%d", o.issued) {
+			o.expireNow = false
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error_summary": "expired_access_token/..", "error": {".tag": "expired_access_token"}}`))
+			return
+		}
+		w.Write([]byte(`{"entries": [], "has_more": false}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newOAuthClient(server *httptest.Server, refreshToken string) *Client {
+	return NewClient("", WithBaseURLs(server.URL, server.URL), WithHTTPClient(server.Client()), WithRetryPolicy(fastRetries),
+		WithRefreshToken("app-key", "app-secret", refreshToken))
+}
+
+func TestRefreshToken_FetchesAndCachesAccessToken(t *testing.T) {
+	fake := &oauthServer{}
+	client := newOAuthClient(fake.server(t), "refresh-1")
+
+	for range 2 {
+		if _, err := client.HandleListDropboxFolder(mockContext(), ListDropboxFoldersArgs{Path: "/"}); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	}
+
+	if len(fake.forms) != 1 {
+		t.Fatalf("Expected a single token refresh, got %d", len(fake.forms))
+	}
+	form := fake.forms[0]
+	if form.Get("grant_type") != "refresh_token" || form.Get("refresh_token") != "refresh-1" ||
+		form.Get("client_id") != "app-key" || form.Get("client_secret") != "app-secret" {
+		t.Errorf("Unexpected token request: %v", form)
+	}
+}
+
+func TestRefreshToken_RefreshesOnExpiredAccessToken(t *testing.T) {
+	fake := &oauthServer{}
+	client := newOAuthClient(fake.server(t), "refresh-1")
+
+	if _, err := client.HandleListDropboxFolder(mockContext(), ListDropboxFoldersArgs{Path: "/"}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// Dropbox revokes the cached token early; the client refreshes and retries once
+	fake.expireNow = true
+	fake.issued++
+	if _, err := client.HandleListDropboxFolder(mockContext(), ListDropboxFoldersArgs{Path: "/"}); err != nil {
+		t.Fatalf("Expected the call to succeed after refreshing, got: %v", err)
+	}
+	if len(fake.forms) != 2 {
+		t.Errorf("Expected a second token refresh, got %d", len(fake.forms))
+	}
+}
+
+func TestRefreshToken_InvalidGrant(t *testing.T) {
+	fake := &oauthServer{}
+	client := newOAuthClient(fake.server(t), "revoked")
+
+	_, err := client.HandleListDropboxFolder(mockContext(), ListDropboxFoldersArgs{Path: "/"})
+	if !errors.Is(err, ErrUnauthorized) || !strings.Contains(err.Error(), "dropbox-auth") {
+		t.Fatalf("Expected an unauthorized error pointing to dropbox-auth, got: %v", err)
+	}
+}
+
+func TestStaticAPIKey_ExpiredTokenNotRefreshed(t *testing.T) {
+	fake := &oauthServer{}
+	server := fake.server(t)
+	client := NewClient("static", WithBaseURLs(server.URL, server.URL), WithHTTPClient(server.Client()), WithRetryPolicy(fastRetries))
+
+	_, err := client.HandleListDropboxFolder(mockContext(), ListDropboxFoldersArgs{Path: "/"})
+	if !errors.Is(err, ErrExpiredAccessToken) || len(fake.forms) != 0 {
+		t.Fatalf("Expected an expired token error without refreshing, got %v after %d refreshes", err, len(fake.forms))
+	}
+}
+
+func TestOAuthTokenSource_RefreshesBeforeExpiry(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	source := &oauthTokenSource{appKey: "app-key", refreshToken: "refresh-1", now: func() time.Time { return now }}
+
+	fetches := 0
+	fetch := func(ctx context.Context, form url.Values) (OAuthToken, error) {
+		fetches++
+		if _, ok := form["client_secret"]; ok {
+			t.Errorf("Expected no client_secret without an app secret, got %v", form)
+		}
+		return OAuthToken{AccessToken: fmt.Sprintf("access-%d", fetches), ExpiresIn: 3600}, nil
+	}
+
+	tests := []struct {
+		advance  time.Duration
+		expected string
+	}{
+		{advance: 0, expected: "access-1"},
+		{advance: 50 * time.Minute, expected: "access-1"},
+		{advance: 6 * time.Minute, expected: "access-2"}, // within tokenRefreshMargin of expiry
+	}
+
+	for _, tt := range tests {
+		now = now.Add(tt.advance)
+		token, err := source.token(context.Background(), false, fetch)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if token != tt.expected {
+			t.Errorf("After %s expected %s, got %s", tt.advance, tt.expected, token)
+		}
+	}
+}
+
+func TestPKCEFlow(t *testing.T) {
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.PostForm
+		w.Write([]byte(`{"access_token": "access", "expires_in": 14400, "refresh_token": "refresh", "account_id": "dbid:1"}`))
+	}))
+	defer server.Close()
+
+	flow, err := NewPKCEFlow("app-key", "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	flow.tokenURL = server.URL
+	flow.httpClient = server.Client()
+
+	authorizeURL, err := url.Parse(flow.AuthorizeURL())
+	if err != nil {
+		t.Fatalf("Invalid authorize URL: %v", err)
+	}
+	query := authorizeURL.Query()
+	sum := sha256.Sum256([]byte(flow.verifier))
+	if query.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(sum[:]) || query.Get("code_challenge_method") != "S256" {
+		t.Errorf("Expected an S256 challenge of the verifier, got %v", query)
+	}
+	if query.Get("token_access_type") != "offline" || query.Get("client_id") != "app-key" || len(flow.verifier) < 43 {
+		t.Errorf("Unexpected authorize query %v for verifier %q", query, flow.verifier)
+	}
+
+	token, err := flow.Exchange(context.Background(), " the-code\n")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if token.RefreshToken != "refresh" {
+		t.Errorf("Expected refresh token, got %+v", token)
+	}
+	if form.Get("code") != "the-code" || form.Get("code_verifier") != flow.verifier || form.Get("grant_type") != "authorization_code" {
+		t.Errorf("Unexpected exchange request: %v", form)
+	}
+
+	if _, err := NewPKCEFlow("", ""); err == nil {
+		t.Error("Expected an error without an app key")
+	}
+}
@@ -0,0 +1,184 @@
+package dropbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/localrivet/gomcp/server"
+)
+
+// Client holds everything the Dropbox tools need to talk to the API.
+// It is constructed once in main.go and its handler methods are registered as tools.
+type Client struct {
+	apiKey         string
+	apiBaseURL     string
+	contentBaseURL string
+	httpClient     *http.Client
+	logger         *slog.Logger
+	retryPolicy    RetryPolicy
+	rpcTimeout     time.Duration
+	contentTimeout time.Duration
+	oauth          *oauthTokenSource
+}
+
+// ClientOption configures a Client
+type ClientOption func(*Client)
+
+// WithHTTPClient sets the http.Client used for requests
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithBaseURLs overrides the API and content hosts, e.g. to point at an httptest server
+func WithBaseURLs(apiBaseURL, contentBaseURL string) ClientOption {
+	return func(c *Client) {
+		c.apiBaseURL = strings.TrimSuffix(apiBaseURL, "/")
+		c.contentBaseURL = strings.TrimSuffix(contentBaseURL, "/")
+	}
+}
+
+// WithLogger sets the logger used outside of tool calls
+func WithLogger(logger *slog.Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// NewClient creates a Dropbox client authenticating with apiKey.
+// An empty apiKey is allowed so the server can start; tool calls will then fail with a clear error.
+func NewClient(apiKey string, opts ...ClientOption) *Client {
+	c := &Client{
+		apiKey:         apiKey,
+		apiBaseURL:     DROPBOX_API_URL,
+		contentBaseURL: DROPBOX_CONTENT_URL,
+		httpClient:     &http.Client{},
+		logger:         slog.Default(),
+		retryPolicy:    DefaultRetryPolicy,
+		rpcTimeout:     defaultRPCTimeout,
+		contentTimeout: defaultContentTimeout,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// filesURL returns the URL of a files RPC endpoint such as "list_folder"
+func (c *Client) filesURL(endpoint string) string {
+	return fmt.Sprintf("%s/2/files/%s", c.apiBaseURL, endpoint)
+}
+
+// contentURL returns the URL of a files content endpoint such as "download"
+func (c *Client) contentURL(endpoint string) string {
+	return fmt.Sprintf("%s/2/files/%s", c.contentBaseURL, endpoint)
+}
+
+// requireAPIKey returns an error describing the action that can't be performed without a key
+func (c *Client) requireAPIKey(ctx *server.Context, action string) error {
+	if c.apiKey == "" && c.oauth == nil {
+		ctx.Logger.Info("$DROPBOX_API_KEY not set")
+		return fmt.Errorf("$DROPBOX_API_KEY not set, unable to %s. Set it, or $DROPBOX_APP_KEY and $DROPBOX_REFRESH_TOKEN", action)
+	}
+	return nil
+}
+
+// newRPCRequest creates an authenticated JSON request to a files RPC endpoint
+func (c *Client) newRPCRequest(endpoint string, body any) (*http.Request, error) {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+	req, err := http.NewRequest("POST", c.filesURL(endpoint), bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new HTTP request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// doRPCRequest sends body to a files RPC endpoint and decodes the JSON response into result (if not nil)
+func (c *Client) doRPCRequest(ctx *server.Context, endpoint string, body any, result any) error {
+	req, err := c.newRPCRequest(endpoint, body)
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", endpoint, err)
+	}
+
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return fmt.Errorf("%s http request failed: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return handleFailedHttpReq(resp)
+	}
+
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to unmarshal %s response: %w", endpoint, err)
+	}
+	return nil
+}
+
+// newContentRequest creates an authenticated request to a files content endpoint,
+// passing apiArg in the Dropbox-API-Arg header and body (if any) as the raw request body.
+// The body is a section so its length is known up front and it can be re-read.
+func (c *Client) newContentRequest(endpoint string, apiArg any, body *io.SectionReader) (*http.Request, error) {
+	header, err := apiArgHeader(apiArg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", c.contentURL(endpoint), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("Dropbox-API-Arg", header)
+	req.Header.Set("Content-Type", "text/plain")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.ContentLength = body.Size()
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(io.NewSectionReader(body, 0, body.Size())), nil
+		}
+		req.Body, _ = req.GetBody()
+	}
+	return req, nil
+}
+
+// apiArgHeader encodes an API argument for the Dropbox-API-Arg header. Header values
+// must be ASCII, so non-ASCII characters (e.g. in file names) are escaped as \uXXXX.
+func apiArgHeader(apiArg any) (string, error) {
+	data, err := json.Marshal(apiArg)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal API argument: %w", err)
+	}
+
+	var header strings.Builder
+	for _, r := range string(data) {
+		switch {
+		case r < 0x80:
+			header.WriteRune(r)
+		case r > 0xFFFF:
+			r1, r2 := utf16.EncodeRune(r)
+			fmt.Fprintf(&header, "\\u%04x\\u%04x", r1, r2)
+		default:
+			fmt.Fprintf(&header, "\\u%04x", r)
+		}
+	}
+	return header.String(), nil
+}
@@ -0,0 +1,52 @@
+package dropbox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+)
+
+// contentHashBlockSize is the block size of Dropbox's content hash
+const contentHashBlockSize = 4 * 1024 * 1024 // 4MB
+
+// contentHasher computes the Dropbox content_hash of a stream: the SHA-256 of the
+// concatenated SHA-256 digests of each 4MB block.
+// See https://www.dropbox.com/developers/reference/content-hash
+type contentHasher struct {
+	blockDigests []byte
+	block        hash.Hash
+	blockSize    int
+}
+
+// newContentHasher creates a hasher for the Dropbox content hash
+func newContentHasher() *contentHasher {
+	return &contentHasher{block: sha256.New()}
+}
+
+// Write implements io.Writer, feeding data into the current block
+func (h *contentHasher) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := min(contentHashBlockSize-h.blockSize, len(p))
+		h.block.Write(p[:n])
+		h.blockSize += n
+		p = p[n:]
+
+		if h.blockSize == contentHashBlockSize {
+			h.blockDigests = h.block.Sum(h.blockDigests)
+			h.block.Reset()
+			h.blockSize = 0
+		}
+	}
+	return written, nil
+}
+
+// Sum returns the hex-encoded content hash of everything written so far
+func (h *contentHasher) Sum() string {
+	digests := h.blockDigests
+	if h.blockSize > 0 {
+		digests = h.block.Sum(append([]byte(nil), digests...))
+	}
+	overall := sha256.Sum256(digests)
+	return hex.EncodeToString(overall[:])
+}
@@ -0,0 +1,59 @@
+package dropbox
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+// referenceContentHash computes the Dropbox content hash of data in one pass
+func referenceContentHash(data []byte) string {
+	var digests []byte
+	for start := 0; start < len(data); start += contentHashBlockSize {
+		end := min(start+contentHashBlockSize, len(data))
+		digest := sha256.Sum256(data[start:end])
+		digests = append(digests, digest[:]...)
+	}
+	overall := sha256.Sum256(digests)
+	return hex.EncodeToString(overall[:])
+}
+
+func TestContentHasher(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{name: "Empty", size: 0},
+		{name: "Small", size: 11},
+		{name: "Exactly one block", size: contentHashBlockSize},
+		{name: "Multiple blocks", size: 2*contentHashBlockSize + 12345},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := bytes.Repeat([]byte("dropbox!"), tt.size/8+1)[:tt.size]
+
+			// Write in uneven chunks to exercise block boundaries
+			hasher := newContentHasher()
+			for chunk := 1; len(data) > 0; chunk *= 3 {
+				n := min(chunk, len(data))
+				hasher.Write(data[:n])
+				data = data[n:]
+			}
+
+			expected := referenceContentHash(bytes.Repeat([]byte("dropbox!"), tt.size/8+1)[:tt.size])
+			if got := hasher.Sum(); got != expected {
+				t.Errorf("Expected %s, got %s", expected, got)
+			}
+		})
+	}
+}
+
+func TestContentHasher_EmptyMatchesDropbox(t *testing.T) {
+	// Dropbox reports this content_hash for empty files
+	expected := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	if got := newContentHasher().Sum(); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
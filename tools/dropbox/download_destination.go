@@ -0,0 +1,211 @@
+package dropbox
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang-mcp-testing/tools/config"
+
+	"github.com/localrivet/gomcp/server"
+)
+
+// conflictPolicy decides what happens when a download's local path already exists
+type conflictPolicy string
+
+const (
+	conflictOverwrite conflictPolicy = "overwrite"
+	conflictSkip      conflictPolicy = "skip"
+	conflictRename    conflictPolicy = "rename"
+	conflictFail      conflictPolicy = "fail"
+)
+
+// maxRenameAttempts bounds the numeric suffixes tried by the rename policy
+const maxRenameAttempts = 1000
+
+// parseConflictPolicy validates the on_conflict argument, defaulting to rename
+func parseConflictPolicy(value string) (conflictPolicy, error) {
+	switch policy := conflictPolicy(strings.ToLower(value)); policy {
+	case "":
+		return conflictRename, nil
+	case conflictOverwrite, conflictSkip, conflictRename, conflictFail:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid on_conflict %q, expected one of overwrite, skip, rename, fail", value)
+	}
+}
+
+// resolveDownloadPath returns the local path a download should be saved to, checked
+// against the allowed directories. The destination falls back to the configured
+// downloadDirectory and then ~/Desktop/wip; directories keep the remote file name.
+func resolveDownloadPath(ctx *server.Context, destination, remoteName string) (string, error) {
+	if destination == "" {
+		dir, err := defaultDownloadDirectory(ctx)
+		if err != nil {
+			return "", err
+		}
+		destination = dir + string(filepath.Separator)
+	}
+
+	isDir := strings.HasSuffix(destination, "/") || strings.HasSuffix(destination, string(filepath.Separator))
+	policy, err := config.GetPathPolicy(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to load path policy: %w", err)
+	}
+	resolved, err := policy.Resolve(destination)
+	if err != nil {
+		return "", fmt.Errorf("invalid download destination: %w", err)
+	}
+
+	if info, err := os.Stat(resolved); err == nil && info.IsDir() {
+		isDir = true
+	}
+	if !isDir {
+		return resolved, nil
+	}
+
+	name := filepath.Base(remoteName)
+	if name == "" || name == "." || name == string(filepath.Separator) {
+		return "", fmt.Errorf("cannot save to a directory without a remote file name")
+	}
+	return policy.Resolve(filepath.Join(resolved, name))
+}
+
+// defaultDownloadDirectory returns the configured downloadDirectory, or ~/Desktop/wip
+func defaultDownloadDirectory(ctx *server.Context) (string, error) {
+	cfg, err := config.Load(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.DownloadDirectory != nil && *cfg.DownloadDirectory != "" {
+		return *cfg.DownloadDirectory, nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(homeDir, "Desktop", "wip"), nil
+}
+
+// downloadOutcome describes a file saved by saveDownloadedFile
+type downloadOutcome struct {
+	path         string
+	bytesWritten int64
+	contentHash  string
+	verified     bool
+}
+
+// saveDownloadedFile streams body to a temp file next to localPath while computing its
+// content hash, verifies it against expectedHash (when known), and then moves it into
+// place following the conflict policy. Nothing is left at localPath if any step fails.
+func saveDownloadedFile(ctx *server.Context, localPath string, body io.Reader, expectedHash string, policy conflictPolicy) (downloadOutcome, error) {
+	// Create the destination directory if it doesn't exist
+	dir := filepath.Dir(localPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return downloadOutcome{}, fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(localPath)+".*.download")
+	if err != nil {
+		return downloadOutcome{}, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // no-op once the file has been moved into place
+
+	hasher := newContentHasher()
+	written, err := io.Copy(io.MultiWriter(tmp, hasher), body)
+	if err != nil {
+		tmp.Close()
+		return downloadOutcome{}, fmt.Errorf("failed to stream file content: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return downloadOutcome{}, fmt.Errorf("failed to sync temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return downloadOutcome{}, fmt.Errorf("failed to close temp file: %w", err)
+	}
+	// CreateTemp uses 0600, match the permissions of other written files
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return downloadOutcome{}, fmt.Errorf("failed to set file permissions: %w", err)
+	}
+
+	outcome := downloadOutcome{bytesWritten: written, contentHash: hasher.Sum()}
+	if expectedHash != "" {
+		if !strings.EqualFold(outcome.contentHash, expectedHash) {
+			return downloadOutcome{}, fmt.Errorf("content hash mismatch: expected %s, got %s", expectedHash, outcome.contentHash)
+		}
+		outcome.verified = true
+	}
+
+	outcome.path, err = placeFile(tmpPath, localPath, policy)
+	if err != nil {
+		return downloadOutcome{}, err
+	}
+
+	ctx.Logger.Info("File saved successfully", "file_path", outcome.path, "bytes", outcome.bytesWritten, "verified", outcome.verified)
+	return outcome, nil
+}
+
+// placeFile atomically moves tmpPath to localPath following the conflict policy
+// and returns the path the file ended up at
+func placeFile(tmpPath, localPath string, policy conflictPolicy) (string, error) {
+	if policy == conflictOverwrite {
+		if err := os.Rename(tmpPath, localPath); err != nil {
+			return "", fmt.Errorf("failed to move file into place: %w", err)
+		}
+		return localPath, nil
+	}
+
+	candidate := localPath
+	for attempt := 1; ; attempt++ {
+		err := linkNoClobber(tmpPath, candidate)
+		if err == nil {
+			return candidate, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", fmt.Errorf("failed to move file into place: %w", err)
+		}
+
+		switch policy {
+		case conflictFail, conflictSkip:
+			// Skip only reaches here if the file appeared after the existence check
+			return "", fmt.Errorf("local file already exists: %s", candidate)
+		case conflictRename:
+			if attempt > maxRenameAttempts {
+				return "", fmt.Errorf("no free file name found for %s", localPath)
+			}
+			candidate = suffixedPath(localPath, attempt)
+		}
+	}
+}
+
+// linkNoClobber moves oldPath to newPath, failing with fs.ErrExist if newPath exists.
+// A hard link makes the check atomic; filesystems without hard links fall back to
+// an existence check followed by a rename.
+func linkNoClobber(oldPath, newPath string) error {
+	err := os.Link(oldPath, newPath)
+	if err == nil {
+		return os.Remove(oldPath)
+	}
+	if errors.Is(err, fs.ErrExist) {
+		return err
+	}
+
+	if _, statErr := os.Lstat(newPath); statErr == nil {
+		return fs.ErrExist
+	}
+	return os.Rename(oldPath, newPath)
+}
+
+// suffixedPath inserts " (n)" before the extension, e.g. report.pdf -> report (1).pdf
+func suffixedPath(path string, n int) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + " (" + strconv.Itoa(n) + ")" + ext
+}
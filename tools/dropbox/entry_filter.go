@@ -0,0 +1,89 @@
+package dropbox
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// entryFilter selects listing entries by tag, file extension and modification time
+type entryFilter struct {
+	tags          map[string]bool
+	extensions    map[string]bool
+	modifiedSince time.Time
+}
+
+// newEntryFilter validates the filter arguments of the list tool.
+// Without tags, files and folders are returned and deleted entries are not.
+func newEntryFilter(tags, extensions []string, modifiedSince string) (*entryFilter, error) {
+	filter := &entryFilter{tags: map[string]bool{}, extensions: map[string]bool{}}
+
+	if len(tags) == 0 {
+		tags = []string{TagFile, TagFolder}
+	}
+	for _, tag := range tags {
+		switch tag {
+		case TagFile, TagFolder, TagDeleted:
+			filter.tags[tag] = true
+		default:
+			return nil, fmt.Errorf("invalid tag %q, expected one of file, folder, deleted", tag)
+		}
+	}
+
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+		if ext == "" {
+			return nil, fmt.Errorf("extensions cannot be empty")
+		}
+		filter.extensions[ext] = true
+	}
+
+	if modifiedSince != "" {
+		since, err := time.Parse(time.RFC3339, modifiedSince)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modified_since, expected RFC 3339 time: %w", err)
+		}
+		filter.modifiedSince = since
+	}
+
+	return filter, nil
+}
+
+// apply returns the entries matching the filter
+func (f *entryFilter) apply(entries DropboxEntries) DropboxEntries {
+	filtered := make(DropboxEntries, 0, len(entries))
+	for _, entry := range entries {
+		if f.matches(entry) {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered
+}
+
+// matches reports whether a single entry passes the filter.
+// Extension and time filters only apply to files.
+func (f *entryFilter) matches(entry DropboxEntry) bool {
+	if !f.tags[entry.Tag] {
+		return false
+	}
+	if entry.File == nil {
+		return true
+	}
+
+	if len(f.extensions) > 0 {
+		ext := strings.ToLower(strings.TrimPrefix(path.Ext(entry.File.Name), "."))
+		if !f.extensions[ext] {
+			return false
+		}
+	}
+
+	if !f.modifiedSince.IsZero() {
+		modified, err := time.Parse(time.RFC3339, entry.File.ServerModified)
+		if err != nil || modified.Before(f.modifiedSince) {
+			return false
+		}
+	}
+
+	return true
+}
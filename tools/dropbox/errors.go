@@ -0,0 +1,212 @@
+package dropbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Sentinels for the Dropbox errors callers commonly need to tell apart.
+// Use errors.Is(err, ErrNotFound), or errors.As with *APIError for the details.
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("conflict")
+	ErrInsufficientSpace  = errors.New("insufficient space")
+	ErrNoWritePermission  = errors.New("no write permission")
+	ErrMalformedPath      = errors.New("malformed path")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrExpiredAccessToken = errors.New("expired access token")
+	ErrRateLimited        = errors.New("rate limited")
+	ErrServerError        = errors.New("dropbox server error")
+)
+
+// tagSentinels maps Dropbox error tags, found anywhere in the .tag hierarchy, to sentinels
+var tagSentinels = map[string]error{
+	"not_found":                 ErrNotFound,
+	"conflict":                  ErrConflict,
+	"insufficient_space":        ErrInsufficientSpace,
+	"no_write_permission":       ErrNoWritePermission,
+	"malformed_path":            ErrMalformedPath,
+	"invalid_access_token":      ErrUnauthorized,
+	"expired_access_token":      ErrExpiredAccessToken,
+	"invalid_grant":             ErrUnauthorized,
+	"too_many_requests":         ErrRateLimited,
+	"too_many_write_operations": ErrRateLimited,
+}
+
+// errorHints are actionable explanations shown to the MCP client for well known error tags
+var errorHints = map[string]string{
+	"not_found":                 "The file or folder does not exist. Check the path with dropbox_list_dropbox_folder or dropbox_search.",
+	"conflict":                  "Something already exists at the destination. Use autorename or choose another path.",
+	"insufficient_space":        "The Dropbox account is out of space. Free up space or upgrade the plan.",
+	"no_write_permission":       "The account does not have permission to write to this path.",
+	"malformed_path":            "The path is not valid. Dropbox paths start with / and use / as separator.",
+	"disallowed_name":           "Dropbox does not allow this file or folder name.",
+	"invalid_access_token":      "The Dropbox access token is invalid. Check $DROPBOX_API_KEY.",
+	"expired_access_token":      "The Dropbox access token has expired. Provide a fresh token in $DROPBOX_API_KEY, or configure a refresh token with dropbox-auth.",
+	"invalid_grant":             "The Dropbox refresh token is invalid or revoked. Run dropbox-auth to obtain a new one.",
+	"missing_scope":             "The Dropbox app is missing a permission scope required for this call.",
+	"too_many_requests":         "Dropbox is rate limiting requests. Try again shortly.",
+	"too_many_write_operations": "Too many concurrent writes to this Dropbox namespace. Try again shortly.",
+}
+
+// APIError is an error response from the Dropbox API, parsed from its
+// error_summary and the .tag hierarchy of its error union.
+type APIError struct {
+	// StatusCode is the HTTP status, or 0 for errors reported inside a successful response such as a failed batch job
+	StatusCode int
+	// Endpoint is the API route that failed, e.g. "files/list_folder"
+	Endpoint string
+	// Summary is the raw error_summary, e.g. "path/not_found/.."
+	Summary string
+	// Tags is the .tag hierarchy from outermost to innermost, e.g. ["path", "not_found"]
+	Tags []string
+	// UserMessage is the localized message Dropbox intends for end users, if any
+	UserMessage string
+	// Body is the response body when it isn't a structured error, e.g. for 400 and 5xx responses
+	Body string
+}
+
+// Error returns a message naming the endpoint, status, error tags and, when known, what to do about it
+func (e *APIError) Error() string {
+	var msg strings.Builder
+	msg.WriteString("Dropbox")
+	if e.Endpoint != "" {
+		msg.WriteString(" " + e.Endpoint)
+	}
+	if e.StatusCode != 0 {
+		fmt.Fprintf(&msg, " request failed with status %d", e.StatusCode)
+	} else {
+		msg.WriteString(" request failed")
+	}
+
+	switch {
+	case len(e.Tags) > 0:
+		msg.WriteString(": " + strings.Join(e.Tags, "/"))
+	case e.Body != "":
+		msg.WriteString(": " + e.Body)
+	}
+	if e.UserMessage != "" {
+		msg.WriteString(" (" + e.UserMessage + ")")
+	}
+	if hint := e.Hint(); hint != "" {
+		msg.WriteString(". " + hint)
+	}
+	return msg.String()
+}
+
+// Hint returns an actionable explanation of the innermost known tag, or "" if there is none
+func (e *APIError) Hint() string {
+	for i := len(e.Tags) - 1; i >= 0; i-- {
+		if hint, ok := errorHints[e.Tags[i]]; ok {
+			return hint
+		}
+	}
+	return ""
+}
+
+// HasTag reports whether tag appears anywhere in the error's .tag hierarchy
+func (e *APIError) HasTag(tag string) bool {
+	for _, t := range e.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Is matches the sentinels by error tag and, for authentication, rate limiting
+// and server errors, by HTTP status
+func (e *APIError) Is(target error) bool {
+	for _, tag := range e.Tags {
+		if tagSentinels[tag] == target {
+			return true
+		}
+	}
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServerError:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// apiErrorResponse is the JSON body of a Dropbox error response
+type apiErrorResponse struct {
+	ErrorSummary string          `json:"error_summary"`
+	Error        json.RawMessage `json:"error"`
+	UserMessage  *struct {
+		Text string `json:"text"`
+	} `json:"user_message"`
+}
+
+// parseAPIError builds an APIError from a response body. Structured errors
+// carry error_summary and an error union, anything else is kept as Body.
+func parseAPIError(statusCode int, endpoint string, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode, Endpoint: endpoint}
+
+	var resp apiErrorResponse
+	if json.Unmarshal(body, &resp) != nil || (resp.ErrorSummary == "" && resp.Error == nil) {
+		apiErr.Body = strings.TrimSpace(string(body))
+		return apiErr
+	}
+
+	apiErr.Summary = resp.ErrorSummary
+	apiErr.Tags = errorTags(resp.Error)
+	if len(apiErr.Tags) == 0 {
+		// Some errors, such as rate limits, nest the union under a field; the summary still has the tags
+		apiErr.Tags = summaryTags(resp.ErrorSummary)
+	}
+	if resp.UserMessage != nil {
+		apiErr.UserMessage = resp.UserMessage.Text
+	}
+	return apiErr
+}
+
+// errorTags follows a nested Dropbox error union such as
+// {".tag": "path", "path": {".tag": "not_found"}} and returns ["path", "not_found"]
+func errorTags(raw json.RawMessage) []string {
+	var tags []string
+	for len(raw) > 0 {
+		var union map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &union); err != nil {
+			break
+		}
+		var tag string
+		if err := json.Unmarshal(union[".tag"], &tag); err != nil || tag == "" {
+			break
+		}
+		tags = append(tags, tag)
+		raw = union[tag]
+	}
+	return tags
+}
+
+// summaryTags splits an error_summary such as "path/not_found/.." into its tags,
+// dropping the trailing dots Dropbox appends
+func summaryTags(summary string) []string {
+	var tags []string
+	for _, part := range strings.Split(summary, "/") {
+		part = strings.TrimSpace(part)
+		if part == "" || strings.Trim(part, ".") == "" {
+			continue
+		}
+		tags = append(tags, part)
+	}
+	return tags
+}
+
+// describeDropboxError flattens a nested Dropbox error union such as
+// {".tag": "from_lookup", "from_lookup": {".tag": "not_found"}} into "from_lookup/not_found"
+func describeDropboxError(raw json.RawMessage) string {
+	tags := errorTags(raw)
+	if len(tags) == 0 {
+		return "unknown error"
+	}
+	return strings.Join(tags, "/")
+}
@@ -0,0 +1,136 @@
+package dropbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseAPIError(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		tags       []string
+		sentinel   error
+		notMatched error
+		contains   string
+	}{
+		{
+			name:       "Nested path not found",
+			status:     http.StatusConflict,
+			body:       `{"error_summary": "path/not_found/..", "error": {".tag": "path", "path": {".tag": "not_found"}}}`,
+			tags:       []string{"path", "not_found"},
+			sentinel:   ErrNotFound,
+			notMatched: ErrInsufficientSpace,
+			contains:   "path/not_found. The file or folder does not exist",
+		},
+		{
+			name:       "Insufficient space with user message",
+			status:     http.StatusConflict,
+			body:       `{"error_summary": "path/insufficient_space/...", "error": {".tag": "path", "path": {".tag": "insufficient_space"}}, "user_message": {"text": "Your Dropbox is full.", "locale": "en"}}`,
+			tags:       []string{"path", "insufficient_space"},
+			sentinel:   ErrInsufficientSpace,
+			notMatched: ErrNotFound,
+			contains:   "(Your Dropbox is full.)",
+		},
+		{
+			name:       "Expired token",
+			status:     http.StatusUnauthorized,
+			body:       `{"error_summary": "expired_access_token/..", "error": {".tag": "expired_access_token"}}`,
+			tags:       []string{"expired_access_token"},
+			sentinel:   ErrExpiredAccessToken,
+			notMatched: ErrRateLimited,
+			contains:   "status 401",
+		},
+		{
+			name:       "Rate limit falls back to summary tags",
+			status:     http.StatusTooManyRequests,
+			body:       `{"error_summary": "too_many_requests/..", "error": {"reason": {".tag": "too_many_requests"}, "retry_after": 1}}`,
+			tags:       []string{"too_many_requests"},
+			sentinel:   ErrRateLimited,
+			notMatched: ErrServerError,
+			contains:   "Try again shortly",
+		},
+		{
+			name:       "Plain text bad request",
+			status:     http.StatusBadRequest,
+			body:       "Error in call to API function \"files/list_folder\": bad path\n",
+			sentinel:   nil,
+			notMatched: ErrNotFound,
+			contains:   `status 400: Error in call to API function "files/list_folder": bad path`,
+		},
+		{
+			name:       "Server error",
+			status:     http.StatusServiceUnavailable,
+			body:       "",
+			sentinel:   ErrServerError,
+			notMatched: ErrUnauthorized,
+			contains:   "status 503",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiErr := parseAPIError(tt.status, "files/list_folder", []byte(tt.body))
+			var err error = fmt.Errorf("wrapped: %w", apiErr)
+
+			if strings.Join(apiErr.Tags, "/") != strings.Join(tt.tags, "/") {
+				t.Errorf("Expected tags %v, got %v", tt.tags, apiErr.Tags)
+			}
+			if tt.sentinel != nil && !errors.Is(err, tt.sentinel) {
+				t.Errorf("Expected error to match %v", tt.sentinel)
+			}
+			if errors.Is(err, tt.notMatched) {
+				t.Errorf("Expected error not to match %v", tt.notMatched)
+			}
+			if !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("Expected error to contain %q, got: %s", tt.contains, err.Error())
+			}
+		})
+	}
+}
+
+func TestHandleFailedHttpReq_AsAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"error_summary": "from_lookup/not_found/", "error": {".tag": "from_lookup", "from_lookup": {".tag": "not_found"}}}`))
+	}))
+	defer server.Close()
+	client := NewClient("test_api_key_123", WithBaseURLs(server.URL, server.URL), WithHTTPClient(server.Client()))
+
+	_, err := client.HandleMove(mockContext(), RelocationArgs{FromPath: "/a", ToPath: "/b"})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected an *APIError, got %T: %v", err, err)
+	}
+	if apiErr.Endpoint != "files/move_v2" || apiErr.StatusCode != http.StatusConflict || apiErr.Summary != "from_lookup/not_found/" {
+		t.Errorf("Unexpected API error: %+v", apiErr)
+	}
+	if !apiErr.HasTag("from_lookup") || !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected from_lookup/not_found, got %v", apiErr.Tags)
+	}
+	if strings.Contains(err.Error(), "map[") {
+		t.Errorf("Expected no stringified maps in the message, got: %s", err.Error())
+	}
+}
+
+func TestDescribeDropboxError(t *testing.T) {
+	tests := map[string]string{
+		`{".tag": "path_lookup", "path_lookup": {".tag": "not_found"}}`: "path_lookup/not_found",
+		`{".tag": "too_many_write_operations"}`:                         "too_many_write_operations",
+		`"unexpected"`:                                                  "unknown error",
+		``:                                                              "unknown error",
+	}
+
+	for raw, expected := range tests {
+		if got := describeDropboxError(json.RawMessage(raw)); got != expected {
+			t.Errorf("describeDropboxError(%s) = %q, want %q", raw, got, expected)
+		}
+	}
+}
@@ -3,16 +3,25 @@ package dropbox
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
-	"path/filepath"
 
 	"github.com/localrivet/gomcp/server"
 )
 
 type FilesDownloadArgs struct {
-	Path string `json:"path"`
+	Path        string `json:"path"`
+	Destination string `json:"destination,omitempty" description:"Local file or directory to save to. A directory (existing, or ending in /) keeps the remote file name. Defaults to the configured downloadDirectory."`
+	OnConflict  string `json:"on_conflict,omitempty" description:"What to do when the local file exists: overwrite, skip, rename (add a numeric suffix, the default) or fail."`
+}
+
+// FilesDownloadResult is the result of the files_download tool
+type FilesDownloadResult struct {
+	DropboxFileMetadata
+	LocalPath           string `json:"local_path"`
+	Skipped             bool   `json:"skipped,omitempty"`
+	BytesWritten        int64  `json:"bytes_written"`
+	ContentHashVerified bool   `json:"content_hash_verified"`
 }
 
 type FileLockInfo struct {
@@ -37,6 +46,29 @@ type SharingInfo struct {
 	ReadOnly             bool   `json:"read_only"`
 }
 
+type Dimensions struct {
+	Height int64 `json:"height"`
+	Width  int64 `json:"width"`
+}
+
+type GpsCoordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type MediaMetadata struct {
+	Tag        string          `json:".tag"`
+	Dimensions *Dimensions     `json:"dimensions,omitempty"`
+	Location   *GpsCoordinates `json:"location,omitempty"`
+	TimeTaken  string          `json:"time_taken,omitempty"`
+	Duration   int64           `json:"duration,omitempty"`
+}
+
+type MediaInfo struct {
+	Tag      string         `json:".tag"`
+	Metadata *MediaMetadata `json:"metadata,omitempty"`
+}
+
 type DropboxFileMetadata struct {
 	ClientModified           string          `json:"client_modified"`
 	ContentHash              string          `json:"content_hash"`
@@ -44,6 +76,7 @@ type DropboxFileMetadata struct {
 	HasExplicitSharedMembers bool            `json:"has_explicit_shared_members"`
 	ID                       string          `json:"id"`
 	IsDownloadable           bool            `json:"is_downloadable"`
+	MediaInfo                *MediaInfo      `json:"media_info,omitempty"`
 	Name                     string          `json:"name"`
 	PathDisplay              string          `json:"path_display"`
 	PathLower                string          `json:"path_lower"`
@@ -56,59 +89,70 @@ type DropboxFileMetadata struct {
 
 // HandleFilesDownload implements the logic the files.download tool
 // This handler downloads the file at the provided FilesDownloadArgs.Path
-func HandleFilesDownload(ctx *server.Context, args FilesDownloadArgs) (DropboxFileMetadata, error) {
+// and saves it to FilesDownloadArgs.Destination according to the conflict policy
+func (c *Client) HandleFilesDownload(ctx *server.Context, args FilesDownloadArgs) (FilesDownloadResult, error) {
 	ctx.Logger.Info("Handling FilesDownload tool call")
 
-	// Get API key
-	apiKey := os.Getenv("DROPBOX_API_KEY")
-	if apiKey == "" {
-		ctx.Logger.Info("$DROPBOX_API_KEY not set")
-		return DropboxFileMetadata{}, fmt.Errorf("$DROPBOX_API_KEY not set, unable to download file")
+	if err := c.requireAPIKey(ctx, "download file"); err != nil {
+		return FilesDownloadResult{}, err
+	}
+
+	policy, err := parseConflictPolicy(args.OnConflict)
+	if err != nil {
+		return FilesDownloadResult{}, err
 	}
 
 	// Create the request
-	req, err := createDownloadRequest(ctx, args, apiKey)
+	req, err := c.createDownloadRequest(ctx, args)
 	if err != nil {
-		return DropboxFileMetadata{}, fmt.Errorf("failed to create download request: %w", err)
+		return FilesDownloadResult{}, fmt.Errorf("failed to create download request: %w", err)
 	}
 
 	// Execute the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := c.do(ctx, req)
 	if err != nil {
-		return DropboxFileMetadata{}, fmt.Errorf("download http request failed: %w", err)
+		return FilesDownloadResult{}, fmt.Errorf("download http request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		err := handleFailedHttpReq(resp)
-		return DropboxFileMetadata{}, err
+		return FilesDownloadResult{}, err
 	}
 
 	// Parse metadata from response header
 	metadata, err := parseMetadataFromResponse(resp)
 	if err != nil {
-		return DropboxFileMetadata{}, fmt.Errorf("failed to parse metadata: %w", err)
+		return FilesDownloadResult{}, fmt.Errorf("failed to parse metadata: %w", err)
 	}
 
-	// Read file content
-	fileContent, err := io.ReadAll(resp.Body)
+	// Work out where the file goes before reading the body, so skipped files aren't read
+	localPath, err := resolveDownloadPath(ctx, args.Destination, metadata.Name)
 	if err != nil {
-		return DropboxFileMetadata{}, fmt.Errorf("failed to read file content: %w", err)
+		return FilesDownloadResult{}, err
+	}
+	result := FilesDownloadResult{DropboxFileMetadata: metadata, LocalPath: localPath}
+	if _, err := os.Stat(localPath); err == nil && policy == conflictSkip {
+		ctx.Logger.Info("Local file exists, skipping download", "local_path", localPath)
+		result.Skipped = true
+		return result, nil
 	}
 
-	// Save file to Desktop/wip folder
-	err = saveFileToDesktop(ctx, metadata.Name, fileContent)
+	// Stream the file content to the destination, verifying its content hash
+	outcome, err := saveDownloadedFile(ctx, localPath, resp.Body, metadata.ContentHash, policy)
 	if err != nil {
-		return DropboxFileMetadata{}, fmt.Errorf("failed to save file to Desktop: %w", err)
+		return FilesDownloadResult{}, fmt.Errorf("failed to save file: %w", err)
 	}
+	result.LocalPath = outcome.path
+	result.BytesWritten = outcome.bytesWritten
+	result.ContentHashVerified = outcome.verified
 
-	ctx.Logger.Info("Successfully downloaded and saved file", "path", args.Path, "size", metadata.Size, "saved_to", "Desktop/wip")
-	return metadata, nil
+	ctx.Logger.Info("Successfully downloaded and saved file", "path", args.Path, "size", metadata.Size, "saved_to", result.LocalPath, "verified", result.ContentHashVerified)
+	return result, nil
 }
 
 // createDownloadRequest creates the HTTP request for downloading a file
-func createDownloadRequest(ctx *server.Context, args FilesDownloadArgs, apiKey string) (*http.Request, error) {
+func (c *Client) createDownloadRequest(ctx *server.Context, args FilesDownloadArgs) (*http.Request, error) {
 	// Validate path
 	if args.Path == "" {
 		return nil, fmt.Errorf("path cannot be empty")
@@ -116,27 +160,11 @@ func createDownloadRequest(ctx *server.Context, args FilesDownloadArgs, apiKey s
 
 	ctx.Logger.Info("downloading file", "path", args.Path)
 
-	// Create the request body (empty for download)
-	req, err := http.NewRequest("POST", "https://content.dropboxapi.com/2/files/download", nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
-	}
-
-	// Set headers
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", "text/plain")
-
-	// Set the API argument in the header
+	// The path goes in the Dropbox-API-Arg header, the request body is empty
 	apiArg := map[string]string{
 		"path": args.Path,
 	}
-	apiArgJSON, err := json.Marshal(apiArg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal API argument: %w", err)
-	}
-	req.Header.Set("Dropbox-API-Arg", string(apiArgJSON))
-
-	return req, nil
+	return c.newContentRequest("download", apiArg, nil)
 }
 
 // parseMetadataFromResponse extracts file metadata from the Dropbox-API-Result header
@@ -157,33 +185,3 @@ func parseMetadataFromResponse(resp *http.Response) (DropboxFileMetadata, error)
 
 	return metadata, nil
 }
-
-// saveFileToDesktop saves the file content to the Desktop/wip folder
-func saveFileToDesktop(ctx *server.Context, filename string, content []byte) error {
-	// Get user's home directory
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("failed to get user home directory: %w", err)
-	}
-
-	// Create Desktop/wip path
-	wipDir := filepath.Join(homeDir, "Desktop", "wip")
-
-	// Create the wip directory if it doesn't exist
-	err = os.MkdirAll(wipDir, 0755)
-	if err != nil {
-		return fmt.Errorf("failed to create wip directory: %w", err)
-	}
-
-	// Create the full file path
-	filePath := filepath.Join(wipDir, filename)
-
-	// Write the file
-	err = os.WriteFile(filePath, content, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
-	}
-
-	ctx.Logger.Info("File saved successfully", "file_path", filePath)
-	return nil
-}
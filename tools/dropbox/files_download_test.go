@@ -0,0 +1,219 @@
+package dropbox
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandleFilesDownload_MissingAPIKey(t *testing.T) {
+	_, err := NewClient("").HandleFilesDownload(mockContext(), FilesDownloadArgs{Path: "/file.txt"})
+	if err == nil {
+		t.Fatal("Expected error when API key is missing")
+	}
+
+	expectedError := "$DROPBOX_API_KEY not set, unable to download file"
+	if !strings.Contains(err.Error(), expectedError) {
+		t.Fatalf("Expected error to contain '%s', got: %s", expectedError, err.Error())
+	}
+}
+
+func TestHandleFilesDownload_SuccessfulResponse(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2/files/download" {
+			t.Errorf("Expected path /2/files/download, got %s", r.URL.Path)
+		}
+
+		if r.Header.Get("Authorization") != "Bearer test_api_key_123" {
+			t.Errorf("Expected Authorization header 'Bearer test_api_key_123', got %s", r.Header.Get("Authorization"))
+		}
+
+		if r.Header.Get("Dropbox-API-Arg") != `{"path":"/docs/notes.txt"}` {
+			t.Errorf("Unexpected Dropbox-API-Arg header: %s", r.Header.Get("Dropbox-API-Arg"))
+		}
+
+		w.Header().Set("Dropbox-API-Result", `{"name": "notes.txt", "path_display": "/docs/notes.txt", "size": 11}`)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("hello world"))
+	}))
+	defer server.Close()
+
+	client := NewClient("test_api_key_123", WithBaseURLs(server.URL, server.URL), WithHTTPClient(server.Client()))
+
+	metadata, err := client.HandleFilesDownload(mockContext(), FilesDownloadArgs{Path: "/docs/notes.txt"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if metadata.Name != "notes.txt" || metadata.Size != 11 {
+		t.Errorf("Unexpected metadata: %+v", metadata)
+	}
+
+	content, err := os.ReadFile(filepath.Join(homeDir, "Desktop", "wip", "notes.txt"))
+	if err != nil {
+		t.Fatalf("Expected downloaded file to exist: %v", err)
+	}
+
+	if string(content) != "hello world" {
+		t.Errorf("Expected file content 'hello world', got %q", content)
+	}
+}
+
+func TestHandleFilesDownload_MissingMetadataHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("hello world"))
+	}))
+	defer server.Close()
+
+	client := NewClient("test_api_key_123", WithBaseURLs(server.URL, server.URL), WithHTTPClient(server.Client()))
+
+	_, err := client.HandleFilesDownload(mockContext(), FilesDownloadArgs{Path: "/docs/notes.txt"})
+	if err == nil || !strings.Contains(err.Error(), "missing Dropbox-API-Result header") {
+		t.Fatalf("Expected missing header error, got: %v", err)
+	}
+}
+
+// downloadServer serves a fixed file for /2/files/download
+func downloadServer(t *testing.T, content string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Dropbox-API-Result", `{"name": "report.pdf", "path_display": "/docs/report.pdf", "size": 7}`)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(content))
+	}))
+}
+
+func TestHandleFilesDownload_Destination(t *testing.T) {
+	server := downloadServer(t, "new pdf")
+	defer server.Close()
+	client := NewClient("test_api_key_123", WithBaseURLs(server.URL, server.URL), WithHTTPClient(server.Client()))
+
+	dir := t.TempDir()
+	tests := []struct {
+		name        string
+		destination string
+		expected    string
+	}{
+		{name: "Existing directory", destination: dir, expected: filepath.Join(dir, "report.pdf")},
+		{name: "New directory with trailing slash", destination: filepath.Join(dir, "new") + "/", expected: filepath.Join(dir, "new", "report.pdf")},
+		{name: "Explicit file name", destination: filepath.Join(dir, "renamed.pdf"), expected: filepath.Join(dir, "renamed.pdf")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := client.HandleFilesDownload(mockContext(), FilesDownloadArgs{Path: "/docs/report.pdf", Destination: tt.destination})
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if result.LocalPath != tt.expected {
+				t.Errorf("Expected local path %s, got %s", tt.expected, result.LocalPath)
+			}
+			if content, err := os.ReadFile(tt.expected); err != nil || string(content) != "new pdf" {
+				t.Errorf("Expected file content 'new pdf', got %q (err %v)", content, err)
+			}
+		})
+	}
+}
+
+func TestHandleFilesDownload_ConflictPolicies(t *testing.T) {
+	server := downloadServer(t, "new pdf")
+	defer server.Close()
+	client := NewClient("test_api_key_123", WithBaseURLs(server.URL, server.URL), WithHTTPClient(server.Client()))
+
+	tests := []struct {
+		policy          string
+		expectedName    string
+		expectedContent string
+		skipped         bool
+		wantErr         bool
+	}{
+		{policy: "overwrite", expectedName: "report.pdf", expectedContent: "new pdf"},
+		{policy: "skip", expectedName: "report.pdf", expectedContent: "old pdf", skipped: true},
+		{policy: "", expectedName: "report (2).pdf", expectedContent: "new pdf"},
+		{policy: "rename", expectedName: "report (2).pdf", expectedContent: "new pdf"},
+		{policy: "fail", wantErr: true},
+		{policy: "merge", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run("policy "+tt.policy, func(t *testing.T) {
+			dir := t.TempDir()
+			// report.pdf and report (1).pdf are already taken
+			os.WriteFile(filepath.Join(dir, "report.pdf"), []byte("old pdf"), 0644)
+			os.WriteFile(filepath.Join(dir, "report (1).pdf"), []byte("older pdf"), 0644)
+
+			result, err := client.HandleFilesDownload(mockContext(), FilesDownloadArgs{Path: "/docs/report.pdf", Destination: dir, OnConflict: tt.policy})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			expectedPath := filepath.Join(dir, tt.expectedName)
+			if result.LocalPath != expectedPath || result.Skipped != tt.skipped {
+				t.Errorf("Expected %s (skipped=%v), got %s (skipped=%v)", expectedPath, tt.skipped, result.LocalPath, result.Skipped)
+			}
+			if content, _ := os.ReadFile(expectedPath); string(content) != tt.expectedContent {
+				t.Errorf("Expected content %q, got %q", tt.expectedContent, content)
+			}
+		})
+	}
+}
+
+func TestHandleFilesDownload_ContentHash(t *testing.T) {
+	content := "hashed content"
+	tests := []struct {
+		name        string
+		contentHash string
+		verified    bool
+		wantErr     bool
+	}{
+		{name: "Matching hash", contentHash: referenceContentHash([]byte(content)), verified: true},
+		{name: "No hash", contentHash: "", verified: false},
+		{name: "Mismatched hash", contentHash: referenceContentHash([]byte("something else")), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Dropbox-API-Result", `{"name": "data.bin", "size": 14, "content_hash": "`+tt.contentHash+`"}`)
+				w.Write([]byte(content))
+			}))
+			defer server.Close()
+			client := NewClient("test_api_key_123", WithBaseURLs(server.URL, server.URL), WithHTTPClient(server.Client()))
+
+			dir := t.TempDir()
+			result, err := client.HandleFilesDownload(mockContext(), FilesDownloadArgs{Path: "/data.bin", Destination: dir})
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "content hash mismatch") {
+					t.Fatalf("Expected content hash mismatch, got: %v", err)
+				}
+				// Neither the file nor the temp file may be left behind
+				if leftovers, _ := os.ReadDir(dir); len(leftovers) != 0 {
+					t.Errorf("Expected empty destination, found %d entries", len(leftovers))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			if result.ContentHashVerified != tt.verified || result.BytesWritten != int64(len(content)) {
+				t.Errorf("Expected verified=%v bytes=%d, got verified=%v bytes=%d", tt.verified, len(content), result.ContentHashVerified, result.BytesWritten)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+				t.Errorf("Expected only the downloaded file in destination, found %d entries", len(entries))
+			}
+		})
+	}
+}
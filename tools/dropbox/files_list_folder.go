@@ -1,76 +1,254 @@
 package dropbox
 
 import (
-	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"slices"
 
 	"github.com/localrivet/gomcp/server"
 )
 
+// maxListFolderPageSize is the largest page size list_folder accepts
+const maxListFolderPageSize = 2000
+
 type ListDropboxFoldersArgs struct {
-	Path string `json:"path"`
+	Path       string `json:"path"`
+	MaxEntries int    `json:"max_entries,omitempty" description:"Stop fetching further pages once this many entries have been collected. 0 fetches the whole folder."`
+	Cursor     string `json:"cursor,omitempty" description:"Cursor returned by a previous incomplete listing, to continue where it stopped. Pass the same path and recursion options alongside it."`
+	Recursive  bool   `json:"recursive,omitempty" description:"List the contents of all subfolders as well."`
+	MaxDepth   int    `json:"max_depth,omitempty" description:"With recursive, only return entries at most this many levels below path (1 = direct children). 0 means unlimited."`
+	Tree       bool   `json:"tree,omitempty" description:"Also return the entries as a tree with nested children per folder."`
+
+	Tags          []string `json:"tags,omitempty" description:"Only return entries with these tags: file, folder, deleted. Defaults to file and folder."`
+	Extensions    []string `json:"extensions,omitempty" description:"Only return files with these extensions, e.g. [\"go\", \".md\"]. Folders are not affected."`
+	ModifiedSince string   `json:"modified_since,omitempty" description:"Only return files whose server_modified is at or after this RFC 3339 time. Folders are not affected."`
+}
+
+// ListDropboxFolderResult is the result of the list_dropbox_folder tool.
+// When Complete is false, pass Cursor back to continue the listing.
+type ListDropboxFolderResult struct {
+	Entries  DropboxEntries     `json:"entries"`
+	Tree     []*DropboxTreeNode `json:"tree,omitempty"`
+	Complete bool               `json:"complete"`
+	Cursor   string             `json:"cursor,omitempty"`
+	Pages    int                `json:"pages"`
 }
 
-type DropboxFolders []DropboxFolder
-type DropboxFolder struct {
-	ID             string `json:"id"`
-	Name           string `json:"name"`
-	PathDisplay    string `json:"path_display"`
-	Tag            string `json:".tag"`
-	SharedFolderID string `json:"shared_folder_id"`
+// listFolderPage is a single page of a list_folder or list_folder/continue response
+type listFolderPage struct {
+	Entries DropboxEntries `json:"entries"`
+	Cursor  string         `json:"cursor"`
+	HasMore bool           `json:"has_more"`
 }
 
 // HandleListDropBoxFolders implements the logic the list_dropbox_folders tool
-// This handler provides a listing of all folders and their metadata at
-// the provided path (ListDropboxFoldersArgs.Path).
-func HandleListDropboxFolder(ctx *server.Context, args ListDropboxFoldersArgs) (DropboxFolders, error) {
+// This handler provides a listing of all files and folders and their metadata at
+// the provided path (ListDropboxFoldersArgs.Path), following list_folder/continue
+// until the listing is exhausted or ListDropboxFoldersArgs.MaxEntries is reached.
+// With MaxDepth, subfolders are listed one level at a time down to that depth.
+func (c *Client) HandleListDropboxFolder(ctx *server.Context, args ListDropboxFoldersArgs) (*ListDropboxFolderResult, error) {
 	ctx.Logger.Info("Handling ListDropboxFolders tool call")
 
-	// Get API key and print first two letters
-	apiKey := os.Getenv("DROPBOX_API_KEY")
-	if apiKey == "" {
-		ctx.Logger.Info("$DROPBOX_API_KEY not set")
-		return nil, fmt.Errorf("$DROPBOX_API_KEY not set, unable to retrieve dropbox folders")
+	if err := c.requireAPIKey(ctx, "retrieve dropbox folders"); err != nil {
+		return nil, err
+	}
+	if args.MaxEntries < 0 {
+		return nil, fmt.Errorf("max_entries cannot be negative: %d", args.MaxEntries)
+	}
+	if args.MaxDepth < 0 {
+		return nil, fmt.Errorf("max_depth cannot be negative: %d", args.MaxDepth)
+	}
+	if args.MaxDepth > 0 && !args.Recursive {
+		return nil, fmt.Errorf("max_depth requires recursive")
+	}
+	filter, err := newEntryFilter(args.Tags, args.Extensions, args.ModifiedSince)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *ListDropboxFolderResult
+	if args.MaxDepth > 0 {
+		result, err = c.listToDepth(ctx, args, filter)
+	} else {
+		result, err = c.listPages(ctx, args, filter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if args.Tree {
+		result.Tree = buildFolderTree(result.Entries)
+	}
+
+	ctx.Logger.Info("Successfully retrieved dropbox folders", "count", len(result.Entries), "pages", result.Pages, "complete", result.Complete)
+	return result, nil
+}
+
+// listPages lists the folder, recursively if asked, following list_folder/continue
+// until the listing is exhausted or args.MaxEntries is reached
+func (c *Client) listPages(ctx *server.Context, args ListDropboxFoldersArgs, filter *entryFilter) (*ListDropboxFolderResult, error) {
+	result := &ListDropboxFolderResult{Entries: DropboxEntries{}}
+	cursor := args.Cursor
+	for {
+		var req *http.Request
+		var err error
+		if cursor == "" {
+			req, err = c.craftHttpReq(ctx, &args)
+		} else {
+			req, err = c.craftContinueReq(cursor)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		page, err := c.fetchListFolderPage(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		result.Pages++
+		if args.Recursive {
+			page.Entries = filterByDepth(page.Entries, args.Path, 0)
+		}
+		result.Entries = append(result.Entries, filter.apply(page.Entries)...)
+
+		if !page.HasMore {
+			result.Complete = true
+			return result, nil
+		}
+		cursor = page.Cursor
+		if args.MaxEntries > 0 && len(result.Entries) >= args.MaxEntries {
+			result.Cursor = cursor
+			return result, nil
+		}
 	}
-	if len(apiKey) >= 2 {
-		ctx.Logger.Info("First two letters of API key: " + apiKey[:2])
+}
+
+// depthWalk is the state of a breadth-first listing down to max_depth. An
+// incomplete listing returns it, encoded, as its cursor.
+type depthWalk struct {
+	// Folder is the folder being listed, Cursor its list_folder/continue cursor
+	Folder folderAtDepth `json:"folder"`
+	Cursor string        `json:"cursor,omitempty"`
+	// Pending are the folders still to be listed, in breadth-first order
+	Pending []folderAtDepth `json:"pending,omitempty"`
+}
+
+// folderAtDepth is a folder and how many levels it is below the listed path
+type folderAtDepth struct {
+	Path  string `json:"path"`
+	Depth int    `json:"depth"`
+}
+
+// listToDepth lists args.Path breadth-first with non-recursive list_folder
+// calls, descending into subfolders only while they are above args.MaxDepth,
+// so nothing deeper is fetched. It stops early once args.MaxEntries is reached.
+func (c *Client) listToDepth(ctx *server.Context, args ListDropboxFoldersArgs, filter *entryFilter) (*ListDropboxFolderResult, error) {
+	walk := depthWalk{Pending: []folderAtDepth{{Path: args.Path}}}
+	if args.Cursor != "" {
+		var err error
+		if walk, err = decodeDepthWalk(args.Cursor); err != nil {
+			return nil, err
+		}
 	}
 
-	req, err := craftHttpReq(ctx, &args, apiKey)
+	result := &ListDropboxFolderResult{Entries: DropboxEntries{}}
+	for {
+		var req *http.Request
+		var err error
+		if walk.Cursor == "" {
+			walk.Folder, walk.Pending = walk.Pending[0], walk.Pending[1:]
+			folderArgs := args
+			folderArgs.Path = walk.Folder.Path
+			folderArgs.Recursive = false
+			req, err = c.craftHttpReq(ctx, &folderArgs)
+		} else {
+			req, err = c.craftContinueReq(walk.Cursor)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		page, err := c.fetchListFolderPage(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		result.Pages++
+		if depth := walk.Folder.Depth + 1; depth < args.MaxDepth {
+			for _, entry := range page.Entries {
+				if entry.Tag == TagFolder {
+					walk.Pending = append(walk.Pending, folderAtDepth{Path: entry.PathDisplay(), Depth: depth})
+				}
+			}
+		}
+		result.Entries = append(result.Entries, filter.apply(page.Entries)...)
+
+		walk.Cursor = ""
+		if page.HasMore {
+			walk.Cursor = page.Cursor
+		} else if len(walk.Pending) == 0 {
+			result.Complete = true
+			return result, nil
+		}
+		if args.MaxEntries > 0 && len(result.Entries) >= args.MaxEntries {
+			if result.Cursor, err = encodeDepthWalk(walk); err != nil {
+				return nil, err
+			}
+			return result, nil
+		}
+	}
+}
+
+// encodeDepthWalk encodes the state of a listing as an opaque cursor
+func encodeDepthWalk(walk depthWalk) (string, error) {
+	data, err := json.Marshal(walk)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeDepthWalk decodes a cursor returned by an incomplete max_depth listing
+func decodeDepthWalk(cursor string) (depthWalk, error) {
+	var walk depthWalk
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		err = json.Unmarshal(data, &walk)
+	}
+	if err != nil || (walk.Cursor == "" && len(walk.Pending) == 0) {
+		return depthWalk{}, fmt.Errorf("invalid cursor: not from a listing with max_depth")
 	}
+	return walk, nil
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// fetchListFolderPage executes a list_folder or list_folder/continue request
+func (c *Client) fetchListFolderPage(ctx *server.Context, req *http.Request) (listFolderPage, error) {
+	resp, err := c.do(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("list folders http request failed: %w", err)
+		return listFolderPage{}, fmt.Errorf("list folders http request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		err := handleFailedHttpReq(resp)
-		return nil, err
+		return listFolderPage{}, err
 	}
 
 	// Read the response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return listFolderPage{}, fmt.Errorf("failed to read response body: %w", err)
 	}
-	folders, err := unmarshalFolders(&body)
+	page, err := unmarshalFolders(&body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal folders: %w", err)
+		return listFolderPage{}, fmt.Errorf("failed to unmarshal folders: %w", err)
 	}
-	ctx.Logger.Info("Successfully retrieved dropbox folders", "count", len(folders))
-	return folders, nil
+	return page, nil
 }
 
-func craftHttpReq(ctx *server.Context, args *ListDropboxFoldersArgs, apiKey string) (*http.Request, error) {
+func (c *Client) craftHttpReq(ctx *server.Context, args *ListDropboxFoldersArgs) (*http.Request, error) {
 	if args.Path == "" || args.Path == "/" || args.Path == "." {
 		ctx.Logger.Info(`provided path does not exist. To reach root directory, use empty string, ""`)
 		ctx.Logger.Info("assuming root path for listing")
@@ -80,40 +258,37 @@ func craftHttpReq(ctx *server.Context, args *ListDropboxFoldersArgs, apiKey stri
 	ctx.Logger.Info("request path", "path", args.Path)
 	// Make HTTP request to Dropbox API
 	requestBody := map[string]any{
-		"include_deleted":                     false,
+		"include_deleted":                     slices.Contains(args.Tags, TagDeleted),
 		"include_has_explicit_shared_members": false,
 		"include_media_info":                  true,
 		"include_mounted_folders":             true,
 		"include_non_downloadable_files":      true,
 		"path":                                args.Path,
-		"recursive":                           false,
-	}
-
-	jsonBody, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+		"recursive":                           args.Recursive,
 	}
-	req, err := http.NewRequest("POST", fmt.Sprintf("%v/list_folder", DROPBOX_FILES_API_URL), bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create new HTTP request: %w", err)
+	if args.MaxEntries > 0 {
+		requestBody["limit"] = min(args.MaxEntries, maxListFolderPageSize)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", "application/json")
-	return req, nil
+	return c.newRPCRequest("list_folder", requestBody)
 }
 
-func unmarshalFolders(body *[]byte) (DropboxFolders, error) {
-	// Dropbox API returns folders in an "entries" field
-	type DropboxAPIResponse struct {
-		Entries []DropboxFolder `json:"entries"`
-	}
+// craftContinueReq creates a list_folder/continue request for the given cursor
+func (c *Client) craftContinueReq(cursor string) (*http.Request, error) {
+	return c.newRPCRequest("list_folder/continue", map[string]any{
+		"cursor": cursor,
+	})
+}
 
-	var apiResponse DropboxAPIResponse
-	if err := json.Unmarshal(*body, &apiResponse); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON response: %w", err)
+func unmarshalFolders(body *[]byte) (listFolderPage, error) {
+	// Dropbox API returns folders in an "entries" field, along with the paging cursor
+	var page listFolderPage
+	if err := json.Unmarshal(*body, &page); err != nil {
+		return listFolderPage{}, fmt.Errorf("failed to unmarshal JSON response: %w", err)
+	}
+	if page.Entries == nil {
+		page.Entries = DropboxEntries{}
 	}
 
-	// Convert to DropboxFolders type
-	return DropboxFolders(apiResponse.Entries), nil
+	return page, nil
 }
@@ -0,0 +1,504 @@
+package dropbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/localrivet/gomcp/server"
+)
+
+// maxBatchEntries is the largest number of entries a Dropbox batch call accepts
+const maxBatchEntries = 1000
+
+// batchPollInterval and batchPollTimeout control how async batch jobs are polled
+var (
+	batchPollInterval = time.Second
+	batchPollTimeout  = 2 * time.Minute
+)
+
+// Actions reported in FileOperation.Action
+const (
+	actionCreateFolder = "create_folder"
+	actionMove         = "move"
+	actionCopy         = "copy"
+	actionDelete       = "delete"
+)
+
+type CreateFolderArgs struct {
+	Path       string `json:"path" description:"The Dropbox path of the folder to create, e.g. /projects/new." required:"true"`
+	Autorename bool   `json:"autorename,omitempty" description:"Rename the folder instead of failing when the path already exists."`
+	DryRun     bool   `json:"dry_run,omitempty" description:"Check the paths with get_metadata and report what would change, without changing anything."`
+}
+
+type RelocationArgs struct {
+	FromPath               string `json:"from_path" description:"The Dropbox path of the file or folder to move or copy." required:"true"`
+	ToPath                 string `json:"to_path" description:"The Dropbox destination path." required:"true"`
+	Autorename             bool   `json:"autorename,omitempty" description:"Rename the result instead of failing when the destination already exists."`
+	AllowOwnershipTransfer bool   `json:"allow_ownership_transfer,omitempty" description:"Allow moves that transfer ownership of the content. Ignored for copies."`
+	DryRun                 bool   `json:"dry_run,omitempty" description:"Check the paths with get_metadata and report what would change, without changing anything."`
+}
+
+type DeleteArgs struct {
+	Path   string `json:"path" description:"The Dropbox path of the file or folder to delete. Folders are deleted with all their contents." required:"true"`
+	DryRun bool   `json:"dry_run,omitempty" description:"Check the paths with get_metadata and report what would change, without changing anything."`
+}
+
+type CreateFolderBatchArgs struct {
+	Paths      []string `json:"paths" description:"The Dropbox paths of the folders to create." required:"true"`
+	Autorename bool     `json:"autorename,omitempty" description:"Rename folders instead of failing when a path already exists."`
+	DryRun     bool     `json:"dry_run,omitempty" description:"Check the paths with get_metadata and report what would change, without changing anything."`
+}
+
+// RelocationPath is one source and destination pair of a move or copy batch
+type RelocationPath struct {
+	FromPath string `json:"from_path"`
+	ToPath   string `json:"to_path"`
+}
+
+type RelocationBatchArgs struct {
+	Entries                []RelocationPath `json:"entries" description:"The from_path and to_path pairs to move or copy." required:"true"`
+	Autorename             bool             `json:"autorename,omitempty" description:"Rename results instead of failing when a destination already exists."`
+	AllowOwnershipTransfer bool             `json:"allow_ownership_transfer,omitempty" description:"Allow moves that transfer ownership of the content. Ignored for copies."`
+	DryRun                 bool             `json:"dry_run,omitempty" description:"Check the paths with get_metadata and report what would change, without changing anything."`
+}
+
+type DeleteBatchArgs struct {
+	Paths  []string `json:"paths" description:"The Dropbox paths of the files or folders to delete." required:"true"`
+	DryRun bool     `json:"dry_run,omitempty" description:"Check the paths with get_metadata and report what would change, without changing anything."`
+}
+
+// FileOperation is a single create folder, move, copy or delete operation and its outcome.
+// Metadata is the resulting entry; for batches, Error is set instead when that entry failed.
+// Dry runs set Existing to the entry at Path or FromPath, Conflict to the entry
+// already at the destination, Outcome to what would happen and Error when it would fail.
+type FileOperation struct {
+	Action   string        `json:"action"`
+	Path     string        `json:"path,omitempty"`
+	FromPath string        `json:"from_path,omitempty"`
+	ToPath   string        `json:"to_path,omitempty"`
+	Metadata *DropboxEntry `json:"metadata,omitempty"`
+	Existing *DropboxEntry `json:"existing,omitempty"`
+	Conflict *DropboxEntry `json:"conflict,omitempty"`
+	Outcome  string        `json:"outcome,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// FileOperationResult is the result of the file management tools.
+// With DryRun, Operations lists what would change, checked against Dropbox with
+// get_metadata, and nothing has been changed.
+type FileOperationResult struct {
+	DryRun     bool            `json:"dry_run"`
+	Operations []FileOperation `json:"operations"`
+	AsyncJobID string          `json:"async_job_id,omitempty"`
+}
+
+// operationMetadataResponse is the response of create_folder_v2, move_v2, copy_v2 and delete_v2
+type operationMetadataResponse struct {
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+// batchJobStatus is the response of a batch launch or check call: either an
+// async job ID to poll, a job still in progress, or the per-entry results
+type batchJobStatus struct {
+	Tag        string             `json:".tag"`
+	AsyncJobID string             `json:"async_job_id"`
+	Entries    []batchEntryResult `json:"entries"`
+	Failed     json.RawMessage    `json:"failed"`
+}
+
+// batchEntryResult is the outcome of one batch entry. Move and copy batches
+// put the metadata in Success, delete and create folder batches in Metadata.
+type batchEntryResult struct {
+	Tag      string          `json:".tag"`
+	Success  json.RawMessage `json:"success"`
+	Metadata json.RawMessage `json:"metadata"`
+	Failure  json.RawMessage `json:"failure"`
+}
+
+// HandleCreateFolder implements the logic of the create_folder tool
+func (c *Client) HandleCreateFolder(ctx *server.Context, args CreateFolderArgs) (FileOperationResult, error) {
+	ctx.Logger.Info("Handling CreateFolder tool call")
+
+	if args.Path == "" {
+		return FileOperationResult{}, fmt.Errorf("path cannot be empty")
+	}
+	op := FileOperation{Action: actionCreateFolder, Path: args.Path}
+	if err := c.requireAPIKey(ctx, "create folder"); err != nil {
+		return FileOperationResult{}, err
+	}
+	if args.DryRun {
+		return c.dryRun(ctx, args.Autorename, op)
+	}
+
+	return c.runOperation(ctx, "create_folder_v2", map[string]any{
+		"path":       args.Path,
+		"autorename": args.Autorename,
+	}, op)
+}
+
+// HandleMove implements the logic of the move tool
+func (c *Client) HandleMove(ctx *server.Context, args RelocationArgs) (FileOperationResult, error) {
+	ctx.Logger.Info("Handling Move tool call")
+	return c.relocate(ctx, actionMove, args)
+}
+
+// HandleCopy implements the logic of the copy tool
+func (c *Client) HandleCopy(ctx *server.Context, args RelocationArgs) (FileOperationResult, error) {
+	ctx.Logger.Info("Handling Copy tool call")
+	return c.relocate(ctx, actionCopy, args)
+}
+
+// relocate moves or copies a single entry via move_v2 or copy_v2
+func (c *Client) relocate(ctx *server.Context, action string, args RelocationArgs) (FileOperationResult, error) {
+	if err := validateRelocation(RelocationPath{FromPath: args.FromPath, ToPath: args.ToPath}); err != nil {
+		return FileOperationResult{}, err
+	}
+	op := FileOperation{Action: action, FromPath: args.FromPath, ToPath: args.ToPath}
+	if err := c.requireAPIKey(ctx, action+" file"); err != nil {
+		return FileOperationResult{}, err
+	}
+	if args.DryRun {
+		return c.dryRun(ctx, args.Autorename, op)
+	}
+
+	body := map[string]any{
+		"from_path":  args.FromPath,
+		"to_path":    args.ToPath,
+		"autorename": args.Autorename,
+	}
+	if action == actionMove {
+		body["allow_ownership_transfer"] = args.AllowOwnershipTransfer
+	}
+	return c.runOperation(ctx, action+"_v2", body, op)
+}
+
+// HandleDelete implements the logic of the delete tool
+func (c *Client) HandleDelete(ctx *server.Context, args DeleteArgs) (FileOperationResult, error) {
+	ctx.Logger.Info("Handling Delete tool call")
+
+	if args.Path == "" {
+		return FileOperationResult{}, fmt.Errorf("path cannot be empty")
+	}
+	op := FileOperation{Action: actionDelete, Path: args.Path}
+	if err := c.requireAPIKey(ctx, "delete file"); err != nil {
+		return FileOperationResult{}, err
+	}
+	if args.DryRun {
+		return c.dryRun(ctx, false, op)
+	}
+
+	return c.runOperation(ctx, "delete_v2", map[string]any{"path": args.Path}, op)
+}
+
+// HandleCreateFolderBatch implements the logic of the create_folder_batch tool
+func (c *Client) HandleCreateFolderBatch(ctx *server.Context, args CreateFolderBatchArgs) (FileOperationResult, error) {
+	ctx.Logger.Info("Handling CreateFolderBatch tool call")
+
+	ops, err := pathOperations(actionCreateFolder, args.Paths)
+	if err != nil {
+		return FileOperationResult{}, err
+	}
+	if err := c.requireAPIKey(ctx, "create folders"); err != nil {
+		return FileOperationResult{}, err
+	}
+	if args.DryRun {
+		return c.dryRun(ctx, args.Autorename, ops...)
+	}
+
+	return c.runBatch(ctx, "create_folder_batch", "create_folder_batch/check", map[string]any{
+		"paths":       args.Paths,
+		"autorename":  args.Autorename,
+		"force_async": false,
+	}, ops)
+}
+
+// HandleMoveBatch implements the logic of the move_batch tool
+func (c *Client) HandleMoveBatch(ctx *server.Context, args RelocationBatchArgs) (FileOperationResult, error) {
+	ctx.Logger.Info("Handling MoveBatch tool call")
+	return c.relocateBatch(ctx, actionMove, args)
+}
+
+// HandleCopyBatch implements the logic of the copy_batch tool
+func (c *Client) HandleCopyBatch(ctx *server.Context, args RelocationBatchArgs) (FileOperationResult, error) {
+	ctx.Logger.Info("Handling CopyBatch tool call")
+	return c.relocateBatch(ctx, actionCopy, args)
+}
+
+// relocateBatch moves or copies entries via move_batch_v2 or copy_batch_v2
+func (c *Client) relocateBatch(ctx *server.Context, action string, args RelocationBatchArgs) (FileOperationResult, error) {
+	if err := validateBatchSize(len(args.Entries)); err != nil {
+		return FileOperationResult{}, err
+	}
+	ops := make([]FileOperation, len(args.Entries))
+	for i, entry := range args.Entries {
+		if err := validateRelocation(entry); err != nil {
+			return FileOperationResult{}, fmt.Errorf("entry %d: %w", i, err)
+		}
+		ops[i] = FileOperation{Action: action, FromPath: entry.FromPath, ToPath: entry.ToPath}
+	}
+	if err := c.requireAPIKey(ctx, action+" files"); err != nil {
+		return FileOperationResult{}, err
+	}
+	if args.DryRun {
+		return c.dryRun(ctx, args.Autorename, ops...)
+	}
+
+	body := map[string]any{
+		"entries":    args.Entries,
+		"autorename": args.Autorename,
+	}
+	if action == actionMove {
+		body["allow_ownership_transfer"] = args.AllowOwnershipTransfer
+	}
+	return c.runBatch(ctx, action+"_batch_v2", action+"_batch/check_v2", body, ops)
+}
+
+// HandleDeleteBatch implements the logic of the delete_batch tool
+func (c *Client) HandleDeleteBatch(ctx *server.Context, args DeleteBatchArgs) (FileOperationResult, error) {
+	ctx.Logger.Info("Handling DeleteBatch tool call")
+
+	ops, err := pathOperations(actionDelete, args.Paths)
+	if err != nil {
+		return FileOperationResult{}, err
+	}
+	if err := c.requireAPIKey(ctx, "delete files"); err != nil {
+		return FileOperationResult{}, err
+	}
+	if args.DryRun {
+		return c.dryRun(ctx, false, ops...)
+	}
+
+	entries := make([]map[string]string, len(args.Paths))
+	for i, path := range args.Paths {
+		entries[i] = map[string]string{"path": path}
+	}
+	return c.runBatch(ctx, "delete_batch", "delete_batch/check", map[string]any{"entries": entries}, ops)
+}
+
+// dryRun looks up the paths of each operation with get_metadata and reports
+// what it would do, without changing anything in Dropbox
+func (c *Client) dryRun(ctx *server.Context, autorename bool, ops ...FileOperation) (FileOperationResult, error) {
+	for i := range ops {
+		if err := c.previewOperation(ctx, &ops[i], autorename); err != nil {
+			return FileOperationResult{}, err
+		}
+		op := ops[i]
+		ctx.Logger.Info("dry run", "action", op.Action, "path", op.Path, "from_path", op.FromPath, "to_path", op.ToPath, "outcome", op.Outcome, "error", op.Error)
+	}
+	return FileOperationResult{DryRun: true, Operations: ops}, nil
+}
+
+// previewOperation fills in what op would do given the current entries at its paths
+func (c *Client) previewOperation(ctx *server.Context, op *FileOperation, autorename bool) error {
+	var err error
+	switch op.Action {
+	case actionCreateFolder:
+		if op.Conflict, err = c.lookupMetadata(ctx, op.Path); err != nil {
+			return err
+		}
+		op.Outcome = "create folder " + op.Path
+		if op.Conflict != nil {
+			op.Outcome, op.Error = conflictOutcome(op.Conflict, op.Path, autorename, "create a renamed folder")
+		}
+
+	case actionMove, actionCopy:
+		if op.Existing, err = c.lookupMetadata(ctx, op.FromPath); err != nil {
+			return err
+		}
+		if op.Existing == nil {
+			op.Error = "from_path does not exist: " + op.FromPath
+			return nil
+		}
+		if op.Conflict, err = c.lookupMetadata(ctx, op.ToPath); err != nil {
+			return err
+		}
+		op.Outcome = fmt.Sprintf("%s %s %s to %s", op.Action, op.Existing.Tag, op.FromPath, op.ToPath)
+		if op.Conflict != nil {
+			op.Outcome, op.Error = conflictOutcome(op.Conflict, op.ToPath, autorename, fmt.Sprintf("%s %s %s to a renamed path", op.Action, op.Existing.Tag, op.FromPath))
+		}
+
+	case actionDelete:
+		if op.Existing, err = c.lookupMetadata(ctx, op.Path); err != nil {
+			return err
+		}
+		switch {
+		case op.Existing == nil:
+			op.Error = "path does not exist: " + op.Path
+		case op.Existing.Tag == TagFolder:
+			op.Outcome = "delete folder " + op.Path + " and all its contents"
+		default:
+			op.Outcome = "delete " + op.Existing.Tag + " " + op.Path
+		}
+	}
+	return nil
+}
+
+// conflictOutcome describes an operation whose destination is taken by existing:
+// with autorename it goes ahead as renamed, otherwise it fails
+func conflictOutcome(existing *DropboxEntry, path string, autorename bool, renamed string) (outcome, failure string) {
+	if autorename {
+		return renamed, ""
+	}
+	return "", fmt.Sprintf("a %s already exists at %s", existing.Tag, path)
+}
+
+// lookupMetadata returns the entry at path, or nil when there is none
+func (c *Client) lookupMetadata(ctx *server.Context, path string) (*DropboxEntry, error) {
+	var entry DropboxEntry
+	err := c.doRPCRequest(ctx, "get_metadata", map[string]any{"path": path}, &entry)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get_metadata %s failed: %w", path, err)
+	}
+	return &entry, nil
+}
+
+// validateRelocation checks a move or copy source and destination
+func validateRelocation(entry RelocationPath) error {
+	if entry.FromPath == "" || entry.ToPath == "" {
+		return fmt.Errorf("from_path and to_path cannot be empty")
+	}
+	if entry.FromPath == entry.ToPath {
+		return fmt.Errorf("from_path and to_path are the same: %s", entry.FromPath)
+	}
+	return nil
+}
+
+// validateBatchSize checks a batch has between 1 and maxBatchEntries entries
+func validateBatchSize(n int) error {
+	if n == 0 {
+		return fmt.Errorf("batch cannot be empty")
+	}
+	if n > maxBatchEntries {
+		return fmt.Errorf("batch has %d entries, at most %d are allowed", n, maxBatchEntries)
+	}
+	return nil
+}
+
+// pathOperations builds the operations of a batch that takes a list of paths
+func pathOperations(action string, paths []string) ([]FileOperation, error) {
+	if err := validateBatchSize(len(paths)); err != nil {
+		return nil, err
+	}
+	ops := make([]FileOperation, len(paths))
+	for i, path := range paths {
+		if path == "" {
+			return nil, fmt.Errorf("entry %d: path cannot be empty", i)
+		}
+		ops[i] = FileOperation{Action: action, Path: path}
+	}
+	return ops, nil
+}
+
+// runOperation calls a single-entry endpoint and records the resulting metadata in op
+func (c *Client) runOperation(ctx *server.Context, endpoint string, body any, op FileOperation) (FileOperationResult, error) {
+	var resp operationMetadataResponse
+	if err := c.doRPCRequest(ctx, endpoint, body, &resp); err != nil {
+		return FileOperationResult{}, fmt.Errorf("%s failed: %w", op.Action, err)
+	}
+
+	metadata, err := parseOperationMetadata(resp.Metadata)
+	if err != nil {
+		return FileOperationResult{}, fmt.Errorf("failed to parse %s metadata: %w", endpoint, err)
+	}
+	op.Metadata = metadata
+
+	ctx.Logger.Info("Successfully completed file operation", "action", op.Action, "path", metadata.PathDisplay())
+	return FileOperationResult{Operations: []FileOperation{op}}, nil
+}
+
+// runBatch launches a batch job, polls checkEndpoint until it completes
+// and records the per-entry outcomes in ops, which are in request order
+func (c *Client) runBatch(ctx *server.Context, launchEndpoint, checkEndpoint string, body any, ops []FileOperation) (FileOperationResult, error) {
+	var status batchJobStatus
+	if err := c.doRPCRequest(ctx, launchEndpoint, body, &status); err != nil {
+		return FileOperationResult{}, fmt.Errorf("%s failed: %w", launchEndpoint, err)
+	}
+
+	result := FileOperationResult{Operations: ops, AsyncJobID: status.AsyncJobID}
+	callCtx, stop := requestContext(ctx)
+	defer stop()
+	deadline := time.Now().Add(batchPollTimeout)
+	for status.Tag == "async_job_id" || status.Tag == "in_progress" {
+		if time.Now().After(deadline) {
+			return result, fmt.Errorf("batch job %s still in progress after %s", result.AsyncJobID, batchPollTimeout)
+		}
+		ctx.Logger.Info("waiting for batch job", "endpoint", checkEndpoint, "async_job_id", result.AsyncJobID)
+		timer := time.NewTimer(batchPollInterval)
+		select {
+		case <-timer.C:
+		case <-callCtx.Done():
+			timer.Stop()
+			return result, fmt.Errorf("cancelled while waiting for batch job %s: %w", result.AsyncJobID, callCtx.Err())
+		}
+
+		if err := c.doRPCRequest(ctx, checkEndpoint, map[string]string{"async_job_id": result.AsyncJobID}, &status); err != nil {
+			return result, fmt.Errorf("%s failed: %w", checkEndpoint, err)
+		}
+	}
+
+	switch status.Tag {
+	case "complete":
+	case "failed":
+		return result, &APIError{Endpoint: "files/" + launchEndpoint, Tags: errorTags(status.Failed)}
+	default:
+		return result, fmt.Errorf("unexpected batch job status %q", status.Tag)
+	}
+	if len(status.Entries) != len(ops) {
+		return result, fmt.Errorf("batch job returned %d results for %d entries", len(status.Entries), len(ops))
+	}
+
+	failures := 0
+	for i, entry := range status.Entries {
+		if entry.Tag != "success" {
+			result.Operations[i].Error = describeDropboxError(entry.Failure)
+			failures++
+			continue
+		}
+		raw := entry.Metadata
+		if raw == nil {
+			raw = entry.Success
+		}
+		metadata, err := parseOperationMetadata(raw)
+		if err != nil {
+			return result, fmt.Errorf("failed to parse metadata of entry %d: %w", i, err)
+		}
+		result.Operations[i].Metadata = metadata
+	}
+
+	ctx.Logger.Info("Batch job completed", "endpoint", launchEndpoint, "entries", len(ops), "failures", failures)
+	return result, nil
+}
+
+// parseOperationMetadata decodes the metadata of an operation result. create_folder
+// results are folder metadata without a ".tag", everything else is tagged.
+func parseOperationMetadata(raw json.RawMessage) (*DropboxEntry, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("missing metadata")
+	}
+
+	var tagged struct {
+		Tag string `json:".tag"`
+	}
+	if err := json.Unmarshal(raw, &tagged); err != nil {
+		return nil, err
+	}
+	if tagged.Tag == "" {
+		folder := &DropboxFolder{}
+		if err := json.Unmarshal(raw, folder); err != nil {
+			return nil, err
+		}
+		folder.Tag = TagFolder
+		return &DropboxEntry{Tag: TagFolder, Folder: folder}, nil
+	}
+
+	entry := &DropboxEntry{}
+	if err := entry.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
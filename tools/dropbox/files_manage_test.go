@@ -0,0 +1,364 @@
+package dropbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-mcp-testing/internal/utils"
+
+	"github.com/localrivet/gomcp/server"
+)
+
+// rpcRecorder is a fake API server answering each endpoint with a canned response
+type rpcRecorder struct {
+	responses map[string][]string
+	endpoints []string
+	bodies    []map[string]any
+}
+
+func (r *rpcRecorder) server(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		endpoint := strings.TrimPrefix(req.URL.Path, "/2/files/")
+		r.endpoints = append(r.endpoints, endpoint)
+
+		var body map[string]any
+		data, _ := io.ReadAll(req.Body)
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("Invalid request body for %s: %v", endpoint, err)
+		}
+		r.bodies = append(r.bodies, body)
+
+		queue := r.responses[endpoint]
+		if len(queue) == 0 {
+			t.Errorf("Unexpected call to %s", endpoint)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		r.responses[endpoint] = queue[1:]
+		if strings.HasPrefix(queue[0], `{"error_summary"`) {
+			w.WriteHeader(http.StatusConflict)
+		}
+		w.Write([]byte(queue[0]))
+	}))
+}
+
+func newRecordedClient(t *testing.T, responses map[string][]string) (*Client, *rpcRecorder) {
+	recorder := &rpcRecorder{responses: responses}
+	server := recorder.server(t)
+	t.Cleanup(server.Close)
+	return NewClient("test_api_key_123", WithBaseURLs(server.URL, server.URL), WithHTTPClient(server.Client())), recorder
+}
+
+func fastBatchPolling(t *testing.T) {
+	interval, timeout := batchPollInterval, batchPollTimeout
+	batchPollInterval, batchPollTimeout = time.Millisecond, time.Second
+	t.Cleanup(func() { batchPollInterval, batchPollTimeout = interval, timeout })
+}
+
+func TestHandleCreateFolder(t *testing.T) {
+	client, recorder := newRecordedClient(t, map[string][]string{
+		"create_folder_v2": {`{"metadata": {"id": "id:1", "name": "new", "path_display": "/projects/new"}}`},
+	})
+
+	result, err := client.HandleCreateFolder(mockContext(), CreateFolderArgs{Path: "/projects/new", Autorename: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if recorder.bodies[0]["path"] != "/projects/new" || recorder.bodies[0]["autorename"] != true {
+		t.Errorf("Unexpected request body: %v", recorder.bodies[0])
+	}
+	metadata := result.Operations[0].Metadata
+	if metadata == nil || metadata.Tag != TagFolder || metadata.PathDisplay() != "/projects/new" {
+		t.Errorf("Expected folder metadata, got %+v", metadata)
+	}
+}
+
+func TestHandleMove_RequestAndMetadata(t *testing.T) {
+	client, recorder := newRecordedClient(t, map[string][]string{
+		"move_v2": {`{"metadata": {".tag": "file", "name": "b.txt", "path_display": "/b.txt", "size": 3}}`},
+	})
+
+	result, err := client.HandleMove(mockContext(), RelocationArgs{FromPath: "/a.txt", ToPath: "/b.txt", AllowOwnershipTransfer: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	body := recorder.bodies[0]
+	if body["from_path"] != "/a.txt" || body["to_path"] != "/b.txt" || body["allow_ownership_transfer"] != true {
+		t.Errorf("Unexpected request body: %v", body)
+	}
+	op := result.Operations[0]
+	if op.Action != actionMove || op.Metadata.File == nil || op.Metadata.File.Size != 3 {
+		t.Errorf("Unexpected operation: %+v", op)
+	}
+}
+
+func TestHandleCopy_OmitsOwnershipTransfer(t *testing.T) {
+	client, recorder := newRecordedClient(t, map[string][]string{
+		"copy_v2": {`{"metadata": {".tag": "folder", "name": "b", "path_display": "/b"}}`},
+	})
+
+	if _, err := client.HandleCopy(mockContext(), RelocationArgs{FromPath: "/a", ToPath: "/b", AllowOwnershipTransfer: true}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, ok := recorder.bodies[0]["allow_ownership_transfer"]; ok {
+		t.Errorf("copy_v2 does not accept allow_ownership_transfer: %v", recorder.bodies[0])
+	}
+}
+
+func TestHandleDelete_APIError(t *testing.T) {
+	client, _ := newRecordedClient(t, map[string][]string{
+		"delete_v2": {`{"error_summary": "path_lookup/not_found/..", "error": {".tag": "path_lookup"}}`},
+	})
+
+	_, err := client.HandleDelete(mockContext(), DeleteArgs{Path: "/missing"})
+	if err == nil || !strings.Contains(err.Error(), "409") {
+		t.Fatalf("Expected API error with status 409, got: %v", err)
+	}
+}
+
+func TestFileOperations_DryRunOnlyReads(t *testing.T) {
+	const notFound = `{"error_summary": "path/not_found/..", "error": {".tag": "path", "path": {".tag": "not_found"}}}`
+	client, recorder := newRecordedClient(t, map[string][]string{
+		"get_metadata": {
+			// create_folder /new
+			notFound,
+			// move /a to /b
+			`{".tag": "file", "name": "a", "path_display": "/a"}`, notFound,
+			// delete /old
+			`{".tag": "folder", "name": "old", "path_display": "/old"}`,
+			// copy batch: /c exists at the destination, /d is missing
+			`{".tag": "file", "name": "c", "path_display": "/c"}`, `{".tag": "file", "name": "e", "path_display": "/e"}`,
+			notFound,
+		},
+	})
+	ctx := mockContext()
+
+	tests := []struct {
+		run     func() (FileOperationResult, error)
+		outcome []string
+		errors  []string
+	}{
+		{
+			run: func() (FileOperationResult, error) {
+				return client.HandleCreateFolder(ctx, CreateFolderArgs{Path: "/new", DryRun: true})
+			},
+			outcome: []string{"create folder /new"},
+			errors:  []string{""},
+		},
+		{
+			run: func() (FileOperationResult, error) {
+				return client.HandleMove(ctx, RelocationArgs{FromPath: "/a", ToPath: "/b", DryRun: true})
+			},
+			outcome: []string{"move file /a to /b"},
+			errors:  []string{""},
+		},
+		{
+			run: func() (FileOperationResult, error) {
+				return client.HandleDelete(ctx, DeleteArgs{Path: "/old", DryRun: true})
+			},
+			outcome: []string{"delete folder /old and all its contents"},
+			errors:  []string{""},
+		},
+		{
+			run: func() (FileOperationResult, error) {
+				return client.HandleCopyBatch(ctx, RelocationBatchArgs{Entries: []RelocationPath{{FromPath: "/c", ToPath: "/e"}, {FromPath: "/d", ToPath: "/f"}}, DryRun: true})
+			},
+			outcome: []string{"", ""},
+			errors:  []string{"a file already exists at /e", "from_path does not exist: /d"},
+		},
+	}
+
+	for i, tt := range tests {
+		result, err := tt.run()
+		if err != nil {
+			t.Fatalf("case %d: expected no error, got: %v", i, err)
+		}
+		if !result.DryRun || len(result.Operations) != len(tt.outcome) {
+			t.Fatalf("case %d: expected %d dry run operations, got %+v", i, len(tt.outcome), result)
+		}
+		for j, op := range result.Operations {
+			if op.Metadata != nil || op.Outcome != tt.outcome[j] || op.Error != tt.errors[j] {
+				t.Errorf("case %d: expected outcome %q and error %q, got %+v", i, tt.outcome[j], tt.errors[j], op)
+			}
+		}
+	}
+	for _, endpoint := range recorder.endpoints {
+		if endpoint != "get_metadata" {
+			t.Errorf("Expected dry runs to only call get_metadata, got %v", recorder.endpoints)
+			break
+		}
+	}
+
+	// With autorename the conflict is reported but the operation goes ahead
+	client, _ = newRecordedClient(t, map[string][]string{
+		"get_metadata": {`{".tag": "folder", "name": "new", "path_display": "/new"}`},
+	})
+	result, err := client.HandleCreateFolder(ctx, CreateFolderArgs{Path: "/new", Autorename: true, DryRun: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if op := result.Operations[0]; op.Conflict == nil || op.Error != "" || op.Outcome != "create a renamed folder" {
+		t.Errorf("Expected a renamed folder despite the conflict, got %+v", op)
+	}
+}
+
+func TestFileOperations_InvalidArgs(t *testing.T) {
+	client := NewClient("test_api_key_123")
+	ctx := mockContext()
+
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{"Empty folder path", func() error { _, err := client.HandleCreateFolder(ctx, CreateFolderArgs{}); return err }},
+		{"Missing to_path", func() error { _, err := client.HandleMove(ctx, RelocationArgs{FromPath: "/a"}); return err }},
+		{"Same paths", func() error {
+			_, err := client.HandleCopy(ctx, RelocationArgs{FromPath: "/a", ToPath: "/a"})
+			return err
+		}},
+		{"Empty delete path", func() error { _, err := client.HandleDelete(ctx, DeleteArgs{}); return err }},
+		{"Empty batch", func() error { _, err := client.HandleDeleteBatch(ctx, DeleteBatchArgs{}); return err }},
+		{"Empty batch entry", func() error {
+			_, err := client.HandleCreateFolderBatch(ctx, CreateFolderBatchArgs{Paths: []string{"/a", ""}})
+			return err
+		}},
+		{"Oversized batch", func() error {
+			_, err := client.HandleDeleteBatch(ctx, DeleteBatchArgs{Paths: make([]string, maxBatchEntries+1)})
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); err == nil {
+				t.Fatal("Expected error, got none")
+			}
+		})
+	}
+}
+
+func TestHandleMoveBatch_PollsJob(t *testing.T) {
+	fastBatchPolling(t)
+	client, recorder := newRecordedClient(t, map[string][]string{
+		"move_batch_v2": {`{".tag": "async_job_id", "async_job_id": "job-1"}`},
+		"move_batch/check_v2": {
+			`{".tag": "in_progress"}`,
+			`{".tag": "complete", "entries": [
+				{".tag": "success", "success": {".tag": "file", "name": "b.txt", "path_display": "/b.txt"}},
+				{".tag": "failure", "failure": {".tag": "from_lookup", "from_lookup": {".tag": "not_found"}}}
+			]}`,
+		},
+	})
+
+	result, err := client.HandleMoveBatch(mockContext(), RelocationBatchArgs{Entries: []RelocationPath{
+		{FromPath: "/a.txt", ToPath: "/b.txt"},
+		{FromPath: "/missing", ToPath: "/elsewhere"},
+	}})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := "move_batch_v2,move_batch/check_v2,move_batch/check_v2"
+	if strings.Join(recorder.endpoints, ",") != expected {
+		t.Errorf("Expected calls %s, got %v", expected, recorder.endpoints)
+	}
+	if recorder.bodies[1]["async_job_id"] != "job-1" || result.AsyncJobID != "job-1" {
+		t.Errorf("Expected job-1 to be polled and reported, got %v and %q", recorder.bodies[1], result.AsyncJobID)
+	}
+	if result.Operations[0].Metadata.PathDisplay() != "/b.txt" {
+		t.Errorf("Expected first entry to succeed, got %+v", result.Operations[0])
+	}
+	if result.Operations[1].Error != "from_lookup/not_found" || result.Operations[1].Metadata != nil {
+		t.Errorf("Expected second entry to fail, got %+v", result.Operations[1])
+	}
+}
+
+func TestHandleCreateFolderBatch_CompleteImmediately(t *testing.T) {
+	client, recorder := newRecordedClient(t, map[string][]string{
+		"create_folder_batch": {`{".tag": "complete", "entries": [{".tag": "success", "metadata": {"name": "a", "path_display": "/a"}}]}`},
+	})
+
+	result, err := client.HandleCreateFolderBatch(mockContext(), CreateFolderBatchArgs{Paths: []string{"/a"}})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(recorder.endpoints) != 1 || result.Operations[0].Metadata.Folder == nil {
+		t.Errorf("Expected a single call with folder metadata, got %v and %+v", recorder.endpoints, result.Operations[0])
+	}
+}
+
+func TestHandleDeleteBatch_JobFailed(t *testing.T) {
+	fastBatchPolling(t)
+	client, recorder := newRecordedClient(t, map[string][]string{
+		"delete_batch":       {`{".tag": "async_job_id", "async_job_id": "job-2"}`},
+		"delete_batch/check": {`{".tag": "failed", "failed": {".tag": "too_many_write_operations"}}`},
+	})
+
+	_, err := client.HandleDeleteBatch(mockContext(), DeleteBatchArgs{Paths: []string{"/a", "/b"}})
+	if !errors.Is(err, ErrRateLimited) || !strings.Contains(err.Error(), "too_many_write_operations") {
+		t.Fatalf("Expected rate limited job failure, got: %v", err)
+	}
+
+	entries, _ := recorder.bodies[0]["entries"].([]any)
+	if len(entries) != 2 || entries[0].(map[string]any)["path"] != "/a" {
+		t.Errorf("Unexpected delete_batch entries: %v", recorder.bodies[0])
+	}
+}
+
+func TestRunBatch_PollTimeout(t *testing.T) {
+	fastBatchPolling(t)
+	batchPollTimeout = 5 * time.Millisecond
+
+	inProgress := make([]string, 100)
+	for i := range inProgress {
+		inProgress[i] = `{".tag": "in_progress"}`
+	}
+	client, _ := newRecordedClient(t, map[string][]string{
+		"copy_batch_v2":       {`{".tag": "async_job_id", "async_job_id": "job-3"}`},
+		"copy_batch/check_v2": inProgress,
+	})
+
+	result, err := client.HandleCopyBatch(mockContext(), RelocationBatchArgs{Entries: []RelocationPath{{FromPath: "/a", ToPath: "/b"}}})
+	if err == nil || !strings.Contains(err.Error(), "still in progress") || result.AsyncJobID != "job-3" {
+		t.Fatalf("Expected timeout reporting job-3, got %v (%+v)", err, result)
+	}
+}
+
+func TestRunBatch_Cancelled(t *testing.T) {
+	fastBatchPolling(t)
+	batchPollInterval = time.Hour
+
+	client, _ := newRecordedClient(t, map[string][]string{
+		"delete_batch": {`{".tag": "async_job_id", "async_job_id": "job-4"}`},
+	})
+
+	s := server.NewServer("test")
+	ctx, err := server.NewContext(context.Background(), []byte(`{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "delete_batch"}}`), s.GetServer())
+	if err != nil {
+		t.Fatalf("failed to create context: %v", err)
+	}
+	ctx.Logger = mockLogger()
+	stop := utils.BeginCall(ctx)
+	defer stop()
+	time.AfterFunc(50*time.Millisecond, func() {
+		_ = s.GetServer().HandleCancelledNotification([]byte(`{"jsonrpc": "2.0", "method": "notifications/cancelled", "params": {"requestId": "1"}}`))
+	})
+
+	start := time.Now()
+	result, err := client.HandleDeleteBatch(ctx, DeleteBatchArgs{Paths: []string{"/a"}})
+	if err == nil || !strings.Contains(err.Error(), "cancelled") || result.AsyncJobID != "job-4" {
+		t.Fatalf("Expected a cancellation reporting job-4, got %v (%+v)", err, result)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected polling to stop on cancellation, took %s", elapsed)
+	}
+}
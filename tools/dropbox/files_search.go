@@ -0,0 +1,201 @@
+package dropbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/localrivet/gomcp/server"
+)
+
+// maxSearchPageSize is the largest max_results search_v2 accepts
+const maxSearchPageSize = 1000
+
+// searchCategories are the file categories search_v2 can filter by
+var searchCategories = []string{"image", "document", "pdf", "spreadsheet", "presentation", "audio", "video", "folder", "paper", "others"}
+
+type SearchArgs struct {
+	Query        string   `json:"query" description:"The string to search for in file names and, on plans that support it, file contents." required:"true"`
+	Path         string   `json:"path,omitempty" description:"Only search below this Dropbox folder. Defaults to the whole Dropbox."`
+	Extensions   []string `json:"extensions,omitempty" description:"Only return files with these extensions, e.g. [\"pdf\", \".docx\"]."`
+	Categories   []string `json:"categories,omitempty" description:"Only return these categories: image, document, pdf, spreadsheet, presentation, audio, video, folder, paper, others."`
+	FilenameOnly bool     `json:"filename_only,omitempty" description:"Only match the query against file names, not file contents."`
+	MaxResults   int      `json:"max_results,omitempty" description:"Stop fetching further pages once this many matches have been collected. 0 fetches all matches."`
+	Cursor       string   `json:"cursor,omitempty" description:"Cursor returned by a previous incomplete search, to continue where it stopped."`
+}
+
+// HighlightSpan is a part of a match snippet, highlighted when it matched the query
+type HighlightSpan struct {
+	Text          string `json:"highlight_str"`
+	IsHighlighted bool   `json:"is_highlighted"`
+}
+
+// SearchMatch is a single search result. Snippet renders the highlight spans
+// as text with the matching parts wrapped in ** **.
+type SearchMatch struct {
+	MatchType  string          `json:"match_type"`
+	Metadata   DropboxEntry    `json:"metadata"`
+	Highlights []HighlightSpan `json:"highlights,omitempty"`
+	Snippet    string          `json:"snippet,omitempty"`
+}
+
+// SearchResult is the result of the search tool.
+// When Complete is false, pass Cursor back to continue the search.
+type SearchResult struct {
+	Matches  []SearchMatch `json:"matches"`
+	Complete bool          `json:"complete"`
+	Cursor   string        `json:"cursor,omitempty"`
+	Pages    int           `json:"pages"`
+}
+
+// searchPage is a single page of a search_v2 or search/continue_v2 response
+type searchPage struct {
+	Matches []searchMatchResponse `json:"matches"`
+	Cursor  string                `json:"cursor"`
+	HasMore bool                  `json:"has_more"`
+}
+
+// searchMatchResponse is a match as returned by Dropbox, with its metadata wrapped in a union
+type searchMatchResponse struct {
+	MatchType struct {
+		Tag string `json:".tag"`
+	} `json:"match_type"`
+	Metadata struct {
+		Tag      string          `json:".tag"`
+		Metadata json.RawMessage `json:"metadata"`
+	} `json:"metadata"`
+	HighlightSpans []HighlightSpan `json:"highlight_spans"`
+}
+
+// HandleSearch implements the logic of the search tool
+// This handler searches for SearchArgs.Query with search_v2, following
+// search/continue_v2 until the results are exhausted or SearchArgs.MaxResults is reached.
+func (c *Client) HandleSearch(ctx *server.Context, args SearchArgs) (*SearchResult, error) {
+	ctx.Logger.Info("Handling Search tool call")
+
+	if err := c.requireAPIKey(ctx, "search dropbox"); err != nil {
+		return nil, err
+	}
+	if args.Query == "" && args.Cursor == "" {
+		return nil, fmt.Errorf("query cannot be empty")
+	}
+	if args.MaxResults < 0 {
+		return nil, fmt.Errorf("max_results cannot be negative: %d", args.MaxResults)
+	}
+
+	endpoint, body := "search/continue_v2", map[string]any{"cursor": args.Cursor}
+	if args.Cursor == "" {
+		options, err := searchOptions(args)
+		if err != nil {
+			return nil, err
+		}
+		endpoint, body = "search_v2", map[string]any{
+			"query":               args.Query,
+			"options":             options,
+			"match_field_options": map[string]any{"include_highlights": true},
+		}
+	}
+
+	result := &SearchResult{Matches: []SearchMatch{}}
+	for {
+		var page searchPage
+		if err := c.doRPCRequest(ctx, endpoint, body, &page); err != nil {
+			return nil, fmt.Errorf("search failed: %w", err)
+		}
+		result.Pages++
+
+		for _, match := range page.Matches {
+			searchMatch, err := newSearchMatch(match)
+			if err != nil {
+				// One match Dropbox describes in a way we don't know shouldn't fail the search
+				ctx.Logger.Warn("Skipping search match", "error", err)
+				continue
+			}
+			result.Matches = append(result.Matches, searchMatch)
+		}
+
+		if !page.HasMore {
+			result.Complete = true
+			break
+		}
+		if args.MaxResults > 0 && len(result.Matches) >= args.MaxResults {
+			result.Cursor = page.Cursor
+			break
+		}
+		endpoint, body = "search/continue_v2", map[string]any{"cursor": page.Cursor}
+	}
+
+	ctx.Logger.Info("Successfully searched dropbox", "query", args.Query, "count", len(result.Matches), "pages", result.Pages, "complete", result.Complete)
+	return result, nil
+}
+
+// searchOptions validates the search filters and builds the search_v2 options
+func searchOptions(args SearchArgs) (map[string]any, error) {
+	options := map[string]any{
+		"filename_only": args.FilenameOnly,
+	}
+	if args.Path != "" && args.Path != "/" {
+		options["path"] = args.Path
+	}
+	if args.MaxResults > 0 {
+		options["max_results"] = min(args.MaxResults, maxSearchPageSize)
+	}
+
+	if len(args.Extensions) > 0 {
+		extensions := make([]string, len(args.Extensions))
+		for i, ext := range args.Extensions {
+			ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+			if ext == "" {
+				return nil, fmt.Errorf("extensions cannot be empty")
+			}
+			extensions[i] = ext
+		}
+		options["file_extensions"] = extensions
+	}
+
+	if len(args.Categories) > 0 {
+		categories := make([]map[string]string, len(args.Categories))
+		for i, category := range args.Categories {
+			if !slices.Contains(searchCategories, category) {
+				return nil, fmt.Errorf("invalid category %q, expected one of %s", category, strings.Join(searchCategories, ", "))
+			}
+			categories[i] = map[string]string{".tag": category}
+		}
+		options["file_categories"] = categories
+	}
+
+	return options, nil
+}
+
+// newSearchMatch maps a Dropbox match to the existing metadata types
+func newSearchMatch(match searchMatchResponse) (SearchMatch, error) {
+	if match.Metadata.Tag != "metadata" {
+		return SearchMatch{}, fmt.Errorf("unsupported match metadata %q", match.Metadata.Tag)
+	}
+
+	var entry DropboxEntry
+	if err := json.Unmarshal(match.Metadata.Metadata, &entry); err != nil {
+		return SearchMatch{}, err
+	}
+
+	return SearchMatch{
+		MatchType:  match.MatchType.Tag,
+		Metadata:   entry,
+		Highlights: match.HighlightSpans,
+		Snippet:    renderSnippet(match.HighlightSpans),
+	}, nil
+}
+
+// renderSnippet joins highlight spans, wrapping the highlighted ones in ** **
+func renderSnippet(spans []HighlightSpan) string {
+	var snippet strings.Builder
+	for _, span := range spans {
+		if span.IsHighlighted {
+			snippet.WriteString("**" + span.Text + "**")
+		} else {
+			snippet.WriteString(span.Text)
+		}
+	}
+	return snippet.String()
+}
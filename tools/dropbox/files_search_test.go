@@ -0,0 +1,150 @@
+package dropbox
+
+import (
+	"strings"
+	"testing"
+)
+
+const searchPageOne = `{
+	"matches": [
+		{
+			"match_type": {".tag": "filename"},
+			"metadata": {".tag": "metadata", "metadata": {".tag": "file", "name": "report 2024.pdf", "path_display": "/docs/report 2024.pdf", "size": 10}},
+			"highlight_spans": [{"highlight_str": "report", "is_highlighted": true}, {"highlight_str": " 2024.pdf", "is_highlighted": false}]
+		}
+	],
+	"has_more": true,
+	"cursor": "cursor-1"
+}`
+
+const searchPageTwo = `{
+	"matches": [
+		{
+			"match_type": {".tag": "filename"},
+			"metadata": {".tag": "metadata", "metadata": {".tag": "folder", "name": "reports", "path_display": "/docs/reports"}}
+		}
+	],
+	"has_more": false
+}`
+
+func TestHandleSearch_FollowsContinue(t *testing.T) {
+	client, recorder := newRecordedClient(t, map[string][]string{
+		"search_v2":          {searchPageOne},
+		"search/continue_v2": {searchPageTwo},
+	})
+
+	result, err := client.HandleSearch(mockContext(), SearchArgs{
+		Query:      "report",
+		Path:       "/docs",
+		Extensions: []string{".PDF"},
+		Categories: []string{"pdf", "folder"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if !result.Complete || result.Pages != 2 || len(result.Matches) != 2 {
+		t.Fatalf("Expected 2 matches over 2 complete pages, got %+v", result)
+	}
+	if recorder.bodies[1]["cursor"] != "cursor-1" {
+		t.Errorf("Expected continue with cursor-1, got %v", recorder.bodies[1])
+	}
+
+	options := recorder.bodies[0]["options"].(map[string]any)
+	if options["path"] != "/docs" || options["file_extensions"].([]any)[0] != "pdf" {
+		t.Errorf("Unexpected search options: %v", options)
+	}
+	categories := options["file_categories"].([]any)
+	if len(categories) != 2 || categories[1].(map[string]any)[".tag"] != "folder" {
+		t.Errorf("Unexpected categories: %v", categories)
+	}
+
+	first := result.Matches[0]
+	if first.MatchType != "filename" || first.Metadata.File == nil || first.Metadata.File.Size != 10 {
+		t.Errorf("Unexpected first match: %+v", first)
+	}
+	if first.Snippet != "**report** 2024.pdf" {
+		t.Errorf("Expected highlighted snippet, got %q", first.Snippet)
+	}
+	if result.Matches[1].Metadata.Folder == nil || result.Matches[1].Snippet != "" {
+		t.Errorf("Unexpected second match: %+v", result.Matches[1])
+	}
+}
+
+func TestHandleSearch_MaxResultsAndCursor(t *testing.T) {
+	client, recorder := newRecordedClient(t, map[string][]string{
+		"search_v2":          {searchPageOne},
+		"search/continue_v2": {searchPageTwo},
+	})
+
+	result, err := client.HandleSearch(mockContext(), SearchArgs{Query: "report", MaxResults: 1})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Complete || result.Cursor != "cursor-1" || len(result.Matches) != 1 {
+		t.Fatalf("Expected incomplete result with cursor, got %+v", result)
+	}
+	if recorder.bodies[0]["options"].(map[string]any)["max_results"] != float64(1) {
+		t.Errorf("Expected max_results to be passed as page size, got %v", recorder.bodies[0])
+	}
+
+	result, err = client.HandleSearch(mockContext(), SearchArgs{Cursor: result.Cursor})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !result.Complete || len(result.Matches) != 1 || result.Matches[0].Metadata.Name() != "reports" {
+		t.Errorf("Expected the remaining match, got %+v", result)
+	}
+}
+
+func TestHandleSearch_UnknownMetadata(t *testing.T) {
+	client, _ := newRecordedClient(t, map[string][]string{
+		"search_v2": {`{
+			"matches": [
+				{"match_type": {".tag": "filename"}, "metadata": {".tag": "future_kind"}},
+				{"match_type": {".tag": "filename"}, "metadata": {".tag": "metadata", "metadata": {".tag": "paper_doc", "name": "notes", "path_display": "/notes"}}},
+				{"match_type": {".tag": "filename"}, "metadata": {".tag": "metadata", "metadata": {".tag": "folder", "name": "reports", "path_display": "/reports"}}}
+			],
+			"has_more": false
+		}`},
+	})
+
+	result, err := client.HandleSearch(mockContext(), SearchArgs{Query: "report"})
+	if err != nil {
+		t.Fatalf("Expected unknown metadata not to fail the search, got: %v", err)
+	}
+	if len(result.Matches) != 2 || result.Matches[0].Metadata.Tag != "paper_doc" || result.Matches[0].Metadata.Name() != "notes" {
+		t.Fatalf("Expected the unsupported match skipped and the unknown tag kept, got %+v", result.Matches)
+	}
+}
+
+func TestHandleSearch_InvalidArgs(t *testing.T) {
+	client := NewClient("test_api_key_123")
+
+	tests := []struct {
+		name     string
+		args     SearchArgs
+		contains string
+	}{
+		{name: "Empty query", args: SearchArgs{}, contains: "query cannot be empty"},
+		{name: "Negative max results", args: SearchArgs{Query: "x", MaxResults: -1}, contains: "max_results"},
+		{name: "Empty extension", args: SearchArgs{Query: "x", Extensions: []string{"."}}, contains: "extensions"},
+		{name: "Unknown category", args: SearchArgs{Query: "x", Categories: []string{"music"}}, contains: "invalid category"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.HandleSearch(mockContext(), tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.contains) {
+				t.Fatalf("Expected error containing %q, got: %v", tt.contains, err)
+			}
+		})
+	}
+}
+
+func TestHandleSearch_MissingAPIKey(t *testing.T) {
+	_, err := NewClient("").HandleSearch(mockContext(), SearchArgs{Query: "x"})
+	if err == nil || !strings.Contains(err.Error(), "unable to search dropbox") {
+		t.Fatalf("Expected missing API key error, got: %v", err)
+	}
+}
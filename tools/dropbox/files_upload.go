@@ -0,0 +1,177 @@
+package dropbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"golang-mcp-testing/tools/config"
+
+	"github.com/localrivet/gomcp/server"
+)
+
+// uploadChunkSize is the size of each upload session chunk; files up to this size
+// are sent with a single /files/upload call. A multiple of 4MB, as Dropbox recommends.
+var uploadChunkSize int64 = 8 * 1024 * 1024 // 8MB
+
+type FilesUploadArgs struct {
+	LocalPath  string `json:"local_path" description:"The local file to upload. Must be inside the allowed directories." required:"true"`
+	Path       string `json:"path" description:"The Dropbox path to upload to, e.g. /folder/file.txt." required:"true"`
+	Mode       string `json:"mode,omitempty" description:"Write mode: add (default, never overwrites), overwrite, or update (only overwrite the revision given in rev)."`
+	Rev        string `json:"rev,omitempty" description:"The revision to replace when mode is update."`
+	Autorename bool   `json:"autorename,omitempty" description:"Rename the upload instead of failing when it conflicts with an existing file."`
+}
+
+// uploadCommitInfo is the commit argument shared by /files/upload and upload_session/finish
+type uploadCommitInfo struct {
+	Path       string `json:"path"`
+	Mode       any    `json:"mode"`
+	Autorename bool   `json:"autorename"`
+	Mute       bool   `json:"mute"`
+}
+
+// uploadSessionCursor identifies a position in an upload session
+type uploadSessionCursor struct {
+	SessionID string `json:"session_id"`
+	Offset    int64  `json:"offset"`
+}
+
+// HandleFilesUpload implements the logic of the files_upload tool
+// This handler uploads the local file at FilesUploadArgs.LocalPath to FilesUploadArgs.Path,
+// using an upload session for files larger than a single chunk
+func (c *Client) HandleFilesUpload(ctx *server.Context, args FilesUploadArgs) (DropboxFileMetadata, error) {
+	ctx.Logger.Info("Handling FilesUpload tool call")
+
+	if err := c.requireAPIKey(ctx, "upload file"); err != nil {
+		return DropboxFileMetadata{}, err
+	}
+	if args.Path == "" {
+		return DropboxFileMetadata{}, fmt.Errorf("path cannot be empty")
+	}
+
+	commit, err := newUploadCommitInfo(args)
+	if err != nil {
+		return DropboxFileMetadata{}, err
+	}
+
+	policy, err := config.GetPathPolicy(ctx)
+	if err != nil {
+		return DropboxFileMetadata{}, fmt.Errorf("failed to load path policy: %w", err)
+	}
+	localPath, err := policy.Resolve(args.LocalPath)
+	if err != nil {
+		return DropboxFileMetadata{}, fmt.Errorf("invalid local path: %w", err)
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return DropboxFileMetadata{}, fmt.Errorf("failed to open local file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return DropboxFileMetadata{}, fmt.Errorf("failed to stat local file: %w", err)
+	}
+	if info.IsDir() {
+		return DropboxFileMetadata{}, fmt.Errorf("cannot upload a directory: %s", localPath)
+	}
+
+	ctx.Logger.Info("uploading file", "local_path", localPath, "path", args.Path, "size", info.Size())
+
+	var metadata DropboxFileMetadata
+	if info.Size() <= uploadChunkSize {
+		metadata, err = c.uploadSingle(ctx, file, info.Size(), commit)
+	} else {
+		metadata, err = c.uploadSession(ctx, file, info.Size(), commit)
+	}
+	if err != nil {
+		return DropboxFileMetadata{}, err
+	}
+
+	ctx.Logger.Info("Successfully uploaded file", "path", metadata.PathDisplay, "size", metadata.Size, "rev", metadata.Rev)
+	return metadata, nil
+}
+
+// newUploadCommitInfo validates the write mode and builds the commit argument
+func newUploadCommitInfo(args FilesUploadArgs) (uploadCommitInfo, error) {
+	commit := uploadCommitInfo{Path: args.Path, Autorename: args.Autorename}
+	switch args.Mode {
+	case "", "add":
+		commit.Mode = "add"
+	case "overwrite":
+		commit.Mode = "overwrite"
+	case "update":
+		if args.Rev == "" {
+			return commit, fmt.Errorf("rev is required when mode is update")
+		}
+		commit.Mode = map[string]string{".tag": "update", "update": args.Rev}
+	default:
+		return commit, fmt.Errorf("invalid mode %q, expected one of add, overwrite, update", args.Mode)
+	}
+	if args.Rev != "" && args.Mode != "update" {
+		return commit, fmt.Errorf("rev can only be used with mode update")
+	}
+	return commit, nil
+}
+
+// uploadSingle uploads a whole file with one /files/upload call
+func (c *Client) uploadSingle(ctx *server.Context, file io.ReaderAt, size int64, commit uploadCommitInfo) (DropboxFileMetadata, error) {
+	var metadata DropboxFileMetadata
+	err := c.doContentRequest(ctx, "upload", commit, io.NewSectionReader(file, 0, size), &metadata)
+	return metadata, err
+}
+
+// uploadSession uploads a file in chunks via upload_session/start, append_v2 and finish
+func (c *Client) uploadSession(ctx *server.Context, file io.ReaderAt, size int64, commit uploadCommitInfo) (DropboxFileMetadata, error) {
+	var start struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := c.doContentRequest(ctx, "upload_session/start", map[string]any{"close": false}, io.NewSectionReader(file, 0, uploadChunkSize), &start); err != nil {
+		return DropboxFileMetadata{}, fmt.Errorf("failed to start upload session: %w", err)
+	}
+
+	cursor := uploadSessionCursor{SessionID: start.SessionID, Offset: uploadChunkSize}
+	for size-cursor.Offset > uploadChunkSize {
+		ctx.Logger.Info("appending upload chunk", "session_id", cursor.SessionID, "offset", cursor.Offset, "size", size)
+		if err := c.doContentRequest(ctx, "upload_session/append_v2", map[string]any{"cursor": cursor, "close": false}, io.NewSectionReader(file, cursor.Offset, uploadChunkSize), nil); err != nil {
+			return DropboxFileMetadata{}, fmt.Errorf("failed to append to upload session at offset %d: %w", cursor.Offset, err)
+		}
+		cursor.Offset += uploadChunkSize
+	}
+
+	// The last chunk is sent along with the commit
+	var metadata DropboxFileMetadata
+	if err := c.doContentRequest(ctx, "upload_session/finish", map[string]any{"cursor": cursor, "commit": commit}, io.NewSectionReader(file, cursor.Offset, size-cursor.Offset), &metadata); err != nil {
+		return DropboxFileMetadata{}, fmt.Errorf("failed to finish upload session: %w", err)
+	}
+	return metadata, nil
+}
+
+// doContentRequest sends body to a content upload endpoint and decodes the JSON response into result (if not nil)
+func (c *Client) doContentRequest(ctx *server.Context, endpoint string, apiArg any, body *io.SectionReader, result any) error {
+	req, err := c.newContentRequest(endpoint, apiArg, body)
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", endpoint, err)
+	}
+
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return fmt.Errorf("%s http request failed: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return handleFailedHttpReq(resp)
+	}
+
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to unmarshal %s response: %w", endpoint, err)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package dropbox
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// uploadRecorder is a fake content server recording upload calls
+type uploadRecorder struct {
+	endpoints []string
+	apiArgs   []map[string]any
+	received  strings.Builder
+}
+
+func (u *uploadRecorder) server(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := strings.TrimPrefix(r.URL.Path, "/2/files/")
+		u.endpoints = append(u.endpoints, endpoint)
+
+		var apiArg map[string]any
+		if err := json.Unmarshal([]byte(r.Header.Get("Dropbox-API-Arg")), &apiArg); err != nil {
+			t.Errorf("Invalid Dropbox-API-Arg header: %v", err)
+		}
+		u.apiArgs = append(u.apiArgs, apiArg)
+
+		if r.Header.Get("Content-Type") != "application/octet-stream" {
+			t.Errorf("Expected octet-stream content type, got %s", r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		if r.ContentLength != int64(len(body)) {
+			t.Errorf("Expected Content-Length %d, got %d", len(body), r.ContentLength)
+		}
+		u.received.Write(body)
+
+		switch endpoint {
+		case "upload_session/start":
+			w.Write([]byte(`{"session_id": "session-1"}`))
+		case "upload_session/append_v2":
+			w.Write([]byte(`null`))
+		default:
+			w.Write([]byte(`{"name": "file.txt", "path_display": "/dest/file.txt", "rev": "rev-2", "size": ` + jsonNumber(u.received.Len()) + `}`))
+		}
+	}))
+}
+
+func jsonNumber(n int) string {
+	data, _ := json.Marshal(n)
+	return string(data)
+}
+
+func writeUploadFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+	return path
+}
+
+func TestHandleFilesUpload_SingleRequest(t *testing.T) {
+	recorder := &uploadRecorder{}
+	server := recorder.server(t)
+	defer server.Close()
+	client := NewClient("test_api_key_123", WithBaseURLs(server.URL, server.URL), WithHTTPClient(server.Client()))
+
+	localPath := writeUploadFile(t, "small file")
+	metadata, err := client.HandleFilesUpload(mockContext(), FilesUploadArgs{LocalPath: localPath, Path: "/dest/file.txt", Mode: "overwrite"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(recorder.endpoints) != 1 || recorder.endpoints[0] != "upload" {
+		t.Fatalf("Expected a single upload call, got %v", recorder.endpoints)
+	}
+	if recorder.apiArgs[0]["mode"] != "overwrite" || recorder.apiArgs[0]["path"] != "/dest/file.txt" {
+		t.Errorf("Unexpected upload argument: %v", recorder.apiArgs[0])
+	}
+	if recorder.received.String() != "small file" || metadata.Rev != "rev-2" {
+		t.Errorf("Expected uploaded content and metadata, got %q and %+v", recorder.received.String(), metadata)
+	}
+}
+
+func TestHandleFilesUpload_Session(t *testing.T) {
+	original := uploadChunkSize
+	uploadChunkSize = 4
+	defer func() { uploadChunkSize = original }()
+
+	recorder := &uploadRecorder{}
+	server := recorder.server(t)
+	defer server.Close()
+	client := NewClient("test_api_key_123", WithBaseURLs(server.URL, server.URL), WithHTTPClient(server.Client()))
+
+	localPath := writeUploadFile(t, "0123456789ab") // 12 bytes: start, append, finish
+	metadata, err := client.HandleFilesUpload(mockContext(), FilesUploadArgs{LocalPath: localPath, Path: "/dest/file.txt", Mode: "update", Rev: "rev-1"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := []string{"upload_session/start", "upload_session/append_v2", "upload_session/finish"}
+	if strings.Join(recorder.endpoints, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected %v, got %v", expected, recorder.endpoints)
+	}
+	if recorder.received.String() != "0123456789ab" || metadata.Size != 12 {
+		t.Errorf("Expected all content to be uploaded in order, got %q", recorder.received.String())
+	}
+
+	cursor := recorder.apiArgs[2]["cursor"].(map[string]any)
+	if cursor["session_id"] != "session-1" || cursor["offset"] != float64(8) {
+		t.Errorf("Unexpected finish cursor: %v", cursor)
+	}
+	mode := recorder.apiArgs[2]["commit"].(map[string]any)["mode"].(map[string]any)
+	if mode[".tag"] != "update" || mode["update"] != "rev-1" {
+		t.Errorf("Unexpected commit mode: %v", mode)
+	}
+}
+
+func TestHandleFilesUpload_InvalidArgs(t *testing.T) {
+	client := NewClient("test_api_key_123")
+	localPath := writeUploadFile(t, "content")
+
+	tests := []struct {
+		name string
+		args FilesUploadArgs
+	}{
+		{name: "Empty path", args: FilesUploadArgs{LocalPath: localPath}},
+		{name: "Invalid mode", args: FilesUploadArgs{LocalPath: localPath, Path: "/x", Mode: "append"}},
+		{name: "Update without rev", args: FilesUploadArgs{LocalPath: localPath, Path: "/x", Mode: "update"}},
+		{name: "Rev without update", args: FilesUploadArgs{LocalPath: localPath, Path: "/x", Rev: "rev-1"}},
+		{name: "Missing local file", args: FilesUploadArgs{LocalPath: localPath + ".missing", Path: "/x"}},
+		{name: "Directory", args: FilesUploadArgs{LocalPath: filepath.Dir(localPath), Path: "/x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.HandleFilesUpload(mockContext(), tt.args); err == nil {
+				t.Fatal("Expected error, got none")
+			}
+		})
+	}
+}
+
+func TestAPIArgHeader_EscapesNonASCII(t *testing.T) {
+	header, err := apiArgHeader(map[string]string{"path": "/Fotos/café 📷.jpg"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	for _, r := range header {
+		if r > 127 {
+			t.Fatalf("Expected ASCII-only header, got %q", header)
+		}
+	}
+
+	var decoded map[string]string
+	if err := json.Unmarshal([]byte(header), &decoded); err != nil || decoded["path"] != "/Fotos/café 📷.jpg" {
+		t.Errorf("Expected header to round-trip, got %q (err %v)", decoded["path"], err)
+	}
+}
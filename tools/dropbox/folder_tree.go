@@ -0,0 +1,98 @@
+package dropbox
+
+import (
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DropboxTreeNode is an entry of a listing along with the entries nested below it
+type DropboxTreeNode struct {
+	DropboxEntry
+	Children []*DropboxTreeNode `json:"children,omitempty"`
+}
+
+// MarshalJSON encodes the node as its entry's fields plus "children"
+func (n *DropboxTreeNode) MarshalJSON() ([]byte, error) {
+	fields, err := n.DropboxEntry.fields()
+	if err != nil {
+		return nil, err
+	}
+	if len(n.Children) > 0 {
+		fields["children"] = n.Children
+	}
+	return json.Marshal(fields)
+}
+
+// filterByDepth drops the listed folder itself and any entry more than maxDepth levels below it.
+// A maxDepth of 0 keeps every entry, as recursive listings without max_depth do.
+func filterByDepth(entries DropboxEntries, root string, maxDepth int) DropboxEntries {
+	root = normalizeDropboxPath(root)
+	filtered := make(DropboxEntries, 0, len(entries))
+	for _, entry := range entries {
+		depth := entryDepth(root, normalizeDropboxPath(entry.PathDisplay()))
+		if depth == 0 {
+			// A recursive listing includes the folder being listed
+			continue
+		}
+		if maxDepth > 0 && depth > maxDepth {
+			continue
+		}
+		filtered = append(filtered, entry)
+	}
+	return filtered
+}
+
+// buildFolderTree nests entries under their parent folders. Entries whose parent
+// is not part of the listing become roots of the tree. Siblings are sorted by name.
+func buildFolderTree(entries DropboxEntries) []*DropboxTreeNode {
+	nodes := make(map[string]*DropboxTreeNode, len(entries))
+	for _, entry := range entries {
+		nodes[normalizeDropboxPath(entry.PathDisplay())] = &DropboxTreeNode{DropboxEntry: entry}
+	}
+
+	var roots []*DropboxTreeNode
+	for _, entry := range entries {
+		entryPath := normalizeDropboxPath(entry.PathDisplay())
+		node := nodes[entryPath]
+		if parent, ok := nodes[path.Dir(entryPath)]; ok && parent != node {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	sortTreeNodes(roots)
+	return roots
+}
+
+// sortTreeNodes sorts nodes and their descendants by name
+func sortTreeNodes(nodes []*DropboxTreeNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return strings.ToLower(nodes[i].Name()) < strings.ToLower(nodes[j].Name())
+	})
+	for _, node := range nodes {
+		sortTreeNodes(node.Children)
+	}
+}
+
+// entryDepth returns how many levels entryPath is below root, or 0 for root itself
+func entryDepth(root, entryPath string) int {
+	rel := strings.TrimPrefix(entryPath, root)
+	rel = strings.Trim(rel, "/")
+	if rel == "" {
+		return 0
+	}
+	return strings.Count(rel, "/") + 1
+}
+
+// normalizeDropboxPath lowercases a Dropbox path (paths are case-insensitive)
+// and strips any trailing slash, mapping the root to ""
+func normalizeDropboxPath(p string) string {
+	p = strings.ToLower(strings.TrimSuffix(p, "/"))
+	if p == "." {
+		return ""
+	}
+	return p
+}
@@ -0,0 +1,199 @@
+package dropbox
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFilterByDepth(t *testing.T) {
+	entries := DropboxEntries{
+		folderEntry("Project", "/Project"),
+		folderEntry("src", "/Project/src"),
+		fileEntry("main.go", "/Project/src/main.go"),
+		folderEntry("deep", "/Project/src/a/deep"),
+		fileEntry("README.md", "/project/README.md"),
+	}
+
+	tests := []struct {
+		name     string
+		maxDepth int
+		expected []string
+	}{
+		{name: "Unlimited", maxDepth: 0, expected: []string{"src", "main.go", "deep", "README.md"}},
+		{name: "Direct children", maxDepth: 1, expected: []string{"src", "README.md"}},
+		{name: "Two levels", maxDepth: 2, expected: []string{"src", "main.go", "README.md"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filtered := filterByDepth(entries, "/Project", tt.maxDepth)
+			var names []string
+			for _, entry := range filtered {
+				names = append(names, entry.Name())
+			}
+			if len(names) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, names)
+			}
+			for i := range names {
+				if names[i] != tt.expected[i] {
+					t.Fatalf("Expected %v, got %v", tt.expected, names)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildFolderTree(t *testing.T) {
+	entries := DropboxEntries{
+		folderEntry("src", "/Project/src"),
+		fileEntry("main.go", "/Project/src/main.go"),
+		folderEntry("docs", "/Project/docs"),
+		fileEntry("util.go", "/Project/src/util.go"),
+	}
+
+	tree := buildFolderTree(entries)
+	if len(tree) != 2 || tree[0].Name() != "docs" || tree[1].Name() != "src" {
+		t.Fatalf("Expected roots [docs src], got %+v", tree)
+	}
+
+	src := tree[1]
+	if len(src.Children) != 2 || src.Children[0].Name() != "main.go" || src.Children[1].Name() != "util.go" {
+		t.Fatalf("Expected src children [main.go util.go], got %+v", src.Children)
+	}
+}
+
+func TestDropboxTreeNode_MarshalJSON(t *testing.T) {
+	tree := buildFolderTree(DropboxEntries{
+		folderEntry("src", "/src"),
+		fileEntry("main.go", "/src/main.go"),
+	})
+
+	data, err := json.Marshal(tree)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var decoded []map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Expected valid JSON, got: %v", err)
+	}
+
+	children, _ := decoded[0]["children"].([]any)
+	if decoded[0][".tag"] != "folder" || len(children) != 1 {
+		t.Fatalf("Expected folder with one child, got %s", data)
+	}
+
+	if child := children[0].(map[string]any); child[".tag"] != "file" || child["name"] != "main.go" {
+		t.Errorf("Expected main.go file child, got %v", child)
+	}
+}
+
+// depthListingServer answers non-recursive list_folder calls for a small tree,
+// recording the folders listed. /Project is split across two pages.
+func depthListingServer(t *testing.T) (*httptest.Server, *[]string) {
+	var listed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		if body["recursive"] == true {
+			t.Errorf("Expected a non-recursive request, got %v", body)
+		}
+
+		var page string
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/continue") && body["cursor"] == "project-2":
+			page = `{"entries": [{"name": "README.md", "path_display": "/Project/README.md", ".tag": "file"}], "has_more": false}`
+		case body["path"] == "/Project":
+			page = `{"entries": [{"name": "src", "path_display": "/Project/src", ".tag": "folder"}], "cursor": "project-2", "has_more": true}`
+		case body["path"] == "/Project/src":
+			page = `{"entries": [
+				{"name": "main.go", "path_display": "/Project/src/main.go", ".tag": "file"},
+				{"name": "a", "path_display": "/Project/src/a", ".tag": "folder"}
+			], "has_more": false}`
+		default:
+			t.Errorf("Unexpected request %s %v", r.URL.Path, body)
+			page = `{"entries": [], "has_more": false}`
+		}
+		if path, ok := body["path"].(string); ok {
+			listed = append(listed, path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(page))
+	}))
+	t.Cleanup(server.Close)
+	return server, &listed
+}
+
+func TestHandleListDropboxFolders_RecursiveTree(t *testing.T) {
+	server, listed := depthListingServer(t)
+	client := NewClient("test_api_key_123", WithBaseURLs(server.URL, server.URL), WithHTTPClient(server.Client()))
+
+	result, err := client.HandleListDropboxFolder(mockContext(), ListDropboxFoldersArgs{Path: "/Project", Recursive: true, MaxDepth: 2, Tree: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// /Project/src/a is at depth 2, so its contents are never fetched
+	if strings.Join(*listed, ",") != "/Project,/Project/src" {
+		t.Errorf("Expected only /Project and /Project/src to be listed, got %v", *listed)
+	}
+	if len(result.Entries) != 4 || !result.Complete || result.Pages != 3 {
+		t.Fatalf("Expected 4 entries within depth 2 over 3 pages, got %+v", result)
+	}
+
+	if len(result.Tree) != 2 || result.Tree[1].Name() != "src" || len(result.Tree[1].Children) != 2 {
+		t.Fatalf("Expected README.md and src with two children, got %+v", result.Tree)
+	}
+}
+
+func TestHandleListDropboxFolders_MaxDepthCursor(t *testing.T) {
+	server, _ := depthListingServer(t)
+	client := NewClient("test_api_key_123", WithBaseURLs(server.URL, server.URL), WithHTTPClient(server.Client()))
+
+	args := ListDropboxFoldersArgs{Path: "/Project", Recursive: true, MaxDepth: 2, MaxEntries: 1}
+	var names []string
+	for calls := 0; calls < 5; calls++ {
+		result, err := client.HandleListDropboxFolder(mockContext(), args)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		for _, entry := range result.Entries {
+			names = append(names, entry.Name())
+		}
+		if result.Complete {
+			break
+		}
+		args.Cursor = result.Cursor
+	}
+
+	if strings.Join(names, ",") != "src,README.md,main.go,a" {
+		t.Errorf("Expected the walk to resume breadth-first, got %v", names)
+	}
+
+	args.Cursor = "project-2"
+	if _, err := client.HandleListDropboxFolder(mockContext(), args); err == nil || !strings.Contains(err.Error(), "invalid cursor") {
+		t.Errorf("Expected a plain list_folder cursor to be rejected, got %v", err)
+	}
+}
+
+func TestHandleListDropboxFolders_MaxDepthRequiresRecursive(t *testing.T) {
+	_, err := NewClient("test_api_key_123").HandleListDropboxFolder(mockContext(), ListDropboxFoldersArgs{Path: "/Project", MaxDepth: 1})
+	if err == nil {
+		t.Fatal("Expected error for max_depth without recursive")
+	}
+}
+
+// folderEntry creates a folder listing entry for tests
+func folderEntry(name, pathDisplay string) DropboxEntry {
+	return DropboxEntry{Tag: TagFolder, Folder: &DropboxFolder{Name: name, PathDisplay: pathDisplay, Tag: TagFolder}}
+}
+
+// fileEntry creates a file listing entry for tests
+func fileEntry(name, pathDisplay string) DropboxEntry {
+	return DropboxEntry{Tag: TagFile, File: &DropboxFileMetadata{Name: name, PathDisplay: pathDisplay}}
+}
@@ -1,27 +1,29 @@
 package dropbox
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 )
 
-const DROPBOX_FILES_API_URL = "https://api.dropboxapi.com/2/files"
+const DROPBOX_API_URL = "https://api.dropboxapi.com"
+const DROPBOX_CONTENT_URL = "https://content.dropboxapi.com"
+const DROPBOX_AUTHORIZE_URL = "https://www.dropbox.com/oauth2/authorize"
+const DROPBOX_FILES_API_URL = DROPBOX_API_URL + "/2/files"
 
+// handleFailedHttpReq turns a non-200 response into an *APIError
 func handleFailedHttpReq(resp *http.Response) error {
+	endpoint := ""
+	if resp.Request != nil && resp.Request.URL != nil {
+		endpoint = strings.TrimPrefix(resp.Request.URL.Path, "/2/")
+	}
+
 	// Read the response body to get more details about the error
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("API request FAILED with status: %d, failed to read error response: %w", resp.StatusCode, err)
-	}
-
-	// Try to parse error response as JSON for structured error info
-	var errorResponse map[string]any
-	if json.Unmarshal(body, &errorResponse) == nil {
-		return fmt.Errorf("API request FAILURE with status: %d, error: %v", resp.StatusCode, errorResponse)
+		return &APIError{StatusCode: resp.StatusCode, Endpoint: endpoint, Body: fmt.Sprintf("failed to read error response: %v", err)}
 	}
 
-	// If JSON parsing fails, include raw response body
-	return fmt.Errorf("API request FAILING with status: %d, response: %s", resp.StatusCode, string(body))
+	return parseAPIError(resp.StatusCode, endpoint, body)
 }
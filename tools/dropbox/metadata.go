@@ -0,0 +1,136 @@
+package dropbox
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Tags of the entries returned by Dropbox listings
+const (
+	TagFile    = "file"
+	TagFolder  = "folder"
+	TagDeleted = "deleted"
+)
+
+// DropboxFolder is the metadata of a folder entry
+type DropboxFolder struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	PathDisplay    string `json:"path_display"`
+	PathLower      string `json:"path_lower,omitempty"`
+	Tag            string `json:".tag"`
+	SharedFolderID string `json:"shared_folder_id,omitempty"`
+}
+
+// DropboxDeletedMetadata is the metadata of an entry that has been deleted
+type DropboxDeletedMetadata struct {
+	Name                 string `json:"name"`
+	PathDisplay          string `json:"path_display"`
+	PathLower            string `json:"path_lower,omitempty"`
+	ParentSharedFolderID string `json:"parent_shared_folder_id,omitempty"`
+}
+
+type DropboxEntries []DropboxEntry
+
+// DropboxEntry is one entry of a listing: a tagged union of file, folder and
+// deleted metadata. Exactly one of File, Folder, Deleted and Other is set, matching Tag.
+// Other keeps the fields of a tag this package doesn't know, which Dropbox may add.
+// It is encoded to and from the flat Dropbox form, with the variant's fields next to ".tag".
+type DropboxEntry struct {
+	Tag     string
+	File    *DropboxFileMetadata
+	Folder  *DropboxFolder
+	Deleted *DropboxDeletedMetadata
+	Other   map[string]any
+}
+
+// UnmarshalJSON decodes the variant named by ".tag"
+func (e *DropboxEntry) UnmarshalJSON(data []byte) error {
+	var tagged struct {
+		Tag string `json:".tag"`
+	}
+	if err := json.Unmarshal(data, &tagged); err != nil {
+		return err
+	}
+
+	*e = DropboxEntry{Tag: tagged.Tag}
+	switch tagged.Tag {
+	case TagFile:
+		e.File = &DropboxFileMetadata{}
+		return json.Unmarshal(data, e.File)
+	case TagFolder:
+		e.Folder = &DropboxFolder{}
+		return json.Unmarshal(data, e.Folder)
+	case TagDeleted:
+		e.Deleted = &DropboxDeletedMetadata{}
+		return json.Unmarshal(data, e.Deleted)
+	default:
+		// Kept as is rather than failing the whole listing
+		return json.Unmarshal(data, &e.Other)
+	}
+}
+
+// MarshalJSON encodes the entry in the flat Dropbox form
+func (e DropboxEntry) MarshalJSON() ([]byte, error) {
+	fields, err := e.fields()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// fields returns the entry's variant as a map including ".tag"
+func (e DropboxEntry) fields() (map[string]any, error) {
+	var variant any
+	switch {
+	case e.File != nil:
+		variant = e.File
+	case e.Folder != nil:
+		variant = e.Folder
+	case e.Deleted != nil:
+		variant = e.Deleted
+	case e.Other != nil:
+		variant = e.Other
+	default:
+		return nil, fmt.Errorf("metadata entry with tag %q has no value", e.Tag)
+	}
+
+	data, err := json.Marshal(variant)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]any{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	fields[".tag"] = e.Tag
+	return fields, nil
+}
+
+// Name returns the entry's name regardless of its tag
+func (e DropboxEntry) Name() string {
+	switch {
+	case e.File != nil:
+		return e.File.Name
+	case e.Folder != nil:
+		return e.Folder.Name
+	case e.Deleted != nil:
+		return e.Deleted.Name
+	}
+	name, _ := e.Other["name"].(string)
+	return name
+}
+
+// PathDisplay returns the entry's display path regardless of its tag
+func (e DropboxEntry) PathDisplay() string {
+	switch {
+	case e.File != nil:
+		return e.File.PathDisplay
+	case e.Folder != nil:
+		return e.Folder.PathDisplay
+	case e.Deleted != nil:
+		return e.Deleted.PathDisplay
+	}
+	pathDisplay, _ := e.Other["path_display"].(string)
+	return pathDisplay
+}
@@ -0,0 +1,146 @@
+package dropbox
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDropboxEntry_UnmarshalJSON(t *testing.T) {
+	data := []byte(`[
+		{".tag": "file", "name": "photo.jpg", "path_display": "/photo.jpg", "id": "id:1", "size": 2048, "rev": "015f", "content_hash": "abc", "client_modified": "2024-01-01T00:00:00Z", "server_modified": "2024-01-02T00:00:00Z",
+			"media_info": {".tag": "metadata", "metadata": {".tag": "photo", "dimensions": {"height": 768, "width": 1024}}}},
+		{".tag": "folder", "name": "docs", "path_display": "/docs", "id": "id:2"},
+		{".tag": "deleted", "name": "old.txt", "path_display": "/old.txt"}
+	]`)
+
+	var entries DropboxEntries
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	file := entries[0].File
+	if file == nil || file.Size != 2048 || file.Rev != "015f" || file.ContentHash != "abc" || file.ServerModified != "2024-01-02T00:00:00Z" {
+		t.Fatalf("Expected file metadata to be kept, got %+v", file)
+	}
+	if file.MediaInfo == nil || file.MediaInfo.Metadata == nil || file.MediaInfo.Metadata.Dimensions.Width != 1024 {
+		t.Errorf("Expected media info to be kept, got %+v", file.MediaInfo)
+	}
+
+	if entries[1].Folder == nil || entries[1].Folder.ID != "id:2" {
+		t.Errorf("Expected folder metadata, got %+v", entries[1])
+	}
+
+	if entries[2].Deleted == nil || entries[2].Name() != "old.txt" {
+		t.Errorf("Expected deleted metadata, got %+v", entries[2])
+	}
+}
+
+func TestDropboxEntry_UnknownTag(t *testing.T) {
+	var entries DropboxEntries
+	data := []byte(`[{".tag": "mystery", "name": "x", "path_display": "/x", "size": 3}, {".tag": "folder", "name": "docs", "path_display": "/docs"}]`)
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatalf("Expected an unknown tag not to fail the listing, got: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Tag != "mystery" || entries[0].Name() != "x" || entries[0].PathDisplay() != "/x" {
+		t.Fatalf("Expected the unknown entry to be kept, got %+v", entries)
+	}
+
+	encoded, err := json.Marshal(entries[0])
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Expected valid JSON, got: %v", err)
+	}
+	if decoded[".tag"] != "mystery" || decoded["size"] != float64(3) {
+		t.Errorf("Expected the unknown entry's fields to be passed through, got %s", encoded)
+	}
+
+	// Listings only return the tags asked for, file and folder by default
+	filter, err := newEntryFilter(nil, nil, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if filtered := filter.apply(entries); len(filtered) != 1 || filtered[0].Name() != "docs" {
+		t.Errorf("Expected the unknown entry to be skipped, got %+v", filtered)
+	}
+}
+
+func TestDropboxEntry_MarshalJSON(t *testing.T) {
+	data, err := json.Marshal(fileEntry("a.txt", "/a.txt"))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Expected valid JSON, got: %v", err)
+	}
+
+	if decoded[".tag"] != "file" || decoded["name"] != "a.txt" {
+		t.Errorf("Expected flat file entry, got %s", data)
+	}
+}
+
+func TestEntryFilter(t *testing.T) {
+	oldFile := fileEntry("old.go", "/old.go")
+	oldFile.File.ServerModified = "2023-01-01T00:00:00Z"
+	newFile := fileEntry("new.go", "/new.go")
+	newFile.File.ServerModified = "2024-06-01T00:00:00Z"
+	notes := fileEntry("Notes.MD", "/Notes.MD")
+	notes.File.ServerModified = "2024-06-01T00:00:00Z"
+	folder := folderEntry("src", "/src")
+	deleted := DropboxEntry{Tag: TagDeleted, Deleted: &DropboxDeletedMetadata{Name: "gone.go", PathDisplay: "/gone.go"}}
+
+	entries := DropboxEntries{oldFile, newFile, notes, folder, deleted}
+
+	tests := []struct {
+		name          string
+		tags          []string
+		extensions    []string
+		modifiedSince string
+		expected      []string
+	}{
+		{name: "Defaults", expected: []string{"old.go", "new.go", "Notes.MD", "src"}},
+		{name: "Files only", tags: []string{"file"}, expected: []string{"old.go", "new.go", "Notes.MD"}},
+		{name: "Deleted only", tags: []string{"deleted"}, expected: []string{"gone.go"}},
+		{name: "Extension", extensions: []string{".md"}, expected: []string{"Notes.MD", "src"}},
+		{name: "Modified since", tags: []string{"file"}, modifiedSince: "2024-01-01T00:00:00Z", expected: []string{"new.go", "Notes.MD"}},
+		{name: "Combined", tags: []string{"file"}, extensions: []string{"go"}, modifiedSince: "2024-01-01T00:00:00Z", expected: []string{"new.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := newEntryFilter(tt.tags, tt.extensions, tt.modifiedSince)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			var names []string
+			for _, entry := range filter.apply(entries) {
+				names = append(names, entry.Name())
+			}
+			if len(names) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, names)
+			}
+			for i := range names {
+				if names[i] != tt.expected[i] {
+					t.Fatalf("Expected %v, got %v", tt.expected, names)
+				}
+			}
+		})
+	}
+}
+
+func TestEntryFilter_InvalidArgs(t *testing.T) {
+	if _, err := newEntryFilter([]string{"symlink"}, nil, ""); err == nil {
+		t.Error("Expected error for invalid tag")
+	}
+	if _, err := newEntryFilter(nil, []string{"."}, ""); err == nil {
+		t.Error("Expected error for empty extension")
+	}
+	if _, err := newEntryFilter(nil, nil, "yesterday"); err == nil {
+		t.Error("Expected error for invalid modified_since")
+	}
+}
@@ -0,0 +1,109 @@
+package dropbox
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang-mcp-testing/internal/logging"
+
+	"github.com/localrivet/gomcp/server"
+)
+
+const (
+	leakedAccessToken  = "sl.u.AbCdEfGhIjKlMnOpQrStUvWxYz0123456789"
+	leakedRefreshToken = "refresh-token-0123456789"
+)
+
+// redactingContext returns a context whose logger redacts the way the server's
+// does, writing to the returned buffer
+func redactingContext(t *testing.T) (*server.Context, *bytes.Buffer) {
+	t.Helper()
+	var buf bytes.Buffer
+	handler, err := logging.NewRedactingHandler(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}),
+		[]string{leakedAccessToken, leakedRefreshToken}, nil)
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	return &server.Context{Logger: slog.New(handler)}, &buf
+}
+
+// leakingServer rejects every call with an error body echoing the request's
+// Authorization header, the worst case for a tool that logs its errors
+func leakingServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth2/token" {
+			fmt.Fprintf(w, `{"access_token": %q, "expires_in": 14400}`, leakedAccessToken)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Error in call: invalid header Authorization: %s", r.Header.Get("Authorization"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestToolLogs_NeverContainTokens(t *testing.T) {
+	fake := leakingServer(t)
+	clients := map[string]*Client{
+		"API key": NewClient(leakedAccessToken, WithBaseURLs(fake.URL, fake.URL), WithHTTPClient(fake.Client()), WithRetryPolicy(fastRetries)),
+		"Refresh token": NewClient("", WithBaseURLs(fake.URL, fake.URL), WithHTTPClient(fake.Client()), WithRetryPolicy(fastRetries),
+			WithRefreshToken("app-key", "app-secret", leakedRefreshToken)),
+	}
+	localPath := writeUploadFile(t, "content")
+
+	tools := map[string]func(*Client, *server.Context) error{
+		"list folder": func(c *Client, ctx *server.Context) error {
+			_, err := c.HandleListDropboxFolder(ctx, ListDropboxFoldersArgs{Path: "/docs"})
+			return err
+		},
+		"search": func(c *Client, ctx *server.Context) error {
+			_, err := c.HandleSearch(ctx, SearchArgs{Query: "report"})
+			return err
+		},
+		"download": func(c *Client, ctx *server.Context) error {
+			_, err := c.HandleFilesDownload(ctx, FilesDownloadArgs{Path: "/docs/report.pdf", Destination: t.TempDir()})
+			return err
+		},
+		"upload": func(c *Client, ctx *server.Context) error {
+			_, err := c.HandleFilesUpload(ctx, FilesUploadArgs{LocalPath: localPath, Path: "/docs/file.txt"})
+			return err
+		},
+		"move": func(c *Client, ctx *server.Context) error {
+			_, err := c.HandleMove(ctx, RelocationArgs{FromPath: "/a", ToPath: "/b"})
+			return err
+		},
+		"delete batch": func(c *Client, ctx *server.Context) error {
+			_, err := c.HandleDeleteBatch(ctx, DeleteBatchArgs{Paths: []string{"/a", "/b"}})
+			return err
+		},
+	}
+
+	for clientName, client := range clients {
+		for toolName, tool := range tools {
+			t.Run(clientName+"/"+toolName, func(t *testing.T) {
+				ctx, buf := redactingContext(t)
+				err := tool(client, ctx)
+				if err == nil {
+					t.Fatal("Expected the call to fail")
+				}
+				// Errors returned by a tool end up in the server log
+				ctx.Logger.Error("Tool call failed", "error", err)
+
+				out := buf.String()
+				for _, secret := range []string{leakedAccessToken, leakedRefreshToken} {
+					if strings.Contains(out, secret) {
+						t.Errorf("Expected %q to be redacted, got: %s", secret, out)
+					}
+				}
+				if !strings.Contains(out, logging.Redacted) {
+					t.Errorf("Expected the echoed token to be redacted, got: %s", out)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package dropbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang-mcp-testing/internal/utils"
+
+	"github.com/localrivet/gomcp/server"
+)
+
+// maxInspectedErrorBody bounds how much of an error response is read to decide whether to retry
+const maxInspectedErrorBody = 64 * 1024
+
+// RetryPolicy controls how failed Dropbox requests are retried. Rate limited
+// (429 and too_many_write_operations) and 5xx responses, timeouts and network
+// errors are retried with jittered exponential backoff, or after Retry-After when given.
+// Requests to non-idempotent endpoints are only retried when Dropbox can't
+// have applied them; see nonIdempotentEndpoints.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first. Values below 1 mean 1.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry; it doubles with every attempt
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff. It does not cap a Retry-After requested by Dropbox.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is the retry policy of clients created without WithRetryPolicy
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// nonIdempotentEndpoints change state in a way that a repeated call doesn't
+// reproduce: a repeated append fails with an incorrect offset, a repeated move
+// or create reports a conflict with its own result. Once such a request has been
+// sent it is not retried after a timeout, network error or 5xx response, only
+// after a rate limit or 503, which Dropbox returns without applying the request.
+var nonIdempotentEndpoints = map[string]bool{
+	"upload": true, "upload_session/append_v2": true, "upload_session/finish": true,
+	"move_v2": true, "copy_v2": true, "create_folder_v2": true, "delete_v2": true,
+	"move_batch_v2": true, "copy_batch_v2": true, "create_folder_batch": true, "delete_batch": true,
+}
+
+// Default per-attempt timeouts. Content requests stream whole files, so they get longer.
+const (
+	defaultRPCTimeout     = 30 * time.Second
+	defaultContentTimeout = 10 * time.Minute
+)
+
+// WithRetryPolicy sets how failed requests are retried
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithTimeouts sets the per-attempt timeouts of RPC and content (upload and download) requests.
+// A content attempt's timeout covers reading the response body.
+func WithTimeouts(rpc, content time.Duration) ClientOption {
+	return func(c *Client) {
+		c.rpcTimeout = rpc
+		c.contentTimeout = content
+	}
+}
+
+// backoff returns the jittered delay before retry number attempt (starting at 1),
+// a random duration between half and all of BaseDelay*2^(attempt-1), capped at MaxDelay
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// do sends req, retrying according to the client's retry policy. Every attempt
+// has its own timeout, and all attempts stop when the tool call is cancelled.
+// Retries replay the body via req.GetBody, which requests built by newRPCRequest
+// and newContentRequest set. The returned response's body must be closed.
+func (c *Client) do(ctx *server.Context, req *http.Request) (*http.Response, error) {
+	callCtx, stop := requestContext(ctx)
+
+	timeout := c.rpcTimeout
+	if req.Header.Get("Dropbox-API-Arg") != "" {
+		timeout = c.contentTimeout
+	}
+	maxAttempts := max(c.retryPolicy.MaxAttempts, 1)
+	forceRefresh, refreshed := false, false
+	endpoint := strings.TrimPrefix(req.URL.Path, "/2/files/")
+	idempotent := !nonIdempotentEndpoints[endpoint]
+
+	for attempt := 1; ; attempt++ {
+		attemptReq, err := cloneRequest(req, callCtx, attempt)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		attemptCtx, cancel := context.WithTimeout(callCtx, timeout)
+		// Once the headers are out, Dropbox may act on the request even if the attempt fails
+		var sent atomic.Bool
+		attemptReq = attemptReq.WithContext(httptrace.WithClientTrace(attemptCtx, &httptrace.ClientTrace{
+			WroteHeaders: func() { sent.Store(true) },
+		}))
+		if err := c.authorize(ctx, attemptReq, forceRefresh); err != nil {
+			cancel()
+			stop()
+			return nil, err
+		}
+		forceRefresh = false
+
+		wait := time.Duration(-1)
+		var reason string
+		resp, err := c.httpClient.Do(attemptReq)
+		if err != nil {
+			cancel()
+			if callCtx.Err() != nil {
+				stop()
+				return nil, fmt.Errorf("request cancelled: %w", err)
+			}
+			if attempt >= maxAttempts {
+				stop()
+				return nil, err
+			}
+			if !idempotent && sent.Load() {
+				stop()
+				return nil, fmt.Errorf("not retrying %s, which may already have been applied: %w", endpoint, err)
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				reason = fmt.Sprintf("timed out after %s", timeout)
+			} else {
+				reason = err.Error()
+			}
+		} else {
+			if c.oauth != nil && !refreshed && accessTokenExpired(resp) {
+				// Refresh once and retry straight away; this doesn't count as a failed attempt
+				resp.Body.Close()
+				cancel()
+				ctx.Logger.Info("Dropbox access token expired, refreshing")
+				forceRefresh, refreshed = true, true
+				attempt--
+				continue
+			}
+
+			var retry bool
+			wait, retry = retryDelay(resp, idempotent)
+			if !retry || attempt >= maxAttempts {
+				// Keep the attempt alive until the caller is done with the body
+				resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: func() { cancel(); stop() }}
+				return resp, nil
+			}
+			resp.Body.Close()
+			cancel()
+			reason = fmt.Sprintf("status %d", resp.StatusCode)
+		}
+
+		if wait < 0 {
+			wait = c.retryPolicy.backoff(attempt)
+		}
+		ctx.Logger.Warn("Dropbox request failed, retrying", "url", req.URL.Path, "attempt", attempt, "reason", reason, "wait", wait)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-callCtx.Done():
+			timer.Stop()
+			stop()
+			return nil, fmt.Errorf("request cancelled while waiting to retry: %w", callCtx.Err())
+		}
+	}
+}
+
+// cloneRequest prepares an attempt of req, rewinding its body for retries
+func cloneRequest(req *http.Request, ctx context.Context, attempt int) (*http.Request, error) {
+	clone := req.Clone(ctx)
+	if attempt == 1 || req.Body == nil || req.Body == http.NoBody {
+		return clone, nil
+	}
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("cannot retry request to %s: body cannot be replayed", req.URL.Path)
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("failed to rewind request body: %w", err)
+	}
+	clone.Body = body
+	return clone, nil
+}
+
+// retryDelay decides whether a response should be retried and how long to wait first.
+// A negative wait means no Retry-After was given and the backoff applies.
+// For 409 responses the body is peeked at to look for too_many_write_operations.
+// Of the 5xx responses, only 503 is retried for non-idempotent requests.
+func retryDelay(resp *http.Response, idempotent bool) (time.Duration, bool) {
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return parseRetryAfter(resp.Header.Get("Retry-After")), true
+	case resp.StatusCode == http.StatusServiceUnavailable || (idempotent && resp.StatusCode >= http.StatusInternalServerError):
+		return parseRetryAfter(resp.Header.Get("Retry-After")), true
+	case resp.StatusCode == http.StatusConflict:
+		if peekAPIError(resp).HasTag("too_many_write_operations") {
+			return parseRetryAfter(resp.Header.Get("Retry-After")), true
+		}
+	}
+	return -1, false
+}
+
+// accessTokenExpired reports whether resp is a 401 expired_access_token error
+func accessTokenExpired(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusUnauthorized && peekAPIError(resp).HasTag("expired_access_token")
+}
+
+// peekAPIError parses the start of an error response and puts the body back,
+// so the caller still sees the whole error
+func peekAPIError(resp *http.Response) *APIError {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxInspectedErrorBody))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+	if err != nil {
+		return &APIError{StatusCode: resp.StatusCode}
+	}
+	return parseAPIError(resp.StatusCode, "", body)
+}
+
+// parseRetryAfter parses a Retry-After header in seconds or as an HTTP date,
+// returning -1 when it is missing or invalid
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return -1
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return -1
+}
+
+// cancelOnClose releases an attempt's context once its response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// requestContext returns a context that is cancelled when the MCP client cancels
+// the tool call, or when the returned cancel function is called
+func requestContext(ctx *server.Context) (context.Context, context.CancelFunc) {
+	return context.WithCancel(utils.CallContext(ctx))
+}
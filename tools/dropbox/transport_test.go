@@ -0,0 +1,254 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fastRetries retries quickly so throttling tests don't sleep
+var fastRetries = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+// throttlingServer fails the first failures requests with failure, then answers with success
+func throttlingServer(t *testing.T, failures int32, failure http.HandlerFunc, success string) (*httptest.Server, *atomic.Int32) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) <= failures {
+			failure(w, r)
+			return
+		}
+		w.Write([]byte(success))
+	}))
+	t.Cleanup(server.Close)
+	return server, &attempts
+}
+
+func newRetryingClient(server *httptest.Server, opts ...ClientOption) *Client {
+	opts = append([]ClientOption{WithBaseURLs(server.URL, server.URL), WithHTTPClient(server.Client()), WithRetryPolicy(fastRetries)}, opts...)
+	return NewClient("test_api_key_123", opts...)
+}
+
+func TestDo_RetriesRateLimitWithRetryAfter(t *testing.T) {
+	server, attempts := throttlingServer(t, 2, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error_summary": "too_many_requests/..", "error": {"reason": {".tag": "too_many_requests"}, "retry_after": 0}}`))
+	}, `{"entries": [], "has_more": false}`)
+
+	result, err := newRetryingClient(server).HandleListDropboxFolder(mockContext(), ListDropboxFoldersArgs{Path: "/throttled"})
+	if err != nil {
+		t.Fatalf("Expected throttled request to succeed after retries, got: %v", err)
+	}
+	if attempts.Load() != 3 || !result.Complete {
+		t.Errorf("Expected 3 attempts and a complete result, got %d and %+v", attempts.Load(), result)
+	}
+}
+
+func TestDo_RetriesTooManyWriteOperations(t *testing.T) {
+	server, attempts := throttlingServer(t, 1, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"error_summary": "too_many_write_operations/..", "error": {".tag": "too_many_write_operations"}}`))
+	}, `{"metadata": {"name": "new", "path_display": "/new"}}`)
+
+	result, err := newRetryingClient(server).HandleCreateFolder(mockContext(), CreateFolderArgs{Path: "/new"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if attempts.Load() != 2 || result.Operations[0].Metadata.PathDisplay() != "/new" {
+		t.Errorf("Expected 2 attempts and folder metadata, got %d and %+v", attempts.Load(), result)
+	}
+}
+
+func TestDo_DoesNotRetryOtherConflicts(t *testing.T) {
+	server, attempts := throttlingServer(t, 1, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"error_summary": "path/conflict/folder/..", "error": {".tag": "path", "path": {".tag": "conflict", "conflict": {".tag": "folder"}}}}`))
+	}, `{}`)
+
+	_, err := newRetryingClient(server).HandleCreateFolder(mockContext(), CreateFolderArgs{Path: "/exists"})
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("Expected the conflict body to reach the caller intact, got: %v", err)
+	}
+	if attempts.Load() != 1 {
+		t.Errorf("Expected a single attempt, got %d", attempts.Load())
+	}
+}
+
+func TestDo_GivesUpAfterMaxAttempts(t *testing.T) {
+	server, attempts := throttlingServer(t, 100, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("upstream unavailable"))
+	}, `{}`)
+
+	_, err := newRetryingClient(server).HandleDelete(mockContext(), DeleteArgs{Path: "/a"})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || !errors.Is(err, ErrServerError) {
+		t.Fatalf("Expected the last 503 as an APIError, got: %v", err)
+	}
+	if attempts.Load() != int32(fastRetries.MaxAttempts) {
+		t.Errorf("Expected %d attempts, got %d", fastRetries.MaxAttempts, attempts.Load())
+	}
+}
+
+func TestDo_RetriesTimeouts(t *testing.T) {
+	server, attempts := throttlingServer(t, 1, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}, `{"entries": [], "has_more": false}`)
+	client := newRetryingClient(server, WithTimeouts(50*time.Millisecond, 50*time.Millisecond))
+
+	if _, err := client.HandleListDropboxFolder(mockContext(), ListDropboxFoldersArgs{Path: "/slow"}); err != nil {
+		t.Fatalf("Expected listing to succeed after a timed out attempt, got: %v", err)
+	}
+	if attempts.Load() != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts.Load())
+	}
+}
+
+func TestDo_ReplaysBody(t *testing.T) {
+	bodies := make(chan string, 2)
+	server, attempts := throttlingServer(t, 1, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, `{"name": "file.txt", "path_display": "/file.txt", "size": 7}`)
+
+	localPath := writeUploadFile(t, "content")
+	metadata, err := newRetryingClient(server).HandleFilesUpload(mockContext(), FilesUploadArgs{LocalPath: localPath, Path: "/file.txt"})
+	if err != nil {
+		t.Fatalf("Expected upload to succeed after a 503, got: %v", err)
+	}
+	if attempts.Load() != 2 || metadata.Size != 7 {
+		t.Errorf("Expected 2 attempts, got %d", attempts.Load())
+	}
+	if body := <-bodies; body != "content" {
+		t.Errorf("Expected the first attempt to carry the full body, got %q", body)
+	}
+}
+
+func TestDo_RetriesNetworkErrors(t *testing.T) {
+	server, attempts := throttlingServer(t, 1, dropConnection(t), `{"entries": [], "has_more": false}`)
+
+	if _, err := newRetryingClient(server).HandleListDropboxFolder(mockContext(), ListDropboxFoldersArgs{Path: "/a"}); err != nil {
+		t.Fatalf("Expected a dropped connection to be retried, got: %v", err)
+	}
+	if attempts.Load() != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts.Load())
+	}
+}
+
+func TestDo_DoesNotRetrySentNonIdempotentRequests(t *testing.T) {
+	tests := []struct {
+		name    string
+		failure http.HandlerFunc
+		opts    []ClientOption
+	}{
+		{name: "Timeout", failure: func(w http.ResponseWriter, r *http.Request) { time.Sleep(200 * time.Millisecond) }, opts: []ClientOption{WithTimeouts(50*time.Millisecond, 50*time.Millisecond)}},
+		{name: "Dropped connection", failure: dropConnection(t)},
+		{name: "Internal server error", failure: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, attempts := throttlingServer(t, 1, tt.failure, `{"metadata": {".tag": "file", "name": "b", "path_display": "/b"}}`)
+
+			_, err := newRetryingClient(server, tt.opts...).HandleMove(mockContext(), RelocationArgs{FromPath: "/a", ToPath: "/b"})
+			if err == nil {
+				t.Fatal("Expected the failed move to be reported")
+			}
+			if attempts.Load() != 1 {
+				t.Errorf("Expected a single attempt, got %d", attempts.Load())
+			}
+		})
+	}
+}
+
+func TestDo_RetriesUnsentNonIdempotentRequests(t *testing.T) {
+	server, attempts := throttlingServer(t, 0, nil, `{"metadata": {".tag": "file", "name": "b", "path_display": "/b"}}`)
+
+	// The first connection attempt fails before anything is sent
+	var dials atomic.Int32
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	dial := transport.DialContext
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if dials.Add(1) == 1 {
+			return nil, errors.New("connection refused")
+		}
+		return dial(ctx, network, addr)
+	}
+
+	client := newRetryingClient(server, WithHTTPClient(&http.Client{Transport: transport}))
+	if _, err := client.HandleMove(mockContext(), RelocationArgs{FromPath: "/a", ToPath: "/b"}); err != nil {
+		t.Fatalf("Expected the unsent move to be retried, got: %v", err)
+	}
+	if dials.Load() != 2 || attempts.Load() != 1 {
+		t.Errorf("Expected 2 dials and 1 request, got %d and %d", dials.Load(), attempts.Load())
+	}
+}
+
+// dropConnection closes the connection without responding
+func dropConnection(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Fatalf("failed to hijack connection: %v", err)
+		}
+		conn.Close()
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{attempt: 1, min: 50 * time.Millisecond, max: 100 * time.Millisecond},
+		{attempt: 3, min: 200 * time.Millisecond, max: 400 * time.Millisecond},
+		{attempt: 8, min: 500 * time.Millisecond, max: time.Second},
+	}
+
+	for _, tt := range tests {
+		for range 20 {
+			if d := policy.backoff(tt.attempt); d < tt.min || d > tt.max {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", tt.attempt, d, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := parseRetryAfter("3"); d != 3*time.Second {
+		t.Errorf("Expected 3s, got %s", d)
+	}
+	if d := parseRetryAfter(""); d >= 0 {
+		t.Errorf("Expected no delay for a missing header, got %s", d)
+	}
+	if d := parseRetryAfter("soon"); d >= 0 {
+		t.Errorf("Expected no delay for an invalid header, got %s", d)
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if d := parseRetryAfter(date); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("Expected about an hour for %s, got %s", date, d)
+	}
+}
+
+func TestRequestContext_ManualContext(t *testing.T) {
+	callCtx, stop := requestContext(mockContext())
+	if callCtx.Err() != nil {
+		t.Errorf("Expected a context built outside the server not to be cancelled, got %v", callCtx.Err())
+	}
+	stop()
+	stop()
+	if !strings.Contains(callCtx.Err().Error(), "canceled") {
+		t.Errorf("Expected stop to cancel the request context, got %v", callCtx.Err())
+	}
+}
@@ -1,28 +1,62 @@
 package terminal
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
-	"strings"
+
+	"golang-mcp-testing/tools/config"
 
 	"github.com/localrivet/gomcp/server"
 )
 
+const (
+	// defaultCatLimit is the page size used when no limit is provided
+	defaultCatLimit = 256 * 1024 // 256KB
+	// maxCatLimit caps how much a single call may return
+	maxCatLimit = 10 * 1024 * 1024 // 10MB
+	// defaultCatLineCount is the number of lines returned in line mode when no count is provided
+	defaultCatLineCount = 1000
+)
+
 // CatArgs defines the arguments for the cat tool
 type CatArgs struct {
-	Path string `json:"path"`
+	Path      string `json:"path" description:"The path of the file to read." required:"true"`
+	Offset    int64  `json:"offset,omitempty" description:"Byte offset to start reading from."`
+	Limit     int64  `json:"limit,omitempty" description:"Maximum number of bytes to return (default 256KB, max 10MB)."`
+	StartLine int    `json:"start_line,omitempty" description:"1-based line number to start reading from. Enables line mode."`
+	LineCount int    `json:"line_count,omitempty" description:"Maximum number of lines to return in line mode (default 1000)."`
+	Cursor    string `json:"cursor,omitempty" description:"Continuation cursor returned by a previous call."`
 }
 
 // CatResult defines the result structure for the cat tool
 type CatResult struct {
-	Content  string `json:"content"`
-	FilePath string `json:"file_path"`
-	Size     int64  `json:"size"`
+	Content    string `json:"content"`
+	FilePath   string `json:"file_path"`
+	Size       int64  `json:"size"`
+	Offset     int64  `json:"offset"`
+	BytesRead  int64  `json:"bytes_read"`
+	StartLine  int    `json:"start_line,omitempty"`
+	EndLine    int    `json:"end_line,omitempty"`
+	EOF        bool   `json:"eof"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// catCursor is the decoded form of CatResult.NextCursor
+type catCursor struct {
+	Path   string `json:"p"`
+	Offset int64  `json:"o"`
+	Line   int    `json:"l,omitempty"` // next line number, set only in line mode
 }
 
 // HandleCat implements the logic for the cat tool
-// This handler reads and returns the content of the file at the provided path
+// This handler reads and returns a page of the file at the provided path,
+// either as a byte range (offset/limit) or a line range (start_line/line_count).
 func HandleCat(ctx *server.Context, args CatArgs) (CatResult, error) {
 	ctx.Logger.Info("Handling Cat tool call")
 
@@ -31,13 +65,11 @@ func HandleCat(ctx *server.Context, args CatArgs) (CatResult, error) {
 		return CatResult{}, fmt.Errorf("path cannot be empty")
 	}
 
-	// Validate path for security
-	if err := validatePath(ctx, args.Path); err != nil {
+	// Validate path against the allowed directories and resolve it
+	cleanPath, err := validatePath(ctx, args.Path)
+	if err != nil {
 		return CatResult{}, fmt.Errorf("path validation failed: %w", err)
 	}
-
-	// Clean and resolve the path
-	cleanPath := filepath.Clean(args.Path)
 	ctx.Logger.Info("reading file", "path", cleanPath)
 
 	// Check if file exists
@@ -54,36 +86,245 @@ func HandleCat(ctx *server.Context, args CatArgs) (CatResult, error) {
 		return CatResult{}, fmt.Errorf("cannot cat a directory: %s", cleanPath)
 	}
 
-	// Check file size (optional safety check for very large files)
-	fileSize := fileInfo.Size()
-	const maxFileSize = 10 * 1024 * 1024 // 10MB limit
-	if fileSize > maxFileSize {
-		return CatResult{}, fmt.Errorf("file too large to cat: %d bytes (max %d bytes)", fileSize, maxFileSize)
+	// Resolve where to start reading, either from the cursor or the explicit range arguments
+	offset, line, err := resolveCatStart(args, cleanPath)
+	if err != nil {
+		return CatResult{}, err
 	}
 
-	// Read the file content
-	content, err := os.ReadFile(cleanPath)
+	limit := args.Limit
+	if limit <= 0 {
+		limit = defaultCatLimit
+	}
+	if limit > maxCatLimit {
+		return CatResult{}, fmt.Errorf("limit too large: %d bytes (max %d bytes)", limit, maxCatLimit)
+	}
+
+	file, err := os.Open(cleanPath)
 	if err != nil {
-		return CatResult{}, fmt.Errorf("failed to read file: %w", err)
+		return CatResult{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	fileSize := fileInfo.Size()
+	var page catPage
+	if line > 0 {
+		lineCount := args.LineCount
+		if lineCount <= 0 {
+			lineCount = defaultCatLineCount
+		}
+		page, err = readCatLines(file, offset, line, lineCount, limit)
+	} else {
+		page, err = readCatBytes(file, offset, limit, fileSize)
+	}
+	if err != nil {
+		return CatResult{}, err
 	}
 
 	// Convert to string and handle potential binary content
-	contentStr := string(content)
-	if containsBinaryData(content) {
+	contentStr := string(page.content)
+	if containsBinaryData(page.content) {
 		ctx.Logger.Info("File appears to contain binary data", "path", cleanPath)
-		contentStr = fmt.Sprintf("[Binary file - %d bytes]", len(content))
+		contentStr = fmt.Sprintf("[Binary file - %d bytes]", len(page.content))
 	}
 
 	result := CatResult{
-		Content:  contentStr,
-		FilePath: cleanPath,
-		Size:     fileSize,
+		Content:   contentStr,
+		FilePath:  cleanPath,
+		Size:      fileSize,
+		Offset:    page.offset,
+		BytesRead: int64(len(page.content)),
+		EOF:       page.eof,
+	}
+	if line > 0 {
+		result.StartLine = line
+		result.EndLine = page.nextLine - 1
+		if page.partialLine {
+			result.EndLine = page.nextLine
+		}
+	}
+	if !page.eof {
+		result.NextCursor, err = encodeCatCursor(catCursor{
+			Path:   cleanPath,
+			Offset: page.offset + int64(len(page.content)),
+			Line:   page.nextLine,
+		})
+		if err != nil {
+			return CatResult{}, fmt.Errorf("failed to encode cursor: %w", err)
+		}
 	}
 
-	ctx.Logger.Info("Successfully read file", "path", cleanPath, "size", fileSize)
+	ctx.Logger.Info("Successfully read file", "path", cleanPath, "size", fileSize, "offset", result.Offset, "bytes_read", result.BytesRead, "eof", result.EOF)
 	return result, nil
 }
 
+// catPage is a single page of file content read by HandleCat
+type catPage struct {
+	content     []byte
+	offset      int64
+	nextLine    int
+	eof         bool
+	partialLine bool // content is the start of line nextLine, which continues on the next page
+}
+
+// resolveCatStart returns the byte offset and line number (0 for byte mode) to start reading at
+func resolveCatStart(args CatArgs, cleanPath string) (int64, int, error) {
+	if args.Cursor != "" {
+		cursor, err := decodeCatCursor(args.Cursor)
+		if err != nil {
+			return 0, 0, err
+		}
+		if cursor.Path != cleanPath {
+			return 0, 0, fmt.Errorf("cursor was issued for %s, not %s", cursor.Path, cleanPath)
+		}
+		return cursor.Offset, cursor.Line, nil
+	}
+
+	if args.Offset < 0 {
+		return 0, 0, fmt.Errorf("offset cannot be negative: %d", args.Offset)
+	}
+	if args.StartLine < 0 {
+		return 0, 0, fmt.Errorf("start_line cannot be negative: %d", args.StartLine)
+	}
+	if args.StartLine > 0 && args.Offset > 0 {
+		return 0, 0, fmt.Errorf("offset and start_line cannot be combined")
+	}
+	return args.Offset, args.StartLine, nil
+}
+
+// readCatBytes reads up to limit bytes starting at offset
+func readCatBytes(file *os.File, offset, limit, fileSize int64) (catPage, error) {
+	if offset > fileSize {
+		return catPage{}, fmt.Errorf("offset %d is past end of file (%d bytes)", offset, fileSize)
+	}
+
+	// Size the buffer by what's left of the file rather than the requested limit
+	content := make([]byte, min(limit, fileSize-offset))
+	n, err := file.ReadAt(content, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return catPage{}, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return catPage{
+		content: content[:n],
+		offset:  offset,
+		eof:     offset+int64(n) >= fileSize,
+	}, nil
+}
+
+// readCatLines reads up to lineCount whole lines (and at most limit bytes) starting at
+// line number startLine. When resuming from a cursor, offset is the byte offset of startLine;
+// otherwise the preceding lines are skipped by scanning from the start of the file.
+// A line longer than limit is returned in parts.
+func readCatLines(file *os.File, offset int64, startLine, lineCount int, limit int64) (catPage, error) {
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return catPage{}, fmt.Errorf("failed to seek file: %w", err)
+	}
+	reader := bufio.NewReader(file)
+
+	// Skip lines preceding startLine when not resuming from a cursor
+	line := 1
+	if offset > 0 {
+		line = startLine
+	}
+	for line < startLine {
+		skipped, err := reader.ReadSlice('\n')
+		offset += int64(len(skipped))
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue // long line, keep consuming it
+		}
+		if errors.Is(err, io.EOF) {
+			return catPage{}, fmt.Errorf("start_line %d is past end of file (%d lines)", startLine, line)
+		}
+		if err != nil {
+			return catPage{}, fmt.Errorf("failed to read file: %w", err)
+		}
+		line++
+	}
+
+	var content bytes.Buffer
+	page := catPage{offset: offset, nextLine: line}
+	full := false
+	for read := 0; read < lineCount; read++ {
+		text, tooLong, err := readLine(reader, int(limit)-content.Len())
+		if tooLong {
+			if content.Len() == 0 {
+				// A line longer than the limit is returned in parts, the cursor
+				// continuing within it
+				content.Write(text)
+				page.partialLine = true
+			}
+			full = true
+			break
+		}
+		content.Write(text)
+		if errors.Is(err, io.EOF) {
+			if len(text) > 0 {
+				page.nextLine++
+			}
+			page.eof = true
+			break
+		}
+		if err != nil {
+			return catPage{}, fmt.Errorf("failed to read file: %w", err)
+		}
+		page.nextLine++
+	}
+
+	// Peek so a page that ends exactly at the end of the file is reported as EOF.
+	// A full page stopped before a line that was read past, so there is more.
+	if !page.eof && !full {
+		if _, err := reader.Peek(1); errors.Is(err, io.EOF) {
+			page.eof = true
+		}
+	}
+
+	page.content = content.Bytes()
+	return page, nil
+}
+
+// readLine reads the next line including its newline, but stops once more than
+// max bytes would be read, so a huge line without newlines never has to fit in
+// memory. tooLong reports that the line was cut off; text then holds its first
+// max bytes.
+func readLine(reader *bufio.Reader, max int) (text []byte, tooLong bool, err error) {
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if len(text)+len(chunk) > max {
+			return append(text, chunk[:max-len(text)]...), true, nil
+		}
+		text = append(text, chunk...)
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return text, false, err
+		}
+	}
+}
+
+// encodeCatCursor serializes a cursor into an opaque string
+func encodeCatCursor(cursor catCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCatCursor parses a cursor produced by encodeCatCursor
+func decodeCatCursor(encoded string) (catCursor, error) {
+	var cursor catCursor
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, fmt.Errorf("invalid cursor: %w", err)
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, fmt.Errorf("invalid cursor: %w", err)
+	}
+	if cursor.Offset < 0 || cursor.Line < 0 {
+		return cursor, fmt.Errorf("invalid cursor: negative position")
+	}
+	return cursor, nil
+}
+
 // containsBinaryData checks if the content appears to be binary
 func containsBinaryData(data []byte) bool {
 	// Simple heuristic: if more than 10% of the first 1024 bytes are non-printable, consider it binary
@@ -91,6 +332,9 @@ func containsBinaryData(data []byte) bool {
 	if len(data) < sampleSize {
 		sampleSize = len(data)
 	}
+	if sampleSize == 0 {
+		return false
+	}
 
 	nonPrintableCount := 0
 	for i := 0; i < sampleSize; i++ {
@@ -108,22 +352,19 @@ func containsBinaryData(data []byte) bool {
 	return float64(nonPrintableCount)/float64(sampleSize) > 0.1
 }
 
-// validatePath performs basic security checks on the file path
-func validatePath(ctx *server.Context, path string) error {
-	// Prevent directory traversal attacks
-	if strings.Contains(path, "..") {
-		return fmt.Errorf("path traversal not allowed: %s", path)
+// validatePath resolves the path through the configured path policy,
+// rejecting any path outside the allowed directories
+func validatePath(ctx *server.Context, path string) (string, error) {
+	policy, err := config.GetPathPolicy(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to load path policy: %w", err)
 	}
 
-	// Convert to absolute path for additional checks
-	absPath, err := filepath.Abs(path)
+	resolved, err := policy.Resolve(path)
 	if err != nil {
-		return fmt.Errorf("failed to resolve absolute path: %w", err)
+		return "", err
 	}
 
-	// Additional security checks could be added here
-	// For example, checking against allowed directories from config
-	ctx.Logger.Info("Validated path", "original", path, "absolute", absPath)
-
-	return nil
+	ctx.Logger.Info("Validated path", "original", path, "resolved", resolved)
+	return resolved, nil
 }
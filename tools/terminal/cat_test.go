@@ -0,0 +1,199 @@
+package terminal
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang-mcp-testing/tools/config"
+
+	"github.com/localrivet/gomcp/server"
+)
+
+// TestMain keeps the tests independent of the config files and MCP_* variables
+// of the machine they run on
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "config")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	config.IsolateForTesting(dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// mockContext creates a server context for testing
+func mockContext() *server.Context {
+	return &server.Context{
+		Logger: slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+			Level: slog.LevelDebug,
+		})),
+	}
+}
+
+// writeTestFile creates a file with the given content in a temp directory
+func writeTestFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+	return path
+}
+
+func TestHandleCat_WholeFile(t *testing.T) {
+	path := writeTestFile(t, "hello\nworld\n")
+
+	result, err := HandleCat(mockContext(), CatArgs{Path: path})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Content != "hello\nworld\n" {
+		t.Errorf("Expected full content, got %q", result.Content)
+	}
+	if !result.EOF || result.NextCursor != "" {
+		t.Errorf("Expected EOF with no cursor, got eof=%v cursor=%q", result.EOF, result.NextCursor)
+	}
+}
+
+func TestHandleCat_BytePaging(t *testing.T) {
+	path := writeTestFile(t, "abcdefghij")
+	ctx := mockContext()
+
+	var pages []string
+	args := CatArgs{Path: path, Limit: 4}
+	for {
+		result, err := HandleCat(ctx, args)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		pages = append(pages, result.Content)
+		if result.EOF {
+			break
+		}
+		args = CatArgs{Path: path, Limit: 4, Cursor: result.NextCursor}
+	}
+
+	expected := []string{"abcd", "efgh", "ij"}
+	if strings.Join(pages, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected pages %v, got %v", expected, pages)
+	}
+}
+
+func TestReadCatBytes_SmallFileLargeLimit(t *testing.T) {
+	file, err := os.Open(writeTestFile(t, "abcdefghij"))
+	if err != nil {
+		t.Fatalf("failed to open test file: %v", err)
+	}
+	defer file.Close()
+
+	page, err := readCatBytes(file, 6, 1<<40, 10)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if string(page.content) != "ghij" || !page.eof {
+		t.Errorf("Expected the rest of the file, got %q eof=%v", page.content, page.eof)
+	}
+	if cap(page.content) != 4 {
+		t.Errorf("Expected a buffer sized to the remaining 4 bytes, got %d", cap(page.content))
+	}
+}
+
+func TestHandleCat_LinePaging(t *testing.T) {
+	path := writeTestFile(t, "one\ntwo\nthree\nfour\nfive")
+	ctx := mockContext()
+
+	result, err := HandleCat(ctx, CatArgs{Path: path, StartLine: 2, LineCount: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Content != "two\nthree\n" {
+		t.Errorf("Expected lines 2-3, got %q", result.Content)
+	}
+	if result.StartLine != 2 || result.EndLine != 3 {
+		t.Errorf("Expected lines 2-3, got %d-%d", result.StartLine, result.EndLine)
+	}
+	if result.EOF {
+		t.Fatal("Expected more lines to be available")
+	}
+
+	result, err = HandleCat(ctx, CatArgs{Path: path, LineCount: 2, Cursor: result.NextCursor})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Content != "four\nfive" {
+		t.Errorf("Expected lines 4-5, got %q", result.Content)
+	}
+	if !result.EOF || result.EndLine != 5 {
+		t.Errorf("Expected EOF at line 5, got eof=%v end_line=%d", result.EOF, result.EndLine)
+	}
+}
+
+func TestHandleCat_LongLine(t *testing.T) {
+	long := strings.Repeat("x", 100000)
+	path := writeTestFile(t, "short\n"+long+"\nlast\n")
+	ctx := mockContext()
+
+	// The long line doesn't fit after the first one, so the page stops before it
+	result, err := HandleCat(ctx, CatArgs{Path: path, StartLine: 1, Limit: 4096})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Content != "short\n" || result.EOF || result.EndLine != 1 {
+		t.Fatalf("Expected only the first line, got %d bytes eof=%v end_line=%d", len(result.Content), result.EOF, result.EndLine)
+	}
+
+	// The long line is returned in parts of at most the limit, resuming mid-line
+	var pages []string
+	args := CatArgs{Path: path, Limit: 4096, Cursor: result.NextCursor}
+	for {
+		result, err = HandleCat(ctx, args)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(result.Content) > 4096 {
+			t.Fatalf("Expected at most 4096 bytes, got %d", len(result.Content))
+		}
+		if len(pages) == 0 && (result.StartLine != 2 || result.EndLine != 2) {
+			t.Errorf("Expected the first part to cover line 2, got lines %d-%d", result.StartLine, result.EndLine)
+		}
+		pages = append(pages, result.Content)
+		if result.EOF {
+			break
+		}
+		args.Cursor = result.NextCursor
+	}
+	if strings.Join(pages, "") != long+"\nlast\n" || result.EndLine != 3 {
+		t.Errorf("Expected the pages to make up the rest of the file, got %d bytes ending at line %d", len(strings.Join(pages, "")), result.EndLine)
+	}
+}
+
+func TestHandleCat_InvalidArgs(t *testing.T) {
+	path := writeTestFile(t, "one\ntwo\n")
+
+	tests := []struct {
+		name string
+		args CatArgs
+	}{
+		{name: "Empty path", args: CatArgs{}},
+		{name: "Negative offset", args: CatArgs{Path: path, Offset: -1}},
+		{name: "Offset past end", args: CatArgs{Path: path, Offset: 100}},
+		{name: "Offset with start line", args: CatArgs{Path: path, Offset: 1, StartLine: 1}},
+		{name: "Start line past end", args: CatArgs{Path: path, StartLine: 10}},
+		{name: "Limit too large", args: CatArgs{Path: path, Limit: maxCatLimit + 1}},
+		{name: "Garbage cursor", args: CatArgs{Path: path, Cursor: "!!!"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := HandleCat(mockContext(), tt.args); err == nil {
+				t.Fatal("Expected error, got none")
+			}
+		})
+	}
+}
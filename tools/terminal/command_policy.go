@@ -0,0 +1,499 @@
+package terminal
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// shellNames are interpreters whose -c argument is parsed as a nested command line
+var shellNames = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "fish": true,
+}
+
+// wrapperOptions describes how a wrapper's arguments lead up to the command it runs
+type wrapperOptions struct {
+	short      string   // short options taking a value, e.g. "n" for nice -n 10
+	long       []string // long options taking a value, without the leading dashes
+	positional int      // operands before the command, e.g. the duration of timeout
+}
+
+// commandWrappers run their remaining arguments as another command
+var commandWrappers = map[string]wrapperOptions{
+	"env":        {short: "uCS", long: []string{"unset", "chdir", "split-string"}},
+	"command":    {},
+	"builtin":    {},
+	"exec":       {short: "a"},
+	"nohup":      {},
+	"time":       {short: "fo", long: []string{"format", "output"}},
+	"nice":       {short: "n", long: []string{"adjustment"}},
+	"ionice":     {short: "cnp", long: []string{"class", "classdata", "pid"}},
+	"timeout":    {short: "sk", long: []string{"signal", "kill-after"}, positional: 1},
+	"xargs":      {short: "aEILnPsd", long: []string{"arg-file", "eof", "replace", "max-lines", "max-args", "max-procs", "max-chars", "delimiter", "process-slot-var"}},
+	"stdbuf":     {short: "ioe", long: []string{"input", "output", "error"}},
+	"sudo":       {short: "CDghpRrTtUu", long: []string{"close-from", "chdir", "group", "host", "prompt", "chroot", "role", "command-timeout", "type", "other-user", "user"}},
+	"doas":       {short: "Cu"},
+	"strace":     {short: "abeIoOPpSsUuX", long: []string{"output", "signal", "trace", "user"}},
+	"watch":      {short: "nq", long: []string{"interval", "equexit"}},
+	"caffeinate": {short: "tw"},
+	"setsid":     {},
+	"chroot":     {long: []string{"userspec", "groups"}, positional: 1},
+	"busybox":    {},
+	"toybox":     {},
+}
+
+// findExecActions are the find actions followed by a command, ended by ";" or "+"
+var findExecActions = map[string]bool{
+	"-exec": true, "-execdir": true, "-ok": true, "-okdir": true,
+}
+
+// shellKeywords may precede the command word of a simple command
+var shellKeywords = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "fi": true, "do": true, "done": true,
+	"while": true, "until": true, "!": true, "{": true, "}": true, "esac": true,
+}
+
+// checkBlockedCommands parses a shell command line and returns an error if any
+// executable it would run appears in the blocked list
+func checkBlockedCommands(commandLine string, blocked []string) error {
+	names, err := commandNames(commandLine)
+	if err != nil {
+		return err
+	}
+
+	blockedSet := make(map[string]bool, len(blocked))
+	for _, name := range blocked {
+		blockedSet[normalizeCommandName(name)] = true
+	}
+
+	for _, name := range names {
+		if blockedSet[normalizeCommandName(name)] {
+			return fmt.Errorf("command %q is blocked by configuration", name)
+		}
+	}
+	return nil
+}
+
+// normalizeCommandName reduces an executable to the name used for blocklist matching,
+// so that /bin/rm, "rm" and RM.EXE all match rm
+func normalizeCommandName(name string) string {
+	name = strings.ToLower(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	return strings.TrimSuffix(name, ".exe")
+}
+
+// commandNames returns every executable invoked by a shell command line, including those
+// behind pipes, command lists, subshells, command substitutions, wrappers and `sh -c`
+func commandNames(commandLine string) ([]string, error) {
+	segments, err := splitCommandLine(commandLine)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, segment := range segments {
+		if segment.nested != "" {
+			nested, err := commandNames(segment.nested)
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, nested...)
+			continue
+		}
+		segmentNames, err := segmentCommandNames(segment.words)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, segmentNames...)
+	}
+	return names, nil
+}
+
+// segmentCommandNames finds the command words of a single simple command
+func segmentCommandNames(words []string) ([]string, error) {
+	var names []string
+	i := 0
+	for i < len(words) {
+		word := words[i]
+		switch {
+		case shellKeywords[word]:
+			i++
+			continue
+		case word == "for" || word == "case" || word == "select":
+			// Loop variables and case subjects are not commands; the body follows a separate segment
+			return names, nil
+		case isAssignment(word):
+			i++
+			continue
+		case word != "[" && word != "[[" && strings.ContainsAny(word, "$`*?[{"):
+			// The executable would only be known after expansion or globbing, so it cannot be checked
+			return nil, fmt.Errorf("command names built from variables, substitutions or patterns are not allowed: %s", word)
+		}
+
+		names = append(names, word)
+		name := normalizeCommandName(word)
+		i++
+
+		switch {
+		case isWrapper(name):
+			next, split := wrapperArgs(commandWrappers[name], words, i)
+			if split != "" {
+				// env -S splits its value into the command and its first arguments
+				nested, err := commandNames(split + " " + strings.Join(words[next:], " "))
+				if err != nil {
+					return nil, err
+				}
+				return append(names, nested...), nil
+			}
+			i = next
+		case shellNames[name]:
+			nested, err := shellCommandNames(words[i:])
+			if err != nil {
+				return nil, err
+			}
+			return append(names, nested...), nil
+		case name == "find":
+			nested, err := findCommandNames(words[i:])
+			if err != nil {
+				return nil, err
+			}
+			return append(names, nested...), nil
+		case name == "eval":
+			nested, err := commandNames(strings.Join(words[i:], " "))
+			if err != nil {
+				return nil, err
+			}
+			return append(names, nested...), nil
+		default:
+			return names, nil
+		}
+	}
+	return names, nil
+}
+
+// isWrapper reports whether name runs its remaining arguments as another command
+func isWrapper(name string) bool {
+	_, ok := commandWrappers[name]
+	return ok
+}
+
+// wrapperArgs skips the options, option values and leading operands a wrapper
+// takes before its command and returns the index of the command. If the
+// wrapper was given a command line to split, as with env -S, that is returned too.
+func wrapperArgs(opts wrapperOptions, words []string, i int) (int, string) {
+	var split string
+	for i < len(words) {
+		word := words[i]
+		switch {
+		case word == "--":
+			i++
+			return skipOperands(opts, words, i), split
+		case strings.HasPrefix(word, "--"):
+			i++
+			option, value, hasValue := strings.Cut(word[2:], "=")
+			if !slices.Contains(opts.long, option) {
+				continue
+			}
+			if !hasValue && i < len(words) {
+				value = words[i]
+				i++
+			}
+			if option == "split-string" {
+				split = value
+			}
+		case strings.HasPrefix(word, "-") && len(word) > 1:
+			i++
+			// Short options may be grouped, and a value may follow its option directly
+			for j := 1; j < len(word); j++ {
+				if !strings.ContainsRune(opts.short, rune(word[j])) {
+					continue
+				}
+				value := word[j+1:]
+				if value == "" && i < len(words) {
+					value = words[i]
+					i++
+				}
+				if word[j] == 'S' {
+					split = value
+				}
+				break
+			}
+		case isAssignment(word):
+			// env NAME=value
+			i++
+		default:
+			return skipOperands(opts, words, i), split
+		}
+	}
+	return i, split
+}
+
+// skipOperands skips the operands a wrapper takes before its command
+func skipOperands(opts wrapperOptions, words []string, i int) int {
+	return min(i+opts.positional, len(words))
+}
+
+// shellCommandNames returns the commands run by a shell given args: those of
+// the -c command line, or none for a script file. A shell reading commands from
+// standard input, such as through a pipe or a here-string, cannot be checked.
+func shellCommandNames(args []string) ([]string, error) {
+	command := false
+	for j := 0; j < len(args); j++ {
+		arg := args[j]
+		switch {
+		case arg == "--":
+			switch {
+			case j+1 == len(args):
+				return nil, fmt.Errorf("shell commands read from standard input are not allowed")
+			case command:
+				return commandNames(args[j+1])
+			}
+			return nil, nil
+		case arg == "-":
+			return nil, fmt.Errorf("shell commands read from standard input are not allowed")
+		case strings.HasPrefix(arg, "--"):
+			if arg == "--rcfile" || arg == "--init-file" {
+				j++
+			}
+		case strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "+"):
+			flags := arg[1:]
+			if arg[0] == '-' && strings.ContainsAny(flags, "si") {
+				return nil, fmt.Errorf("shell commands read from standard input are not allowed")
+			}
+			if arg[0] == '-' && strings.ContainsRune(flags, 'c') {
+				command = true
+			}
+			if strings.ContainsAny(flags, "oO") {
+				// Option names, e.g. -o pipefail
+				j++
+			}
+		case command:
+			return commandNames(arg)
+		default:
+			// A script file, which is not inspected
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("shell commands read from standard input are not allowed")
+}
+
+// findCommandNames returns the commands find runs through -exec and its variants
+func findCommandNames(args []string) ([]string, error) {
+	var names []string
+	for j := 0; j < len(args); j++ {
+		if !findExecActions[args[j]] {
+			continue
+		}
+		end := j + 1
+		for end < len(args) && args[end] != ";" && args[end] != "+" {
+			end++
+		}
+		nested, err := segmentCommandNames(args[j+1 : end])
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, nested...)
+		j = end
+	}
+	return names, nil
+}
+
+// isAssignment reports whether a word is a NAME=value environment prefix
+func isAssignment(word string) bool {
+	eq := strings.IndexByte(word, '=')
+	if eq <= 0 {
+		return false
+	}
+	for i, r := range word[:eq] {
+		if r != '_' && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// commandSegment is either a simple command's words or a nested command line
+// (subshell, command substitution or process substitution) to parse recursively
+type commandSegment struct {
+	words  []string
+	nested string
+}
+
+// splitCommandLine tokenizes a command line into simple commands, honoring quotes and escapes.
+// Redirection targets are dropped, and substitutions are returned as nested segments.
+func splitCommandLine(line string) ([]commandSegment, error) {
+	var segments []commandSegment
+	var words []string
+	var word strings.Builder
+	inWord := false
+	skipNextWord := false
+
+	endWord := func() {
+		if inWord {
+			if skipNextWord {
+				skipNextWord = false
+			} else {
+				words = append(words, word.String())
+			}
+		}
+		word.Reset()
+		inWord = false
+	}
+	endSegment := func() {
+		endWord()
+		if len(words) > 0 {
+			segments = append(segments, commandSegment{words: words})
+		}
+		words = nil
+	}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\':
+			if i+1 < len(line) {
+				i++
+				if line[i] != '\n' {
+					word.WriteByte(line[i])
+					inWord = true
+				}
+			}
+		case c == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote in command")
+			}
+			word.WriteString(line[i+1 : i+1+end])
+			inWord = true
+			i += end + 1
+		case c == '"':
+			j := i + 1
+			for ; j < len(line) && line[j] != '"'; j++ {
+				switch {
+				case line[j] == '\\' && j+1 < len(line):
+					j++
+					word.WriteByte(line[j])
+				case line[j] == '`' || (line[j] == '$' && j+1 < len(line) && line[j+1] == '('):
+					inner, end, err := substitution(line, j)
+					if err != nil {
+						return nil, err
+					}
+					segments = append(segments, commandSegment{nested: inner})
+					word.WriteString("$(...)")
+					j = end
+				default:
+					word.WriteByte(line[j])
+				}
+			}
+			if j >= len(line) {
+				return nil, fmt.Errorf("unterminated double quote in command")
+			}
+			inWord = true
+			i = j
+		case c == '`' || (c == '$' && i+1 < len(line) && line[i+1] == '('):
+			inner, end, err := substitution(line, i)
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, commandSegment{nested: inner})
+			// The substitution's output may itself be run as a command, so keep a placeholder word
+			word.WriteString("$(...)")
+			inWord = true
+			i = end
+		case (c == '<' || c == '>') && i+1 < len(line) && line[i+1] == '(':
+			inner, end, err := matchParen(line, i+1)
+			if err != nil {
+				return nil, err
+			}
+			endWord()
+			segments = append(segments, commandSegment{nested: inner})
+			i = end
+		case c == '<' || c == '>':
+			// File descriptor numbers directly before the operator are not arguments
+			if inWord && isDigits(word.String()) {
+				word.Reset()
+				inWord = false
+			}
+			endWord()
+			for i+1 < len(line) && (line[i+1] == '>' || line[i+1] == '&' || line[i+1] == '|' || line[i+1] == '<') {
+				i++
+			}
+			skipNextWord = true
+		case c == '(':
+			inner, end, err := matchParen(line, i)
+			if err != nil {
+				return nil, err
+			}
+			endSegment()
+			segments = append(segments, commandSegment{nested: inner})
+			i = end
+		case c == ')':
+			return nil, fmt.Errorf("unbalanced ')' in command")
+		case c == ';' || c == '&' || c == '|' || c == '\n':
+			endSegment()
+		case c == ' ' || c == '\t' || c == '\r':
+			endWord()
+		case c == '#' && !inWord:
+			// Comment until end of line
+			for i+1 < len(line) && line[i+1] != '\n' {
+				i++
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	endSegment()
+	return segments, nil
+}
+
+// substitution extracts the command inside $(...) or `...` starting at index i
+// and returns it along with the index of the closing delimiter
+func substitution(line string, i int) (string, int, error) {
+	if line[i] == '`' {
+		end := strings.IndexByte(line[i+1:], '`')
+		if end < 0 {
+			return "", 0, fmt.Errorf("unterminated backtick in command")
+		}
+		return line[i+1 : i+1+end], i + 1 + end, nil
+	}
+	return matchParen(line, i+1)
+}
+
+// matchParen returns the content between the '(' at index open and its matching ')'
+func matchParen(line string, open int) (string, int, error) {
+	depth := 0
+	for j := open; j < len(line); j++ {
+		switch line[j] {
+		case '\\':
+			j++
+		case '\'':
+			end := strings.IndexByte(line[j+1:], '\'')
+			if end < 0 {
+				return "", 0, fmt.Errorf("unterminated single quote in command")
+			}
+			j += end + 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return line[open+1 : j], j, nil
+			}
+		}
+	}
+	return "", 0, fmt.Errorf("unbalanced '(' in command")
+}
+
+// isDigits reports whether s is a non-empty string of ASCII digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
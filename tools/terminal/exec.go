@@ -0,0 +1,211 @@
+package terminal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"golang-mcp-testing/internal/utils"
+	"golang-mcp-testing/tools/config"
+
+	"github.com/localrivet/gomcp/server"
+)
+
+const (
+	// defaultExecTimeout is used when no timeout is provided
+	defaultExecTimeout = 30 * time.Second
+	// maxExecTimeout caps how long a single command may run
+	maxExecTimeout = 10 * time.Minute
+	// maxExecOutput caps how much of each output stream is returned
+	maxExecOutput = 1024 * 1024 // 1MB
+	// fallbackShell is used when neither the config nor $SHELL provide a shell
+	fallbackShell = "/bin/sh"
+)
+
+// ExecArgs defines the arguments for the exec tool
+type ExecArgs struct {
+	Command        string `json:"command" description:"The command line to run through the configured shell." required:"true"`
+	WorkingDir     string `json:"working_dir,omitempty" description:"Directory to run the command in. Must be inside the allowed directories."`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty" description:"Seconds before the command is killed (default 30, max 600)."`
+}
+
+// ExecResult defines the result structure for the exec tool
+type ExecResult struct {
+	ExitCode        int    `json:"exit_code"`
+	Stdout          string `json:"stdout"`
+	Stderr          string `json:"stderr"`
+	StdoutTruncated bool   `json:"stdout_truncated,omitempty"`
+	StderrTruncated bool   `json:"stderr_truncated,omitempty"`
+	TimedOut        bool   `json:"timed_out,omitempty"`
+	DurationMs      int64  `json:"duration_ms"`
+	Shell           string `json:"shell"`
+}
+
+// HandleExec implements the logic for the exec tool
+// This handler runs a command line through the configured shell after rejecting
+// any command that invokes an executable from BlockedCommands.
+func HandleExec(ctx *server.Context, args ExecArgs) (ExecResult, error) {
+	ctx.Logger.Info("Handling Exec tool call")
+
+	if args.Command == "" {
+		return ExecResult{}, fmt.Errorf("command cannot be empty")
+	}
+
+	cfg, err := config.Load(ctx)
+	if err != nil {
+		return ExecResult{}, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if err := checkBlockedCommands(args.Command, cfg.BlockedCommands); err != nil {
+		ctx.Logger.Info("Rejected command", "command", args.Command, "error", err)
+		return ExecResult{}, err
+	}
+
+	shell, err := resolveShell(cfg)
+	if err != nil {
+		return ExecResult{}, err
+	}
+
+	workingDir, err := resolveWorkingDir(ctx, args.WorkingDir)
+	if err != nil {
+		return ExecResult{}, err
+	}
+
+	timeout, err := resolveExecTimeout(args.TimeoutSeconds)
+	if err != nil {
+		return ExecResult{}, err
+	}
+
+	// The command stops when the tool call is cancelled as well as on timeout
+	runCtx, cancel := context.WithTimeout(utils.CallContext(ctx), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(runCtx, shell, "-c", args.Command)
+	cmd.Dir = workingDir
+	// Kill the whole process group, not only the shell, so children don't outlive the command
+	configureProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return signalProcessGroup(cmd, true)
+	}
+	// Don't wait forever on background children still holding the output pipes
+	cmd.WaitDelay = 2 * time.Second
+
+	stdout := &cappedBuffer{limit: maxExecOutput}
+	stderr := &cappedBuffer{limit: maxExecOutput}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	ctx.Logger.Info("running command", "shell", shell, "command", args.Command, "dir", workingDir, "timeout", timeout)
+	start := time.Now()
+	err = cmd.Run()
+	duration := time.Since(start)
+
+	result := ExecResult{
+		Stdout:          stdout.String(),
+		Stderr:          stderr.String(),
+		StdoutTruncated: stdout.truncated,
+		StderrTruncated: stderr.truncated,
+		DurationMs:      duration.Milliseconds(),
+		Shell:           shell,
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		result.TimedOut = true
+		result.ExitCode = -1
+	case errors.Is(runCtx.Err(), context.Canceled):
+		return ExecResult{}, fmt.Errorf("command cancelled after %s", duration.Round(time.Millisecond))
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil && !errors.Is(err, exec.ErrWaitDelay):
+		return ExecResult{}, fmt.Errorf("failed to run command: %w", err)
+	}
+
+	ctx.Logger.Info("Command finished", "exit_code", result.ExitCode, "timed_out", result.TimedOut, "duration_ms", result.DurationMs)
+	return result, nil
+}
+
+// resolveShell returns the configured DefaultShell, falling back to $SHELL and then /bin/sh
+func resolveShell(cfg *config.ServerConfig) (string, error) {
+	if cfg.DefaultShell != nil && *cfg.DefaultShell != "" {
+		shell, err := exec.LookPath(*cfg.DefaultShell)
+		if err != nil {
+			return "", fmt.Errorf("configured default shell %s is not executable: %w", *cfg.DefaultShell, err)
+		}
+		return shell, nil
+	}
+	if shell := os.Getenv("SHELL"); shell != "" {
+		if path, err := exec.LookPath(shell); err == nil {
+			return path, nil
+		}
+	}
+	return fallbackShell, nil
+}
+
+// resolveWorkingDir validates the requested working directory against the path policy.
+// An empty directory means the server's current working directory, which must also be allowed.
+func resolveWorkingDir(ctx *server.Context, dir string) (string, error) {
+	if dir == "" {
+		dir = "."
+	}
+	resolved, err := validatePath(ctx, dir)
+	if err != nil {
+		return "", fmt.Errorf("working directory validation failed: %w", err)
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", fmt.Errorf("failed to access working directory: %w", err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("working directory is not a directory: %s", resolved)
+	}
+	return resolved, nil
+}
+
+// resolveExecTimeout converts the requested timeout, applying the default and maximum
+func resolveExecTimeout(seconds int) (time.Duration, error) {
+	if seconds < 0 {
+		return 0, fmt.Errorf("timeout_seconds cannot be negative: %d", seconds)
+	}
+	if seconds == 0 {
+		return defaultExecTimeout, nil
+	}
+	timeout := time.Duration(seconds) * time.Second
+	if timeout > maxExecTimeout {
+		return 0, fmt.Errorf("timeout too large: %ds (max %ds)", seconds, int(maxExecTimeout.Seconds()))
+	}
+	return timeout, nil
+}
+
+// cappedBuffer keeps the first limit bytes written to it and discards the rest
+type cappedBuffer struct {
+	data      []byte
+	limit     int
+	truncated bool
+}
+
+// Write implements io.Writer, always reporting the full length so the process isn't interrupted
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	remaining := b.limit - len(b.data)
+	if remaining <= 0 {
+		b.truncated = b.truncated || len(p) > 0
+		return len(p), nil
+	}
+	if len(p) > remaining {
+		b.data = append(b.data, p[:remaining]...)
+		b.truncated = true
+		return len(p), nil
+	}
+	b.data = append(b.data, p...)
+	return len(p), nil
+}
+
+// String returns the captured output
+func (b *cappedBuffer) String() string {
+	return string(b.data)
+}
@@ -0,0 +1,464 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang-mcp-testing/tools/config"
+
+	"github.com/localrivet/gomcp/server"
+)
+
+const (
+	// processBufferSize is how much recent output is kept per stream
+	processBufferSize = 1024 * 1024 // 1MB
+	// maxRunningProcesses caps how many sessions may run at once
+	maxRunningProcesses = 16
+	// processKillGrace is how long a process gets to exit after SIGTERM before it is killed
+	processKillGrace = 3 * time.Second
+	// processGroupPollInterval is how often terminate checks whether the process group has exited
+	processGroupPollInterval = 50 * time.Millisecond
+	// exitedProcessTTL is how long an exited session's output stays readable
+	exitedProcessTTL = 10 * time.Minute
+	// maxExitedProcesses caps how many exited sessions are kept, oldest dropped first
+	maxExitedProcesses = 16
+)
+
+// processWriteTimeout is how long process_write waits for a process to read its input
+var processWriteTimeout = 5 * time.Second
+
+// processSession is a long-running process started by the process_start tool
+type processSession struct {
+	id         string
+	command    string
+	workingDir string
+	cmd        *exec.Cmd
+	stdin      *os.File
+	stdout     *ringBuffer
+	stderr     *ringBuffer
+	startedAt  time.Time
+	done       chan struct{} // closed once the process has exited
+
+	mu       sync.Mutex
+	exitCode int
+	exitedAt time.Time
+}
+
+// processManager tracks every process session started by this server
+type processManager struct {
+	mu       sync.Mutex
+	sessions map[string]*processSession
+	nextID   int
+}
+
+var processes = &processManager{sessions: map[string]*processSession{}}
+
+// ProcessStartArgs defines the arguments for the process_start tool
+type ProcessStartArgs struct {
+	Command    string `json:"command" description:"The command line to start through the configured shell." required:"true"`
+	WorkingDir string `json:"working_dir,omitempty" description:"Directory to run the process in. Must be inside the allowed directories."`
+}
+
+// ProcessReadArgs defines the arguments for the process_read tool
+type ProcessReadArgs struct {
+	SessionID    string `json:"session_id" description:"The session ID returned by process_start." required:"true"`
+	StdoutOffset int64  `json:"stdout_offset,omitempty" description:"Stdout offset returned by the previous read (0 for everything still buffered)."`
+	StderrOffset int64  `json:"stderr_offset,omitempty" description:"Stderr offset returned by the previous read (0 for everything still buffered)."`
+}
+
+// ProcessWriteArgs defines the arguments for the process_write tool
+type ProcessWriteArgs struct {
+	SessionID  string `json:"session_id" description:"The session ID returned by process_start." required:"true"`
+	Input      string `json:"input" description:"Text to write to the process's stdin. Include a trailing newline to submit a line."`
+	CloseStdin bool   `json:"close_stdin,omitempty" description:"Close stdin after writing, signalling end of input."`
+}
+
+// ProcessListArgs defines the arguments for the process_list tool
+type ProcessListArgs struct{}
+
+// ProcessKillArgs defines the arguments for the process_kill tool
+type ProcessKillArgs struct {
+	SessionID string `json:"session_id" description:"The session ID returned by process_start." required:"true"`
+}
+
+// ProcessInfo describes a process session
+type ProcessInfo struct {
+	SessionID  string `json:"session_id"`
+	Command    string `json:"command"`
+	WorkingDir string `json:"working_dir"`
+	PID        int    `json:"pid"`
+	Running    bool   `json:"running"`
+	ExitCode   *int   `json:"exit_code,omitempty"`
+	StartedAt  string `json:"started_at"`
+	ExitedAt   string `json:"exited_at,omitempty"`
+}
+
+// ProcessOutput is the result of the process_read tool
+type ProcessOutput struct {
+	ProcessInfo
+	Stdout        string `json:"stdout"`
+	Stderr        string `json:"stderr"`
+	StdoutOffset  int64  `json:"stdout_offset"`
+	StderrOffset  int64  `json:"stderr_offset"`
+	StdoutDropped int64  `json:"stdout_dropped,omitempty"`
+	StderrDropped int64  `json:"stderr_dropped,omitempty"`
+}
+
+// HandleProcessStart implements the logic for the process_start tool
+// This handler starts a long-running process and returns its session
+func HandleProcessStart(ctx *server.Context, args ProcessStartArgs) (ProcessInfo, error) {
+	ctx.Logger.Info("Handling ProcessStart tool call")
+
+	if args.Command == "" {
+		return ProcessInfo{}, fmt.Errorf("command cannot be empty")
+	}
+
+	cfg, err := config.Load(ctx)
+	if err != nil {
+		return ProcessInfo{}, fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := checkBlockedCommands(args.Command, cfg.BlockedCommands); err != nil {
+		ctx.Logger.Info("Rejected command", "command", args.Command, "error", err)
+		return ProcessInfo{}, err
+	}
+	shell, err := resolveShell(cfg)
+	if err != nil {
+		return ProcessInfo{}, err
+	}
+	workingDir, err := resolveWorkingDir(ctx, args.WorkingDir)
+	if err != nil {
+		return ProcessInfo{}, err
+	}
+
+	session, err := processes.start(shell, args.Command, workingDir)
+	if err != nil {
+		return ProcessInfo{}, err
+	}
+
+	ctx.Logger.Info("Started process", "session_id", session.id, "pid", session.cmd.Process.Pid, "command", args.Command)
+	return session.info(), nil
+}
+
+// HandleProcessRead implements the logic for the process_read tool
+// This handler returns output written since the provided offsets
+func HandleProcessRead(ctx *server.Context, args ProcessReadArgs) (ProcessOutput, error) {
+	ctx.Logger.Info("Handling ProcessRead tool call", "session_id", args.SessionID)
+
+	session, err := processes.get(args.SessionID)
+	if err != nil {
+		return ProcessOutput{}, err
+	}
+	if args.StdoutOffset < 0 || args.StderrOffset < 0 {
+		return ProcessOutput{}, fmt.Errorf("offsets cannot be negative")
+	}
+
+	// Snapshot the state before reading so a process reported as exited has all its output included
+	info := session.info()
+	stdout, stdoutNext, stdoutDropped := session.stdout.ReadFrom(args.StdoutOffset)
+	stderr, stderrNext, stderrDropped := session.stderr.ReadFrom(args.StderrOffset)
+
+	return ProcessOutput{
+		ProcessInfo:   info,
+		Stdout:        string(stdout),
+		Stderr:        string(stderr),
+		StdoutOffset:  stdoutNext,
+		StderrOffset:  stderrNext,
+		StdoutDropped: stdoutDropped,
+		StderrDropped: stderrDropped,
+	}, nil
+}
+
+// HandleProcessWrite implements the logic for the process_write tool
+// This handler writes input to the stdin of a running process
+func HandleProcessWrite(ctx *server.Context, args ProcessWriteArgs) (string, error) {
+	ctx.Logger.Info("Handling ProcessWrite tool call", "session_id", args.SessionID)
+
+	session, err := processes.get(args.SessionID)
+	if err != nil {
+		return "Error finding process session", err
+	}
+	if !session.running() {
+		return "Process has exited", fmt.Errorf("process session %s has exited", args.SessionID)
+	}
+
+	if args.Input != "" {
+		// A process that isn't reading would otherwise block the call once the pipe is full.
+		// Pipes without deadline support (Windows) are written without one.
+		if err := session.stdin.SetWriteDeadline(time.Now().Add(processWriteTimeout)); err != nil && !errors.Is(err, os.ErrNoDeadline) {
+			return "Error writing to process", fmt.Errorf("failed to set stdin deadline: %w", err)
+		}
+		written, err := io.WriteString(session.stdin, args.Input)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return "Process is not reading its input", fmt.Errorf("timed out after %s writing to stdin, %d of %d bytes written", processWriteTimeout, written, len(args.Input))
+		}
+		if err != nil {
+			return "Error writing to process", fmt.Errorf("failed to write to stdin: %w", err)
+		}
+	}
+	if args.CloseStdin {
+		if err := session.stdin.Close(); err != nil {
+			return "Error closing stdin", fmt.Errorf("failed to close stdin: %w", err)
+		}
+	}
+
+	return fmt.Sprintf("Wrote %d bytes to process %s.", len(args.Input), args.SessionID), nil
+}
+
+// HandleProcessList implements the logic for the process_list tool
+// This handler lists every process session, running or exited
+func HandleProcessList(ctx *server.Context, args ProcessListArgs) ([]ProcessInfo, error) {
+	ctx.Logger.Info("Handling ProcessList tool call")
+	return processes.list(), nil
+}
+
+// HandleProcessKill implements the logic for the process_kill tool
+// This handler terminates a process session and forgets it
+func HandleProcessKill(ctx *server.Context, args ProcessKillArgs) (ProcessInfo, error) {
+	ctx.Logger.Info("Handling ProcessKill tool call", "session_id", args.SessionID)
+
+	session, err := processes.remove(args.SessionID)
+	if err != nil {
+		return ProcessInfo{}, err
+	}
+	session.terminate()
+
+	ctx.Logger.Info("Terminated process", "session_id", session.id)
+	return session.info(), nil
+}
+
+// CloseAllProcesses terminates every process session. It is called when the server shuts down
+// so that dev servers and REPLs don't outlive it.
+func CloseAllProcesses() {
+	processes.mu.Lock()
+	sessions := make([]*processSession, 0, len(processes.sessions))
+	for id, session := range processes.sessions {
+		sessions = append(sessions, session)
+		delete(processes.sessions, id)
+	}
+	processes.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, session := range sessions {
+		wg.Add(1)
+		go func(session *processSession) {
+			defer wg.Done()
+			session.terminate()
+		}(session)
+	}
+	wg.Wait()
+}
+
+// start launches a new process session
+func (m *processManager) start(shell, command, workingDir string) (*processSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune(time.Now())
+
+	running := 0
+	for _, session := range m.sessions {
+		if session.running() {
+			running++
+		}
+	}
+	if running >= maxRunningProcesses {
+		return nil, fmt.Errorf("too many running processes (max %d), kill one first", maxRunningProcesses)
+	}
+
+	cmd := exec.Command(shell, "-c", command)
+	cmd.Dir = workingDir
+	// Don't wait forever on background children still holding the output pipes
+	cmd.WaitDelay = processKillGrace
+	configureProcessGroup(cmd)
+
+	// A pipe of our own rather than StdinPipe, so writes can have a deadline
+	stdinReader, stdin, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	cmd.Stdin = stdinReader
+
+	session := &processSession{
+		command:    command,
+		workingDir: workingDir,
+		cmd:        cmd,
+		stdin:      stdin,
+		stdout:     newRingBuffer(processBufferSize),
+		stderr:     newRingBuffer(processBufferSize),
+		done:       make(chan struct{}),
+		exitCode:   -1,
+	}
+	cmd.Stdout = session.stdout
+	cmd.Stderr = session.stderr
+
+	err = cmd.Start()
+	_ = stdinReader.Close()
+	if err != nil {
+		_ = stdin.Close()
+		return nil, fmt.Errorf("failed to start process: %w", err)
+	}
+	session.startedAt = time.Now()
+
+	m.nextID++
+	session.id = "proc-" + strconv.Itoa(m.nextID)
+	m.sessions[session.id] = session
+
+	go session.wait()
+	return session, nil
+}
+
+// get looks up a session by ID
+func (m *processManager) get(id string) (*processSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune(time.Now())
+
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown process session: %s", id)
+	}
+	return session, nil
+}
+
+// remove looks up a session by ID and stops tracking it
+func (m *processManager) remove(id string) (*processSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown process session: %s", id)
+	}
+	delete(m.sessions, id)
+	return session, nil
+}
+
+// list returns info for every session ordered by start time
+func (m *processManager) list() []ProcessInfo {
+	m.mu.Lock()
+	m.prune(time.Now())
+	sessions := make([]*processSession, 0, len(m.sessions))
+	for _, session := range m.sessions {
+		sessions = append(sessions, session)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].startedAt.Before(sessions[j].startedAt)
+	})
+
+	infos := make([]ProcessInfo, 0, len(sessions))
+	for _, session := range sessions {
+		infos = append(infos, session.info())
+	}
+	return infos
+}
+
+// prune forgets sessions that exited more than exitedProcessTTL ago, and the
+// oldest exited sessions beyond maxExitedProcesses, freeing their output buffers.
+// The caller must hold m.mu.
+func (m *processManager) prune(now time.Time) {
+	var exited []*processSession
+	for id, session := range m.sessions {
+		if session.running() {
+			continue
+		}
+		if now.Sub(session.exitTime()) > exitedProcessTTL {
+			delete(m.sessions, id)
+			continue
+		}
+		exited = append(exited, session)
+	}
+
+	if len(exited) <= maxExitedProcesses {
+		return
+	}
+	sort.Slice(exited, func(i, j int) bool {
+		return exited[i].exitTime().Before(exited[j].exitTime())
+	})
+	for _, session := range exited[:len(exited)-maxExitedProcesses] {
+		delete(m.sessions, session.id)
+	}
+}
+
+// wait records the exit status once the process exits
+func (s *processSession) wait() {
+	err := s.cmd.Wait()
+
+	s.mu.Lock()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		s.exitCode = 0
+	case errors.As(err, &exitErr):
+		s.exitCode = exitErr.ExitCode()
+	}
+	s.exitedAt = time.Now()
+	s.mu.Unlock()
+
+	close(s.done)
+}
+
+// running reports whether the process is still alive
+func (s *processSession) running() bool {
+	select {
+	case <-s.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// exitTime returns when the process exited
+func (s *processSession) exitTime() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.exitedAt
+}
+
+// terminate asks the process group to exit, killing it if it doesn't within processKillGrace.
+// The group is signalled even once the shell has exited, as children it started
+// in the background may still be running.
+func (s *processSession) terminate() {
+	_ = s.stdin.Close()
+	if !s.running() && !processGroupRunning(s.cmd) {
+		return
+	}
+	_ = signalProcessGroup(s.cmd, false)
+
+	deadline := time.Now().Add(processKillGrace)
+	for s.running() || processGroupRunning(s.cmd) {
+		if time.Now().After(deadline) {
+			_ = signalProcessGroup(s.cmd, true)
+			<-s.done
+			return
+		}
+		time.Sleep(processGroupPollInterval)
+	}
+}
+
+// info returns a snapshot of the session's state
+func (s *processSession) info() ProcessInfo {
+	info := ProcessInfo{
+		SessionID:  s.id,
+		Command:    s.command,
+		WorkingDir: s.workingDir,
+		PID:        s.cmd.Process.Pid,
+		Running:    s.running(),
+		StartedAt:  s.startedAt.Format(time.RFC3339),
+	}
+	if !info.Running {
+		s.mu.Lock()
+		exitCode := s.exitCode
+		info.ExitCode = &exitCode
+		info.ExitedAt = s.exitedAt.Format(time.RFC3339)
+		s.mu.Unlock()
+	}
+	return info
+}
@@ -0,0 +1,106 @@
+package terminal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang-mcp-testing/internal/utils"
+
+	"github.com/localrivet/gomcp/server"
+)
+
+// waitGone waits for the process pid to exit, counting zombies awaiting their reaper as gone
+func waitGone(t *testing.T, pid int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+		if errors.Is(err, os.ErrNotExist) {
+			return
+		}
+		// The state follows the parenthesised command name
+		if i := bytes.LastIndexByte(stat, ')'); i >= 0 && bytes.HasPrefix(stat[i+1:], []byte(" Z")) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	_ = syscall.Kill(pid, syscall.SIGKILL)
+	t.Fatalf("Expected process %d to be killed", pid)
+}
+
+// backgroundChild is a command that starts a long-running child, prints its PID and waits for it
+const backgroundChild = "sleep 30 & echo $!; wait"
+
+func TestHandleExec_TimeoutKillsChildren(t *testing.T) {
+	result, err := HandleExec(mockContext(), ExecArgs{Command: backgroundChild, TimeoutSeconds: 1, WorkingDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !result.TimedOut {
+		t.Error("Expected command to time out")
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(result.Stdout))
+	if err != nil {
+		t.Fatalf("Expected the child's PID, got %q", result.Stdout)
+	}
+	waitGone(t, pid)
+}
+
+func TestHandleExec_Cancelled(t *testing.T) {
+	s := server.NewServer("test")
+	ctx, err := server.NewContext(context.Background(), []byte(`{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "terminal_exec"}}`), s.GetServer())
+	if err != nil {
+		t.Fatalf("failed to create context: %v", err)
+	}
+	ctx.Logger = mockContext().Logger
+	stop := utils.BeginCall(ctx)
+	defer stop()
+	time.AfterFunc(200*time.Millisecond, func() {
+		_ = s.GetServer().HandleCancelledNotification([]byte(`{"jsonrpc": "2.0", "method": "notifications/cancelled", "params": {"requestId": "1"}}`))
+	})
+
+	start := time.Now()
+	_, err = HandleExec(ctx, ExecArgs{Command: "sleep 30", WorkingDir: t.TempDir()})
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("Expected the command to be cancelled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected cancellation to stop the command, took %s", elapsed)
+	}
+}
+
+func TestHandleProcessKill_ExitedShellChildren(t *testing.T) {
+	ctx := mockContext()
+
+	// The shell exits at once, leaving the child in its process group
+	info, err := HandleProcessStart(ctx, ProcessStartArgs{Command: "sleep 30 >/dev/null 2>&1 & echo $!", WorkingDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	output := waitForOutput(t, info.SessionID, 0, "\n")
+	pid, err := strconv.Atoi(strings.TrimSpace(output.Stdout))
+	if err != nil {
+		t.Fatalf("Expected the child's PID, got %q", output.Stdout)
+	}
+	session, err := processes.get(info.SessionID)
+	if err != nil {
+		t.Fatalf("Expected the session, got: %v", err)
+	}
+	select {
+	case <-session.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the shell to exit")
+	}
+
+	if _, err := HandleProcessKill(ctx, ProcessKillArgs{SessionID: info.SessionID}); err != nil {
+		t.Fatalf("Expected no error killing, got: %v", err)
+	}
+	waitGone(t, pid)
+}
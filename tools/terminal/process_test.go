@@ -0,0 +1,174 @@
+package terminal
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRingBuffer_ReadFrom(t *testing.T) {
+	buf := newRingBuffer(8)
+	buf.Write([]byte("abcde"))
+
+	data, next, dropped := buf.ReadFrom(0)
+	if string(data) != "abcde" || next != 5 || dropped != 0 {
+		t.Fatalf("Expected abcde/5/0, got %q/%d/%d", data, next, dropped)
+	}
+
+	buf.Write([]byte("fghij"))
+	data, next, dropped = buf.ReadFrom(next)
+	if string(data) != "fghij" || next != 10 || dropped != 0 {
+		t.Fatalf("Expected fghij/10/0, got %q/%d/%d", data, next, dropped)
+	}
+
+	// Everything before offset 2 has been overwritten
+	data, _, dropped = buf.ReadFrom(0)
+	if string(data) != "cdefghij" || dropped != 2 {
+		t.Fatalf("Expected cdefghij with 2 dropped, got %q/%d", data, dropped)
+	}
+
+	buf.Write([]byte("0123456789"))
+	data, next, dropped = buf.ReadFrom(10)
+	if string(data) != "23456789" || next != 20 || dropped != 2 {
+		t.Fatalf("Expected 23456789/20/2, got %q/%d/%d", data, next, dropped)
+	}
+
+	data, next, _ = buf.ReadFrom(20)
+	if len(data) != 0 || next != 20 {
+		t.Fatalf("Expected no new data, got %q/%d", data, next)
+	}
+}
+
+func TestProcessSession_Lifecycle(t *testing.T) {
+	ctx := mockContext()
+	defer CloseAllProcesses()
+
+	info, err := HandleProcessStart(ctx, ProcessStartArgs{Command: "while read line; do echo \"got $line\"; done", WorkingDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !info.Running {
+		t.Fatal("Expected process to be running")
+	}
+
+	if _, err := HandleProcessWrite(ctx, ProcessWriteArgs{SessionID: info.SessionID, Input: "hello\n"}); err != nil {
+		t.Fatalf("Expected no error writing, got: %v", err)
+	}
+
+	output := waitForOutput(t, info.SessionID, 0, "got hello")
+	if _, err := HandleProcessWrite(ctx, ProcessWriteArgs{SessionID: info.SessionID, Input: "again\n", CloseStdin: true}); err != nil {
+		t.Fatalf("Expected no error writing, got: %v", err)
+	}
+	output = waitForOutput(t, info.SessionID, output.StdoutOffset, "got again")
+	if strings.Contains(output.Stdout, "got hello") {
+		t.Errorf("Expected only new output, got %q", output.Stdout)
+	}
+
+	list, _ := HandleProcessList(ctx, ProcessListArgs{})
+	if len(list) != 1 || list[0].SessionID != info.SessionID {
+		t.Fatalf("Expected one listed session, got %+v", list)
+	}
+
+	if _, err := HandleProcessKill(ctx, ProcessKillArgs{SessionID: info.SessionID}); err != nil {
+		t.Fatalf("Expected no error killing, got: %v", err)
+	}
+	if _, err := HandleProcessRead(ctx, ProcessReadArgs{SessionID: info.SessionID}); err == nil {
+		t.Fatal("Expected killed session to be forgotten")
+	}
+}
+
+func TestProcessSession_KillRunning(t *testing.T) {
+	ctx := mockContext()
+
+	info, err := HandleProcessStart(ctx, ProcessStartArgs{Command: "sleep 60", WorkingDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	killed, err := HandleProcessKill(ctx, ProcessKillArgs{SessionID: info.SessionID})
+	if err != nil {
+		t.Fatalf("Expected no error killing, got: %v", err)
+	}
+	if killed.Running || killed.ExitCode == nil {
+		t.Fatalf("Expected process to have exited, got %+v", killed)
+	}
+}
+
+func TestProcessSession_WriteTimeout(t *testing.T) {
+	ctx := mockContext()
+	defer CloseAllProcesses()
+	processWriteTimeout = 200 * time.Millisecond
+	defer func() { processWriteTimeout = 5 * time.Second }()
+
+	info, err := HandleProcessStart(ctx, ProcessStartArgs{Command: "sleep 60", WorkingDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// More than a pipe holds, to a process that never reads it
+	_, err = HandleProcessWrite(ctx, ProcessWriteArgs{SessionID: info.SessionID, Input: strings.Repeat("x", 1024*1024)})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("Expected the write to time out, got: %v", err)
+	}
+}
+
+func TestProcessSession_BlockedCommand(t *testing.T) {
+	if _, err := HandleProcessStart(mockContext(), ProcessStartArgs{Command: "sleep 1 && rm -rf x", WorkingDir: t.TempDir()}); err == nil {
+		t.Fatal("Expected blocked command to be rejected")
+	}
+}
+
+// waitForOutput polls a session until its stdout since offset contains want
+func waitForOutput(t *testing.T, sessionID string, offset int64, want string) ProcessOutput {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		output, err := HandleProcessRead(mockContext(), ProcessReadArgs{SessionID: sessionID, StdoutOffset: offset})
+		if err != nil {
+			t.Fatalf("Expected no error reading, got: %v", err)
+		}
+		if strings.Contains(output.Stdout, want) {
+			return output
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %q", want)
+	return ProcessOutput{}
+}
+
+func TestProcessManager_Prune(t *testing.T) {
+	now := time.Now()
+	exited := func(id string, at time.Time) *processSession {
+		session := &processSession{id: id, done: make(chan struct{}), exitedAt: at}
+		close(session.done)
+		return session
+	}
+	running := &processSession{id: "running", done: make(chan struct{})}
+
+	m := &processManager{sessions: map[string]*processSession{
+		"running": running,
+		"stale":   exited("stale", now.Add(-exitedProcessTTL-time.Second)),
+	}}
+	for i := 0; i < maxExitedProcesses+2; i++ {
+		id := "exited-" + strconv.Itoa(i)
+		m.sessions[id] = exited(id, now.Add(-time.Duration(maxExitedProcesses+2-i)*time.Second))
+	}
+
+	m.prune(now)
+
+	if _, ok := m.sessions["running"]; !ok {
+		t.Error("Expected the running session to be kept")
+	}
+	if _, ok := m.sessions["stale"]; ok {
+		t.Error("Expected the session past the TTL to be removed")
+	}
+	for _, id := range []string{"exited-0", "exited-1"} {
+		if _, ok := m.sessions[id]; ok {
+			t.Errorf("Expected the oldest exited session %s to be removed", id)
+		}
+	}
+	if len(m.sessions) != maxExitedProcesses+1 {
+		t.Errorf("Expected %d exited sessions and the running one, got %d sessions", maxExitedProcesses, len(m.sessions))
+	}
+}
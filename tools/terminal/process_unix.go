@@ -0,0 +1,28 @@
+//go:build !windows
+
+package terminal
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// configureProcessGroup starts the process in its own process group so that
+// children spawned by the shell are terminated along with it
+func configureProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalProcessGroup sends SIGTERM, or SIGKILL when kill is set, to the process group
+func signalProcessGroup(cmd *exec.Cmd, kill bool) error {
+	sig := syscall.SIGTERM
+	if kill {
+		sig = syscall.SIGKILL
+	}
+	return syscall.Kill(-cmd.Process.Pid, sig)
+}
+
+// processGroupRunning reports whether any process is left in the process group
+func processGroupRunning(cmd *exec.Cmd) bool {
+	return syscall.Kill(-cmd.Process.Pid, 0) == nil
+}
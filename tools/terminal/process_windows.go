@@ -0,0 +1,20 @@
+//go:build windows
+
+package terminal
+
+import (
+	"os/exec"
+)
+
+// configureProcessGroup is a no-op on Windows
+func configureProcessGroup(cmd *exec.Cmd) {}
+
+// signalProcessGroup kills the process; Windows has no SIGTERM equivalent for console processes
+func signalProcessGroup(cmd *exec.Cmd, kill bool) error {
+	return cmd.Process.Kill()
+}
+
+// processGroupRunning is always false on Windows, where only the process itself is tracked
+func processGroupRunning(cmd *exec.Cmd) bool {
+	return false
+}
@@ -0,0 +1,70 @@
+package terminal
+
+import "sync"
+
+// ringBuffer keeps the most recent output of a stream in a fixed amount of memory.
+// Positions are absolute byte offsets into the stream, so readers can poll incrementally
+// and detect when output they have not read yet was overwritten.
+type ringBuffer struct {
+	mu      sync.Mutex
+	data    []byte
+	start   int   // index of the oldest byte in data
+	length  int   // number of valid bytes in data
+	written int64 // total bytes ever written
+}
+
+// newRingBuffer creates a ring buffer holding at most capacity bytes
+func newRingBuffer(capacity int) *ringBuffer {
+	return &ringBuffer{data: make([]byte, capacity)}
+}
+
+// Write implements io.Writer, overwriting the oldest bytes when full
+func (r *ringBuffer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := len(p)
+	r.written += int64(n)
+	capacity := len(r.data)
+	if n >= capacity {
+		copy(r.data, p[n-capacity:])
+		r.start = 0
+		r.length = capacity
+		return n, nil
+	}
+
+	end := (r.start + r.length) % capacity
+	copied := copy(r.data[end:], p)
+	copy(r.data, p[copied:])
+
+	r.length += n
+	if r.length > capacity {
+		r.start = (r.start + r.length - capacity) % capacity
+		r.length = capacity
+	}
+	return n, nil
+}
+
+// ReadFrom returns the bytes written since offset, the offset to poll from next,
+// and how many bytes after offset were lost because they were overwritten
+func (r *ringBuffer) ReadFrom(offset int64) (data []byte, next int64, dropped int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	oldest := r.written - int64(r.length)
+	if offset < oldest {
+		dropped = oldest - offset
+		offset = oldest
+	}
+	if offset >= r.written {
+		return nil, r.written, dropped
+	}
+
+	skip := int(offset - oldest)
+	count := r.length - skip
+	data = make([]byte, count)
+	from := (r.start + skip) % len(r.data)
+	copied := copy(data, r.data[from:])
+	copy(data[copied:], r.data[:count-copied])
+	return data, r.written, dropped
+}
@@ -2,9 +2,6 @@ package terminal
 
 import (
 	"os"
-	"os/user"
-	"path/filepath"
-	"strings"
 
 	"github.com/localrivet/gomcp/server"
 )
@@ -19,11 +16,11 @@ type WriteFileArgs struct {
 func HandleWriteFile(ctx *server.Context, args WriteFileArgs) (string, error) {
 	ctx.Logger.Info("Handling write_file tool call")
 
-	// Expand the path to handle ~ and relative paths
-	expandedPath, err := expandPath(args.Path)
+	// Validate the path against the allowed directories and resolve it
+	expandedPath, err := validatePath(ctx, args.Path)
 	if err != nil {
-		ctx.Logger.Info("Error expanding path", "path", args.Path, "error", err)
-		return "Error expanding path", err
+		ctx.Logger.Info("Error validating path", "path", args.Path, "error", err)
+		return "Error validating path", err
 	}
 
 	// Check if file exists
@@ -42,15 +39,3 @@ func HandleWriteFile(ctx *server.Context, args WriteFileArgs) (string, error) {
 
 	return "File written successfully.", nil
 }
-
-// expandPath expands ~ to home directory and converts to absolute path
-func expandPath(path string) (string, error) {
-	if strings.HasPrefix(path, "~/") {
-		usr, err := user.Current()
-		if err != nil {
-			return "", err
-		}
-		path = filepath.Join(usr.HomeDir, path[2:])
-	}
-	return filepath.Abs(path)
-}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+)
+
+// TestManifestTools checks manifest.json lists exactly the tools the server
+// registers, in the same order.
+func TestManifestTools(t *testing.T) {
+	data, err := os.ReadFile("manifest.json")
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	var manifest struct {
+		Tools []struct {
+			Name string `json:"name"`
+		} `json:"tools"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Failed to parse manifest: %v", err)
+	}
+
+	tools := newTools(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if len(manifest.Tools) != len(tools) {
+		t.Errorf("Expected %d tools in the manifest, got %d", len(tools), len(manifest.Tools))
+	}
+	for i, tool := range tools {
+		if i >= len(manifest.Tools) {
+			t.Errorf("Tool %s is missing from the manifest", tool.Name)
+			continue
+		}
+		if manifest.Tools[i].Name != tool.Name {
+			t.Errorf("Expected manifest tool %d to be %s, got %s", i, tool.Name, manifest.Tools[i].Name)
+		}
+	}
+}